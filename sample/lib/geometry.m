@@ -0,0 +1,11 @@
+type Point { x, y }
+
+impl Point {
+    norm() = self.x * self.x + self.y * self.y
+}
+
+origin = Point(0, 0)
+
+square(x) = x * x
+
+print 'geometry loaded'
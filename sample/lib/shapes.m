@@ -0,0 +1,3 @@
+import geometry
+
+unit = geometry.Point(1, 1)
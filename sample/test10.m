@@ -0,0 +1,72 @@
+range(n) = {
+    i = 0
+    for i < n {
+        yield i
+        i = i + 1
+    }
+}
+
+for x in range(3) {
+    print x
+}
+
+fib() = {
+    a = 0
+    b = 1
+    for true {
+        yield a
+        c = a + b
+        a = b
+        b = c
+    }
+}
+
+g = fib()
+i = 0
+for i < 10 {
+    print next(g)[0]
+    i = i + 1
+}
+
+countdown(n) = {
+    for n > 0 {
+        yield n
+        n = n - 1
+    }
+    return 'liftoff'
+}
+
+c = countdown(2)
+print next(c)
+print next(c)
+print next(c)
+print next(c)
+
+squares(xs) = {
+    for x in xs {
+        yield x * x
+    }
+}
+
+total = 0
+for s in squares([1, 2, 3, 4]) {
+    total = total + s
+}
+print total
+
+for ch in 'abc' {
+    print ch
+}
+
+failing() = {
+    yield 1
+    throw 'broken'
+}
+
+try {
+    for v in failing() {
+        print v
+    }
+} catch e {
+    print e.message
+}
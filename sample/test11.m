@@ -0,0 +1,81 @@
+square(x) = x * x
+
+tasks = [spawn square(1), spawn square(2), spawn square(3)]
+print wait(tasks)
+
+producer(c, n) = {
+    i = 0
+    for i < n {
+        send(c, i)
+        i = i + 1
+    }
+    close(c)
+}
+
+c = chan('int', 2)
+spawn producer(c, 5)
+total = 0
+for x in c {
+    total = total + x
+}
+print total
+
+worker(jobs, results) = {
+    for job in jobs {
+        send(results, job * 10)
+    }
+}
+
+jobs = chan(10)
+results = chan(10)
+workers = [spawn worker(jobs, results), spawn worker(jobs, results)]
+i = 1
+for i <= 4 {
+    send(jobs, i)
+    i = i + 1
+}
+close(jobs)
+wait(workers)
+close(results)
+sum = 0
+for r in results {
+    sum = sum + r
+}
+print sum
+
+fast = chan()
+slow = chan()
+spawn producer(fast, 1)
+select {
+    v = recv(fast) {
+        print v
+    }
+    v = recv(slow) {
+        print v
+    }
+}
+
+empty = chan()
+select {
+    v = recv(empty) {
+        print v
+    }
+    else {
+        print 'nothing ready'
+    }
+}
+
+typed = chan('string', 1)
+try {
+    send(typed, 1)
+} catch e {
+    print e.message
+}
+
+fail() = 1 / 0
+t = spawn fail()
+try {
+    wait(t)
+} catch e {
+    print e.kind
+}
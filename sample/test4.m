@@ -0,0 +1,20 @@
+type Point { x, y }
+
+type Line {
+    start
+    end
+}
+
+p = Point(1, 2)
+q = Point{y: 2, x: 1}
+print p
+print p == q
+
+p.x = 10
+print p.x + p.y
+print p != q
+
+l = Line(p, Point{x: 0, y: 'origin'})
+l.end.x = 3
+print l
+print l.start.x
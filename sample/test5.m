@@ -0,0 +1,33 @@
+type Point { x, y }
+type Point3 : Point { z }
+
+impl Point {
+    norm() = self.x * self.x + self.y * self.y
+
+    move(dx, dy) = {
+        self.x = self.x + dx
+        self.y = self.y + dy
+        return self
+    }
+
+    describe() = 'point'
+}
+
+impl Point3 {
+    norm() = super.norm() + self.z * self.z
+
+    describe() = {
+        return super.describe() + ' in space'
+    }
+}
+
+p = Point(3, 4)
+print p.norm()
+p.move(1, 1)
+print p
+
+q = Point3(1, 2, 3)
+print q.norm()
+print q.move(1, 1).norm()
+print q.describe()
+print q
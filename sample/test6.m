@@ -0,0 +1,9 @@
+import "lib/geometry.m"
+import "lib/geometry.m" as g
+from "lib/shapes.m" import unit
+from "lib/geometry.m" import square, origin
+
+print geometry.square(3)
+print g.Point(3, 4).norm()
+print unit
+print square(5) + origin.x
@@ -0,0 +1,51 @@
+type ValueError { message, value }
+
+safeDiv(a, b) = {
+    try {
+        return a / b
+    } catch e {
+        print e.kind
+        return 0
+    }
+}
+
+check(x) = {
+    if x < 0 {
+        throw ValueError('x 不能为负数', x)
+    }
+    return x
+}
+
+print safeDiv(1, 2)
+print safeDiv(1, 0)
+
+try {
+    check(0 - 1)
+} catch e {
+    print e
+    print e.value.value
+    print e.trace
+} finally {
+    print 'finally'
+}
+
+try {
+    print 'abc' - 1
+} catch e {
+    print e.message
+}
+
+try {
+    print undefined
+} catch e {
+    print e.kind
+}
+
+try {
+    throw 'oops'
+} catch e {
+    print e.kind + ': ' + e.message
+}
+
+print 1 + 2.5
+check(0 - 2)
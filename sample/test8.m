@@ -0,0 +1,43 @@
+greet(name, greeting = 'Hello', punct = '!') = greeting + ', ' + name + punct
+
+print greet('World')
+print greet('World', 'Hi')
+print greet(punct: '?', name: 'you')
+print greet('me', punct: '.')
+
+sum(first, ...rest) = {
+    total = first
+    i = 0
+    for i < len(rest) {
+        total = total + rest[i]
+        i = i + 1
+    }
+    return total
+}
+
+print sum(1)
+print sum(1, 2, 3, 4)
+
+tail(first, ...rest) = rest
+xs = tail(1, 2, 3)
+xs[0] = 'two'
+print xs
+print xs == ['two', 3]
+
+try {
+    greet()
+} catch e {
+    print e.message
+}
+
+try {
+    greet('a', 'b', 'c', 'd')
+} catch e {
+    print e.message
+}
+
+try {
+    greet('a', nam: 'b')
+} catch e {
+    print e.message
+}
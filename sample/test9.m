@@ -0,0 +1,22 @@
+count(n) = {
+    if n == 0 {
+        return 0
+    }
+    return 1 + count(n - 1)
+}
+
+sum(n, acc) = {
+    if n == 0 {
+        return acc
+    }
+    return sum(n - 1, acc + n)
+}
+
+print count(5000)
+print sum(1000000, 0)
+
+try {
+    count(100000)
+} catch e {
+    print e
+}
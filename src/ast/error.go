@@ -0,0 +1,160 @@
+package ast
+
+import (
+	"fmt"
+	"my-lang/token"
+	"strings"
+)
+
+// 错误类型
+const (
+	ErrorKind          = "Error" // throw 抛出的普通值
+	SyntaxError        = "SyntaxError"
+	NameError          = "NameError"
+	TypeError          = "TypeError"
+	AttributeError     = "AttributeError"
+	IndexError         = "IndexError"
+	ZeroDivisionError  = "ZeroDivisionError"
+	ImportError        = "ImportError"
+	StackOverflowError = "StackOverflowError"
+	ChannelError       = "ChannelError"
+	MemoryError        = "MemoryError"
+	PermissionError    = "PermissionError"
+	IOError            = "IOError"
+	AssertionError     = "AssertionError"
+	InternalError      = "InternalError" // 解释器自身的错误 (Go 的 panic)
+)
+
+// Error 脚本错误 (可以被 try/catch 捕获)
+type Error struct {
+	Kind    string       // 错误类型
+	Message string       // 错误信息
+	Value   interface{}  // throw 抛出的原始值
+	Trace   []TraceFrame // 抛出错误时的调用栈 (由外到内)
+	Pos     token.Pos    // 出错的位置 (可能没有)
+}
+
+// TraceFrame 调用栈中的一层
+type TraceFrame struct {
+	Name string     // 方法名, 最外层为 <main> 或者 <task>, 模块顶层为 <模块名>
+	Pos  token.Pos  // 这一层正在执行的位置: 外层为调用处, 最内层为出错处
+	Args []TraceArg // 调用参数
+}
+
+// TraceArg 调用参数的名字与值
+type TraceArg struct {
+	Name  string
+	Value interface{}
+}
+
+// 调用栈中参数值的最大长度
+const maxArgRepr = 40
+
+// String 格式: main.m:3:9 fib(n=0)
+func (f TraceFrame) String() string {
+	var sb strings.Builder
+	if f.Pos.IsValid() {
+		sb.WriteString(f.Pos.String())
+		sb.WriteString(" ")
+	}
+	sb.WriteString(f.Name)
+	if f.Args != nil {
+		sb.WriteString("(")
+		for i, arg := range f.Args {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			repr := []rune(Repr(arg.Value))
+			if len(repr) > maxArgRepr {
+				repr = append(repr[:maxArgRepr-3], []rune("...")...)
+			}
+			sb.WriteString(arg.Name)
+			sb.WriteString("=")
+			sb.WriteString(string(repr))
+		}
+		sb.WriteString(")")
+	}
+	return sb.String()
+}
+
+func NewError(kind string, format string, args ...interface{}) *Error {
+	msg := fmt.Sprintf(format, args...)
+	return &Error{
+		Kind:    kind,
+		Message: msg,
+		Value:   msg,
+	}
+}
+
+// ThrowValue 将 throw 的值包装成错误
+// 1. 错误值原样抛出
+// 2. 结构体以类型名作为错误类型, 如果有 message 字段则作为错误信息
+// 3. 其他值的错误类型为 Error
+func ThrowValue(val interface{}) *Error {
+	switch val := val.(type) {
+	case *Error:
+		return val
+	case *RecordValue:
+		err := &Error{
+			Kind:    val.Type.Name,
+			Message: val.String(),
+			Value:   val,
+		}
+		if val.Type.FieldIndex("message") >= 0 {
+			err.Message = fmt.Sprint(val.Get("message"))
+		}
+		return err
+	}
+	return &Error{
+		Kind:    ErrorKind,
+		Message: fmt.Sprint(val),
+		Value:   val,
+	}
+}
+
+// Error 格式: TypeError: 不合法的运算 string - int
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Kind, e.Message)
+}
+
+// Traceback 调用栈与错误信息, 格式与 Python 类似 (最近的调用在最后)
+//
+//	调用栈 (最近的调用在最后):
+//	  main.m:10:1 <main>
+//	  main.m:3:9 fib(n=0)
+//	ZeroDivisionError: 除数不能为 0
+func (e *Error) Traceback() string {
+	var sb strings.Builder
+	if len(e.Trace) > 0 {
+		sb.WriteString("调用栈 (最近的调用在最后):\n")
+		for _, frame := range e.Trace {
+			sb.WriteString("  ")
+			sb.WriteString(frame.String())
+			sb.WriteString("\n")
+		}
+	} else if e.Pos.IsValid() {
+		sb.WriteString(e.Pos.String())
+		sb.WriteString(": ")
+	}
+	sb.WriteString(e.Error())
+	return sb.String()
+}
+
+// Field 脚本中读取错误的字段: e.kind, e.message, e.value, e.trace
+func (e *Error) Field(name string) interface{} {
+	switch name {
+	case "kind":
+		return e.Kind
+	case "message":
+		return e.Message
+	case "value":
+		return e.Value
+	case "trace":
+		lines := make([]string, len(e.Trace))
+		for i, frame := range e.Trace {
+			lines[i] = frame.String()
+		}
+		return strings.Join(lines, "\n")
+	}
+	panic(NewError(AttributeError, "错误值没有字段 %s", name))
+}
@@ -1,14 +1,14 @@
 package ast
 
 import (
-	"fmt"
 	"my-lang/token"
-	"reflect"
+	"strings"
 )
 
 type (
 	// BinaryExpr 二元表达式
 	BinaryExpr struct {
+		At
 		Left  Expr
 		Op    int
 		Right Expr
@@ -16,32 +16,102 @@ type (
 
 	// LitExpr 字面量
 	LitExpr struct {
+		At
 		Type
 		Lit string
 	}
 
 	// IdentityExpr 变量
 	IdentityExpr struct {
+		At
 		Object
+		Module string // 模块成员 m.x 的限定名 m (没有则为空)
 	}
 
 	// BlockExpr 块状语句
 	BlockExpr struct {
+		At
 		Toks []token.Token
 	}
 
 	// CallFnExpr 调用方法
 	CallFnExpr struct {
+		At
 		Fn     *Function
-		Params []Expr
+		Params []Param
+		Module string // 模块成员 m.f() 的限定名 m (没有则为空)
+	}
+
+	// CallBuiltinExpr 调用内置方法
+	CallBuiltinExpr struct {
+		At
+		Builtin *Builtin
+		Params  []Expr
+		Module  string // 模块成员 m.f() 的限定名 m (没有则为空)
+	}
+
+	// Param 调用参数
+	Param struct {
+		At
+		Name  string // 命名参数 f(x: 1) (位置参数为空)
+		Value Expr
+	}
+
+	// ListExpr 列表字面量
+	ListExpr struct {
+		At
+		Elements []Expr
+	}
+
+	// IndexExpr 下标访问
+	IndexExpr struct {
+		At
+		X     Expr
+		Index Expr
+	}
+
+	// NewRecordExpr 构造结构体
+	NewRecordExpr struct {
+		At
+		Record *Record
+		Fields []Expr // 与 Record.Fields 一一对应
+		Module string // 模块成员 m.Point(...) 的限定名 m (没有则为空)
+	}
+
+	// FieldExpr 成员访问
+	FieldExpr struct {
+		At
+		X    Expr
+		Name string
+	}
+
+	// MethodCallExpr 调用类型方法
+	MethodCallExpr struct {
+		At
+		X      Expr
+		Name   string
+		Params []Param
+	}
+
+	// SpawnExpr 启动并发任务
+	SpawnExpr struct {
+		At
+		Call Expr // *CallFnExpr 或者 *MethodCallExpr
 	}
 )
 
-func (*BinaryExpr) expr()   {}
-func (*LitExpr) expr()      {}
-func (*IdentityExpr) expr() {}
-func (*BlockExpr) expr()    {}
-func (*CallFnExpr) expr()   {}
+func (*BinaryExpr) expr()      {}
+func (*LitExpr) expr()         {}
+func (*IdentityExpr) expr()    {}
+func (*BlockExpr) expr()       {}
+func (*CallFnExpr) expr()      {}
+func (*NewRecordExpr) expr()   {}
+func (*FieldExpr) expr()       {}
+func (*MethodCallExpr) expr()  {}
+func (*CallBuiltinExpr) expr() {}
+func (*ListExpr) expr()        {}
+func (*IndexExpr) expr()       {}
+func (*SpawnExpr) expr()       {}
 
 // 跳过方法内语句
 func (p *Parser) block() (toks []token.Token) {
@@ -67,8 +137,21 @@ func (p *Parser) block() (toks []token.Token) {
 	return
 }
 
+// 跳过一行语句, 遇到没有匹配的 } 时结束: impl Point { norm() = ...[}]
 func (p *Parser) line() (toks []token.Token) {
+	level := 0 // block 层数
 	for p.Token().Type != token.LINEBREAK && !p.IsEnd() {
+		if p.Token().Type == token.LBRACE {
+			level += 1
+		}
+
+		if p.Token().Type == token.RBRACE {
+			if level == 0 {
+				break
+			}
+			level -= 1
+		}
+
 		toks = append(toks, p.Token())
 		p.next()
 	}
@@ -76,42 +159,142 @@ func (p *Parser) line() (toks []token.Token) {
 	return
 }
 
+// 解析调用参数 (a, b, c: 1)
+func (p *Parser) callParams() []Param {
+	params := make([]Param, 0)
+
+	p.require(token.LPAREN, true)
+	for p.Token().Type != token.RPAREN {
+		var param Param
+
+		// ([c]: 1)
+		if p.Token().Type == token.IDENTITY && p.Tokens[p.Offset+1].Type == token.COLON {
+			param.Name = p.Token().Lit
+			p.next()
+			p.next()
+		} else if len(params) > 0 && params[len(params)-1].Name != "" {
+			panic(p.error(SyntaxError, "位置参数不能放在命名参数之后"))
+		}
+
+		param.Value = p.parseExpr(0)
+		params = append(params, param)
+
+		if p.Token().Type == token.COMMA {
+			p.next()
+		}
+	}
+	p.require(token.RPAREN, true)
+
+	return params
+}
+
+// 解析只有位置参数的调用参数 (a, b, c)
+func (p *Parser) positionalParams(name string) []Expr {
+	exprs := make([]Expr, 0)
+	for _, param := range p.callParams() {
+		if param.Name != "" {
+			panic(p.error(SyntaxError, "%s 不支持命名参数 %s", name, param.Name))
+		}
+		exprs = append(exprs, param.Value)
+	}
+	return exprs
+}
+
 // 调用方法
-func (p *Parser) callFn(obj Object) *CallFnExpr {
+func (p *Parser) callFn(obj Object) Expr {
 
 	name, _ := getObjectField(obj, "Name")
 
+	// 内置方法
+	if builtin, ok := obj.(*Builtin); ok {
+		return &CallBuiltinExpr{
+			Builtin: builtin,
+			Params:  p.positionalParams(builtin.Name),
+		}
+	}
+
 	// 如果调用的对象不是方法
-	if reflect.TypeOf(obj).String() != "*ast.Function" {
-		panic(fmt.Sprintf("错误: 无法调用方法 %s, 因为 %s 不是方法", name, name))
+	fn, ok := obj.(*Function)
+	if !ok {
+		panic(p.error(TypeError, "无法调用方法 %s, 因为 %s 不是方法", name, name))
 	}
-	fn := obj.(*Function)
 
-	params := make([]Expr, 0)
+	// 参数在运行时与方法参数绑定
+	return &CallFnExpr{
+		Fn:     fn,
+		Params: p.callParams(),
+	}
+}
 
-	p.require(token.LPAREN, true)
-	for p.Token().Type != token.RPAREN {
-		expr := p.parseExpr(0)
-		params = append(params, expr)
+// 解析列表字面量 [a, b, c]
+func (p *Parser) list() *ListExpr {
+	elements := make([]Expr, 0)
+
+	p.require(token.LBRACK, true)
+	p.skipLineBreak()
+	for p.Token().Type != token.RBRACK {
+		elements = append(elements, p.parseExpr(0))
 
 		if p.Token().Type == token.COMMA {
 			p.next()
 		}
+		p.skipLineBreak()
 	}
-	p.require(token.RPAREN, true)
+	p.require(token.RBRACK, true)
 
-	if len(params) != len(fn.Args) {
-		panic(fmt.Sprintf("错误: 参数数量不一致"))
+	return &ListExpr{
+		Elements: elements,
 	}
+}
 
-	return &CallFnExpr{
-		Fn:     fn,
-		Params: params,
+// 解析 1 为何物, "str" 为何物, a 为何物, 以及后缀的成员访问 a.b 与下标 a[0]
+func (p *Parser) implExpr() (expr Expr) {
+	pos := p.Token().Pos
+	expr = p.operand()
+	setPos(expr, pos)
+
+	// a[.b.c]
+	for expr != nil && (p.Token().Type == token.DOT || p.Token().Type == token.LBRACK) {
+		pos := p.Token().Pos
+		if p.Token().Type == token.LBRACK {
+			// a[[0]]
+			p.next()
+			index := p.parseExpr(0)
+			p.require(token.RBRACK, true)
+			expr = &IndexExpr{
+				At:    At{pos},
+				X:     expr,
+				Index: index,
+			}
+			continue
+		}
+
+		p.next()
+		name := p.require(token.IDENTITY, true)
+
+		if p.Token().Type == token.LPAREN {
+			// a.b(...)
+			expr = &MethodCallExpr{
+				At:     At{pos},
+				X:      expr,
+				Name:   name,
+				Params: p.callParams(),
+			}
+			continue
+		}
+
+		expr = &FieldExpr{
+			At:   At{pos},
+			X:    expr,
+			Name: name,
+		}
 	}
+
+	return
 }
 
-// 解析 1 为何物, "str" 为何物, a 为何物
-func (p *Parser) implExpr() (expr Expr) {
+// 解析单个操作数
+func (p *Parser) operand() (expr Expr) {
 
 	switch p.Token().Type {
 	case token.LPAREN:
@@ -125,18 +308,65 @@ func (p *Parser) implExpr() (expr Expr) {
 		obj := p.Objects.FindObject(p.Token().Lit)
 		if obj == nil {
 			// 如果对象表里没有此对象，直接报错
-			panic(fmt.Sprintf("错误: 找不到对象: %s", p.Token().Lit))
+			panic(p.error(NameError, "找不到对象: %s", p.Token().Lit))
 		}
 
 		p.next()
+
+		// 模块成员: m.name
+		var modules []string
+		for {
+			module, ok := obj.(*Module)
+			if !ok {
+				break
+			}
+			modules = append(modules, module.Name)
+			if p.Token().Type != token.DOT {
+				panic(p.error(ImportError, "模块 %s 不能作为值使用", module.Name))
+			}
+			p.next()
+
+			name := p.require(token.IDENTITY, false)
+			obj = module.Objects.FindObject(name)
+			if obj == nil {
+				panic(p.error(ImportError, "模块 %s 没有对象: %s", module.Name, name))
+			}
+			p.next()
+		}
+		module := strings.Join(modules, ".")
+
+		if record, ok := obj.(*Record); ok {
+			switch p.Token().Type {
+			case token.LPAREN:
+				// Point(...)
+				expr := p.newRecord(record)
+				expr.Module = module
+				return expr
+			case token.LBRACE:
+				// Point{...}
+				expr := p.newRecordByName(record)
+				expr.Module = module
+				return expr
+			}
+			panic(p.error(TypeError, "类型 %s 不能作为值使用", record.Name))
+		}
+
 		if p.Token().Type == token.LPAREN {
 			// a(...)
-			return p.callFn(obj)
+			switch expr := p.callFn(obj).(type) {
+			case *CallFnExpr:
+				expr.Module = module
+				return expr
+			case *CallBuiltinExpr:
+				expr.Module = module
+				return expr
+			}
 		}
 
 		p.rollback()
 		expr = &IdentityExpr{
 			Object: obj,
+			Module: module,
 		}
 	case token.INTLIT:
 		// 整数
@@ -167,12 +397,32 @@ func (p *Parser) implExpr() (expr Expr) {
 		return &BlockExpr{
 			Toks: p.block(),
 		}
+	case token.LBRACK:
+		// 列表
+		return p.list()
+	case token.SPAWN:
+		// 并发任务
+		return p.spawn()
 	}
 
 	p.next()
 	return
 }
 
+// 启动并发任务: spawn f(x) 或者 spawn p.run(x)
+func (p *Parser) spawn() *SpawnExpr {
+	p.require(token.SPAWN, true)
+
+	call := p.implExpr()
+	switch call.(type) {
+	case *CallFnExpr, *MethodCallExpr:
+		return &SpawnExpr{
+			Call: call,
+		}
+	}
+	panic(p.error(SyntaxError, "spawn 只能启动方法调用"))
+}
+
 // 表达式结尾符
 func (p *Parser) endExpr() bool {
 	if p.IsEnd() {
@@ -272,8 +522,9 @@ func operator(tokType token.Type) int {
 	return NOP
 }
 
-func makeBinary(left Expr, op int, right Expr) *BinaryExpr {
+func makeBinary(left Expr, op int, right Expr, pos token.Pos) *BinaryExpr {
 	return &BinaryExpr{
+		At:    At{pos},
 		Left:  left,
 		Op:    op,
 		Right: right,
@@ -297,19 +548,20 @@ func (p *Parser) parseExpr(currentPriority int) Expr {
 	// 1 [+] 2 + 3
 	op := operator(p.Token().Type)
 	for priority(op) > currentPriority {
+		opPos := p.Token().Pos
 		p.next()
 
 		// 1 + [2 + 3]
 		right = p.parseExpr(priority(op))
 		if right == nil {
-			panic("表达式错误")
+			panic(p.error(SyntaxError, "表达式错误"))
 		}
 
 		//     node
 		//    /    \
 		// left   right
 
-		left = makeBinary(left, op, right)
+		left = makeBinary(left, op, right, opPos)
 		op = operator(p.Token().Type)
 
 		if p.endExpr() {
@@ -1,21 +1,56 @@
 package ast
 
-import "my-lang/token"
+import (
+	"my-lang/token"
+	"strings"
+)
 
-// 定义方法参数
-func (p *Parser) defFnArgs() (args []string) {
+// 定义方法参数: (a, b = 1, ...c)
+func (p *Parser) defFnArgs() (args []Arg) {
 
 	// ([a, b, c])
 	for p.Token().Type != token.RPAREN {
+		if len(args) > 0 && args[len(args)-1].Rest {
+			panic(p.error(SyntaxError, "...%s 必须是最后一个参数", args[len(args)-1].Name))
+		}
+
+		var arg Arg
+
+		// ([...]c)
+		if p.Token().Type == token.ELLIPSIS {
+			p.next()
+			arg.Rest = true
+		}
+
 		// ([a], ...)
-		name := p.require(token.IDENTITY, true)
-		args = append(args, name)
+		arg.Name = p.require(token.IDENTITY, true)
+		for _, exist := range args {
+			if exist.Name == arg.Name {
+				panic(p.error(SyntaxError, "参数 %s 重复定义", arg.Name))
+			}
+		}
+
+		// (a[: int])
+		arg.Type = p.typeAnnotation()
+
+		// (b [= 1])
+		if p.Token().Type == token.ASSIGN && !arg.Rest {
+			p.next()
+			start := p.Offset
+			arg.Default = p.parseExpr(0)
+			arg.DefaultLit = token.Join(p.Tokens[start:p.Offset])
+		} else if !arg.Rest && len(args) > 0 && args[len(args)-1].Default != nil {
+			panic(p.error(SyntaxError, "参数 %s 没有默认值, 不能放在有默认值的参数之后", arg.Name))
+		}
+		args = append(args, arg)
 
 		// (a[,] ...)
 		// 如果是逗号，则说明后面还有参数定义
 		if p.Token().Type == token.COMMA {
 			p.next()
-			p.require(token.IDENTITY, false)
+			if p.Token().Type != token.ELLIPSIS {
+				p.require(token.IDENTITY, false)
+			}
 		}
 	}
 	p.require(token.RPAREN, true)
@@ -23,25 +58,184 @@ func (p *Parser) defFnArgs() (args []string) {
 	return
 }
 
+// 类型注解: [: int], 没有注解则返回空
+func (p *Parser) typeAnnotation() string {
+	if p.Token().Type != token.COLON {
+		return ""
+	}
+	p.next()
+	return p.require(token.IDENTITY, true)
+}
+
+// 定义方法签名: (a: int, b = 1)[: float] =
+func (p *Parser) defFnSignature() (args []Arg, result string) {
+	p.require(token.LPAREN, true)
+	args = p.defFnArgs()
+	result = p.typeAnnotation()
+	p.require(token.ASSIGN, true)
+	return
+}
+
 // 定义方法
-func (p *Parser) defFn(name string, args []string) {
+func (p *Parser) defFn(name string, pos token.Pos, args []Arg, result string) {
+	fn := p.newFn(name, pos, args, result)
+	p.Objects.Add(fn)
+
+	fn.ParentObjs = p.Objects.Slice(0, p.Objects.Len())
+}
+
+// 解析方法体
+func (p *Parser) newFn(name string, pos token.Pos, args []Arg, result string) *Function {
 
 	var body []token.Token
 	if p.Token().Type == token.LBRACE {
 		body = p.block()
 	} else {
 		// 偷偷加个return
-		body = append(body, token.EmptyToken(token.RETURN))
+		ret := token.EmptyToken(token.RETURN)
+		ret.Pos = p.Token().Pos
+		body = append(body, ret)
 		body = append(body, p.line()...)
 		// 行格式不用加载下一个 token (避免 EOF)
 	}
 
-	fn := &Function{
-		Name: name,
-		Args: args,
-		Body: body,
+	return &Function{
+		At:        At{pos},
+		Name:      name,
+		Args:      args,
+		Result:    result,
+		Body:      body,
+		Generator: hasYield(body),
 	}
-	p.Objects.Add(fn)
+}
 
-	fn.ParentObjs = p.Objects.Slice(0, p.Objects.Len())
+// 方法体内是否含有 yield, 嵌套定义的方法与类型方法的方法体不计入
+func hasYield(body []token.Token) bool {
+	start := true // 是否在语句开头
+	for i := 0; i < len(body); i++ {
+		switch {
+		case body[i].Type == token.YIELD:
+			return true
+		case start && body[i].Type == token.IMPL:
+			// impl Point { ... }
+			i = skipBlock(body, i)
+		case start && body[i].Type == token.IDENTITY && isFnDefAt(body, i+1):
+			// f(...) = ...
+			i = skipFnDef(body, i+1)
+		}
+		if i < len(body) {
+			switch body[i].Type {
+			case token.LINEBREAK, token.SEMICOLON, token.LBRACE, token.RBRACE:
+				start = true
+			default:
+				start = false
+			}
+		}
+	}
+	return false
+}
+
+// toks[i] 是否是方法定义的 (
+func isFnDefAt(toks []token.Token, i int) bool {
+	if i >= len(toks) || toks[i].Type != token.LPAREN {
+		return false
+	}
+	p := &Parser{Tokens: toks, Offset: i}
+	return p.isFnDef()
+}
+
+// 从 toks[i] 开始跳过到第一个 { 匹配的 }, 返回 } 的下标
+func skipBlock(toks []token.Token, i int) int {
+	level := 0
+	for ; i < len(toks); i++ {
+		switch toks[i].Type {
+		case token.LBRACE:
+			level += 1
+		case token.RBRACE:
+			level -= 1
+			if level == 0 {
+				return i
+			}
+		}
+	}
+	return i
+}
+
+// 从参数的 ( 开始跳过方法定义, 返回方法体最后一个 token 的下标
+func skipFnDef(toks []token.Token, i int) int {
+	// (a = 1, ...)
+	level := 0
+	for ; i < len(toks); i++ {
+		if toks[i].Type == token.LPAREN {
+			level += 1
+		}
+		if toks[i].Type == token.RPAREN {
+			level -= 1
+			if level == 0 {
+				break
+			}
+		}
+	}
+
+	// [: int] =
+	for i < len(toks) && toks[i].Type != token.ASSIGN {
+		i += 1
+	}
+	i += 1
+
+	// = { ... }
+	if i < len(toks) && toks[i].Type == token.LBRACE {
+		return skipBlock(toks, i)
+	}
+
+	// = ...: 到行尾或者没有匹配的 } 为止
+	level = 0
+	for ; i < len(toks); i++ {
+		switch toks[i].Type {
+		case token.LINEBREAK:
+			return i
+		case token.LBRACE:
+			level += 1
+		case token.RBRACE:
+			if level == 0 {
+				return i - 1
+			}
+			level -= 1
+		}
+	}
+	return i
+}
+
+// Signature 方法签名: f(a, b = 1, ...c)
+func (fn *Function) Signature() string {
+	var sb strings.Builder
+	if fn.Owner != nil {
+		sb.WriteString(fn.Owner.Name)
+		sb.WriteString(".")
+	}
+	sb.WriteString(fn.Name)
+	sb.WriteString("(")
+	for i, arg := range fn.Args {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		if arg.Rest {
+			sb.WriteString("...")
+		}
+		sb.WriteString(arg.Name)
+		if arg.Type != "" {
+			sb.WriteString(": ")
+			sb.WriteString(arg.Type)
+		}
+		if arg.Default != nil {
+			sb.WriteString(" = ")
+			sb.WriteString(arg.DefaultLit)
+		}
+	}
+	sb.WriteString(")")
+	if fn.Result != "" {
+		sb.WriteString(": ")
+		sb.WriteString(fn.Result)
+	}
+	return sb.String()
 }
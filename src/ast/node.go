@@ -1,7 +1,11 @@
 package ast
 
+import "my-lang/token"
+
 type (
-	Node interface{}
+	Node interface {
+		Pos() token.Pos
+	}
 
 	// Expr Expression
 	Expr interface {
@@ -14,4 +18,25 @@ type (
 		Node
 		stmt()
 	}
+
+	// At 节点在源码中的位置
+	At struct {
+		Position token.Pos
+	}
 )
+
+func (at *At) Pos() token.Pos {
+	return at.Position
+}
+
+// 设置节点位置 (已经有位置的节点不会被覆盖)
+func setPos(node Node, pos token.Pos) {
+	if node == nil || node.Pos().IsValid() {
+		return
+	}
+	node.(interface{ at() *At }).at().Position = pos
+}
+
+func (at *At) at() *At {
+	return at
+}
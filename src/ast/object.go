@@ -1,8 +1,10 @@
 package ast
 
 import (
+	"context"
 	"my-lang/token"
 	"reflect"
+	"sync"
 )
 
 type (
@@ -11,33 +13,125 @@ type (
 	}
 
 	// ObjectList 对象表 (指针数组指针)
+	// 多个任务可能同时查找与插入同一个对象表, 读写都需要加锁
 	ObjectList struct {
 		Objects *[]Object
+		mu      sync.RWMutex
 	}
 
 	// Variable 变量
+	// 变量可能被多个任务共享, 运行时通过 Load/Store 读写
 	Variable struct {
 		Name  string
 		Value interface{}
+		mu    sync.RWMutex
 	}
 
 	// Function 方法
 	Function struct {
+		At         // 方法名的位置
 		Name       string
-		Args       []string      // 局部变量
+		Args       []Arg         // 局部变量
+		Result     string        // 返回值类型注解 (没有则为空)
 		Body       []token.Token // 内容
 		ParentObjs *ObjectList   // 父对象表 (截取后的)
+		Owner      *Record       // 所属类型 (仅方法)
+		Generator  bool          // 方法体内含有 yield, 调用时返回生成器
 	}
 
-	// Channel 通道 (建立两个对象表的联系)
-	Channel struct {
+	// Arg 方法参数
+	Arg struct {
+		Name       string
+		Type       string // 类型注解 (没有则为空)
+		Default    Expr   // 默认值 (没有默认值则为 nil)
+		DefaultLit string // 默认值的源码
+		Rest       bool   // ...rest 收集剩余的位置参数
+	}
+
+	// Builtin 内置方法
+	Builtin struct {
+		Name string
+		// ctx 取消时阻塞的操作 (例如 recv) 立即结束
+		Fn     func(ctx context.Context, args []interface{}) interface{}
+		Result Type // 返回值类型 (INVALID 表示不确定)
+	}
+
+	// Record 结构体类型
+	Record struct {
+		At      // 类型名的位置
+		Name    string
+		Fields  []string             // 字段名 (按声明顺序, 包含父类型的字段)
+		Parent  *Record              // 父类型
+		Methods map[string]*Function // 方法表
+		mu      sync.RWMutex         // 任务运行时可能有新的 impl
+	}
+
+	// Module 模块 (已执行完毕的源文件)
+	Module struct {
+		Name    string
+		Path    string      // 源文件绝对路径
+		Objects *ObjectList // 模块顶层对象表
+	}
+
+	// ScopeLink 作用域链接 (建立两个对象表的联系)
+	ScopeLink struct {
 		Previous *ObjectList // 上一层对象表
 	}
 )
 
-func (*Variable) obj() {}
-func (*Function) obj() {}
-func (*Channel) obj()  {}
+func (*Variable) obj()  {}
+func (*Function) obj()  {}
+func (*Builtin) obj()   {}
+func (*Record) obj()    {}
+func (*Module) obj()    {}
+func (*ScopeLink) obj() {}
+
+// Load 读取变量的值
+func (v *Variable) Load() interface{} {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return v.Value
+}
+
+// Store 修改变量的值
+func (v *Variable) Store(value interface{}) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.Value = value
+}
+
+// FieldIndex 获取字段下标，不存在则返回 -1
+func (r *Record) FieldIndex(name string) int {
+	for i, field := range r.Fields {
+		if field == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// FindMethod 查找方法，如果没有找到，则往父类型继续找
+func (r *Record) FindMethod(name string) *Function {
+	for record := r; record != nil; record = record.Parent {
+		if fn := record.method(name); fn != nil {
+			return fn
+		}
+	}
+	return nil
+}
+
+func (r *Record) method(name string) *Function {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.Methods[name]
+}
+
+// AddMethod 定义方法
+func (r *Record) AddMethod(fn *Function) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Methods[fn.Name] = fn
+}
 
 // 获取对象名称
 func getObjectField(obj Object, field string) (reflect.Value, bool) {
@@ -50,12 +144,14 @@ func getObjectField(obj Object, field string) (reflect.Value, bool) {
 
 // 往对象表里插入新对象
 func (objs *ObjectList) addBatch(object []Object) {
+	objs.mu.Lock()
+	defer objs.mu.Unlock()
 	*objs.Objects = append(*objs.Objects, object...)
 }
 
 func NewObjectList(previous *ObjectList) *ObjectList {
 	objs := make([]Object, 1)
-	objs[0] = &Channel{
+	objs[0] = &ScopeLink{
 		Previous: previous,
 	}
 	return &ObjectList{
@@ -64,51 +160,93 @@ func NewObjectList(previous *ObjectList) *ObjectList {
 }
 
 func (objs *ObjectList) Len() int {
+	objs.mu.RLock()
+	defer objs.mu.RUnlock()
 	return len(*objs.Objects)
 }
 
 // FindObject 倒序查找当前对象表，如果没有找到对象，则往上一层继续找
 func (objs *ObjectList) FindObject(name string) Object {
+	if obj := objs.findLocal(name); obj != nil {
+		return obj
+	}
+
+	// 如果没有找到的话就往上一层查找
+	link := objs.Get(0).(*ScopeLink)
+	if link.Previous != nil {
+		return link.Previous.FindObject(name)
+	}
+
+	return nil
+}
+
+// Previous 上一层对象表 (最外层为 nil)
+func (objs *ObjectList) Previous() *ObjectList {
+	return objs.Get(0).(*ScopeLink).Previous
+}
+
+// Variables 当前对象表里的变量 (按插入的顺序, 不含上一层)
+func (objs *ObjectList) Variables() []*Variable {
+	objs.mu.RLock()
+	defer objs.mu.RUnlock()
+
+	vars := make([]*Variable, 0)
+	for _, obj := range (*objs.Objects)[1:] {
+		if v, ok := obj.(*Variable); ok {
+			vars = append(vars, v)
+		}
+	}
+	return vars
+}
+
+// 只在当前对象表里查找
+func (objs *ObjectList) findLocal(name string) Object {
+	objs.mu.RLock()
+	defer objs.mu.RUnlock()
 
 	// 从后往前遍历
-	for i := objs.Len() - 1; i > 0; i-- {
+	for i := len(*objs.Objects) - 1; i > 0; i-- {
+		obj := (*objs.Objects)[i]
+
 		// 根据对象类型来判断
-		fieldName, isExsit := getObjectField(objs.Get(i), "Name")
+		fieldName, isExsit := getObjectField(obj, "Name")
 		if !isExsit {
 			// 如果不存在 Name 字段，则跳过检查
 			continue
 		}
 
 		if name == fieldName.String() {
-			return objs.Get(i)
+			return obj
 		}
 	}
-
-	// 如果没有找到的话就往上一层查找
-	channel := objs.Get(0).(*Channel)
-	if channel.Previous != nil {
-		return channel.Previous.FindObject(name)
-	}
-
 	return nil
 }
 
 // Get 获取对象表指定下标的对象
 func (objs *ObjectList) Get(index int) Object {
+	objs.mu.RLock()
+	defer objs.mu.RUnlock()
 	return (*objs.Objects)[index]
 }
 
 // Add 往对象表里插入新对象
 func (objs *ObjectList) Add(object Object) {
+	objs.mu.Lock()
+	defer objs.mu.Unlock()
 	*objs.Objects = append(*objs.Objects, object)
 }
 
 func (objs *ObjectList) Clear() {
+	objs.mu.Lock()
+	defer objs.mu.Unlock()
 	*objs.Objects = (*objs.Objects)[:1]
 }
 
+// Slice 截取对象表, 截取后的对象表不会看到原对象表之后插入的对象
 func (objs *ObjectList) Slice(start int, end int) *ObjectList {
-	sliceObjs := (*objs.Objects)[start:end]
+	objs.mu.RLock()
+	defer objs.mu.RUnlock()
+	sliceObjs := (*objs.Objects)[start:end:end]
 	return &ObjectList{
 		Objects: &sliceObjs,
 	}
@@ -1,9 +1,6 @@
 package ast
 
-import (
-	"fmt"
-	"my-lang/token"
-)
+import "my-lang/token"
 
 type Parser struct {
 	Tokens []token.Token // 定位 token
@@ -13,7 +10,8 @@ type Parser struct {
 }
 
 func NewParser(toks []token.Token, objs *ObjectList) *Parser {
-	toks = append(toks, token.EmptyToken(token.EOF))
+	// 限制容量, 追加 EOF 时总是复制, 避免多个任务同时写同一个方法体
+	toks = append(toks[:len(toks):len(toks)], token.EmptyToken(token.EOF))
 	parser := Parser{
 		Tokens: toks,
 		Offset: 0,
@@ -25,6 +23,14 @@ func NewParser(toks []token.Token, objs *ObjectList) *Parser {
 }
 
 func (p *Parser) Token() token.Token {
+	if p.Offset >= len(p.Tokens) {
+		// 不完整的源码 (例如缺少右括号) 会越过结尾的 EOF
+		err := NewError(SyntaxError, "源码意外结束")
+		if n := len(p.Tokens); n > 1 {
+			err.Pos = p.Tokens[n-2].Pos
+		}
+		panic(err)
+	}
 	return p.Tokens[p.Offset]
 }
 
@@ -38,10 +44,17 @@ func (p *Parser) rollback() {
 	p.Offset -= 1
 }
 
+// 语法分析时的错误, 位置是当前的 token
+func (p *Parser) error(kind string, format string, args ...interface{}) *Error {
+	err := NewError(kind, format, args...)
+	err.Pos = p.Token().Pos
+	return err
+}
+
 // 检查传入的 token, 不符合需要的 token 就 panic
 func (p *Parser) require(tokType token.Type, autoNext bool) string {
 	if p.Token().Type != tokType {
-		panic(fmt.Sprintf("错误: 需要的 token: %s, 实际提供的 token: %s", token.TypeString(tokType), token.TypeString(p.Token().Type)))
+		panic(p.error(SyntaxError, "需要的 token: %s, 实际提供的 token: %s", token.TypeString(tokType), token.TypeString(p.Token().Type)))
 	}
 	str := p.Token().Lit
 	if autoNext {
@@ -50,6 +63,28 @@ func (p *Parser) require(tokType token.Type, autoNext bool) string {
 	return str
 }
 
+// 从当前的 ( 开始向后查看, 匹配的 ) 之后是否紧跟 = (或者返回值类型注解)
+func (p *Parser) isFnDef() bool {
+	level := 0
+	for i := p.Offset; i < len(p.Tokens); i++ {
+		switch p.Tokens[i].Type {
+		case token.LPAREN:
+			level += 1
+		case token.RPAREN:
+			level -= 1
+			if level == 0 {
+				// (...) = 或者 (...): int =
+				next := p.Tokens[i+1:]
+				return (len(next) > 0 && next[0].Type == token.ASSIGN) ||
+					(len(next) > 2 && next[0].Type == token.COLON && next[1].Type == token.IDENTITY && next[2].Type == token.ASSIGN)
+			}
+		case token.LINEBREAK, token.EOF:
+			return false
+		}
+	}
+	return false
+}
+
 func (p *Parser) IsEnd() bool {
 	return p.Offset >= len(p.Tokens) || p.Token().Type == token.EOF
 }
@@ -61,6 +96,17 @@ func (p *Parser) ParseStmt() Stmt {
 		return nil
 	}
 
+	// 语句的位置是第一个 token 的位置
+	pos := p.Token().Pos
+	stmt := p.parseStmt()
+	if stmt != nil {
+		setPos(stmt, pos)
+	}
+	return stmt
+}
+
+func (p *Parser) parseStmt() Stmt {
+
 	switch p.Token().Type {
 	case token.SEMICOLON, token.LINEBREAK:
 		// 跳过
@@ -73,26 +119,34 @@ func (p *Parser) ParseStmt() Stmt {
 		startOffset := p.Offset
 
 		p.next()
-		if p.Token().Type == token.ASSIGN {
+		if name == "test" && p.Token().Type == token.STRINGLIT {
+			// [test "name" { ... }]
+			p.Offset = startOffset
+			return p.parseTestStatement()
+		} else if p.Token().Type == token.ASSIGN {
 			// [a = ...]
 			// 变量的定义与赋值
 			p.next()
 			return p.parseAssignStatement(name)
+		} else if p.Token().Type == token.COLON {
+			// [a: int = ...]
+			typ := p.typeAnnotation()
+			p.require(token.ASSIGN, true)
+			stmt := p.parseAssignStatement(name)
+			stmt.Type = typ
+			return stmt
+		} else if p.Token().Type == token.LPAREN && p.isFnDef() {
+			// [a(...) = ...]
+			args, result := p.defFnSignature()
+			p.defFn(name, p.Tokens[startOffset].Pos, args, result)
 		} else if p.Token().Type == token.LPAREN {
-			// [a(...)]
-			p.next()
-			args := p.defFnArgs()
-
-			if p.Token().Type == token.ASSIGN {
-				// [a(...) = ...]
-				p.next()
-				p.defFn(name, args)
-			} else {
-				// [a(...) + 1]
-				p.Offset = startOffset
-				return p.parseExprStatement()
-			}
-
+			// [a(...) + 1]
+			p.Offset = startOffset
+			return p.parseFieldStatement()
+		} else if p.Token().Type == token.DOT || p.Token().Type == token.LBRACK {
+			// [a.b = ...], [a[0] = ...]
+			p.Offset = startOffset
+			return p.parseFieldStatement()
 		} else {
 			// [a + 1]
 			// 表达式
@@ -111,6 +165,30 @@ func (p *Parser) ParseStmt() Stmt {
 	case token.FOR:
 		// 循环语句
 		return p.parseForStatement()
+	case token.TYPE:
+		// 结构体类型定义
+		p.defType()
+	case token.IMPL:
+		// 类型方法定义
+		p.defImpl()
+	case token.TRY:
+		// 异常处理
+		return p.parseTryStatement()
+	case token.THROW:
+		// 抛出异常
+		return p.parseThrowStatement()
+	case token.YIELD:
+		// 生成器产出值
+		return p.parseYieldStatement()
+	case token.SELECT:
+		// 等待通道操作
+		return p.parseSelectStatement()
+	case token.IMPORT:
+		// 导入模块
+		return p.parseImportStatement()
+	case token.FROM:
+		// 从模块导入指定对象
+		return p.parseFromImportStatement()
 	default:
 		// 表达式
 		return p.parseExprStatement()
@@ -0,0 +1,148 @@
+package ast
+
+import "my-lang/token"
+
+// 跳过换行符
+func (p *Parser) skipLineBreak() {
+	for p.Token().Type == token.LINEBREAK {
+		p.next()
+	}
+}
+
+// 定义结构体类型
+func (p *Parser) defType() {
+
+	p.require(token.TYPE, true)
+
+	// type [Point] { ... }
+	pos := p.Token().Pos
+	name := p.require(token.IDENTITY, true)
+
+	// type Point3 [: Point] { ... }
+	var parent *Record
+	var fields []string
+	if p.Token().Type == token.COLON {
+		p.next()
+		parentName := p.require(token.IDENTITY, true)
+		obj, ok := p.Objects.FindObject(parentName).(*Record)
+		if !ok {
+			panic(p.error(TypeError, "%s 不是类型, 无法被 %s 继承", parentName, name))
+		}
+		parent = obj
+		fields = append(fields, parent.Fields...)
+	}
+
+	// type Point {[x, y]}
+	p.require(token.LBRACE, true)
+	p.skipLineBreak()
+	for p.Token().Type != token.RBRACE {
+		field := p.require(token.IDENTITY, true)
+		for _, exist := range fields {
+			if exist == field {
+				panic(p.error(SyntaxError, "类型 %s 的字段 %s 重复定义", name, field))
+			}
+		}
+		fields = append(fields, field)
+
+		// 字段之间用逗号或者换行分隔
+		if p.Token().Type == token.COMMA {
+			p.next()
+		}
+		p.skipLineBreak()
+	}
+	p.require(token.RBRACE, true)
+
+	p.Objects.Add(&Record{
+		At:      At{pos},
+		Name:    name,
+		Fields:  fields,
+		Parent:  parent,
+		Methods: make(map[string]*Function),
+	})
+}
+
+// 为类型定义方法
+func (p *Parser) defImpl() {
+
+	p.require(token.IMPL, true)
+
+	// impl [Point] { ... }
+	name := p.require(token.IDENTITY, true)
+	record, ok := p.Objects.FindObject(name).(*Record)
+	if !ok {
+		panic(p.error(TypeError, "%s 不是类型, 无法定义方法", name))
+	}
+
+	// impl Point {[norm() = ...]}
+	p.require(token.LBRACE, true)
+	p.skipLineBreak()
+	for p.Token().Type != token.RBRACE {
+		fnPos := p.Token().Pos
+		fnName := p.require(token.IDENTITY, true)
+		args, result := p.defFnSignature()
+
+		fn := p.newFn(fnName, fnPos, args, result)
+		fn.Owner = record
+		fn.ParentObjs = p.Objects.Slice(0, p.Objects.Len())
+		record.AddMethod(fn)
+
+		p.skipLineBreak()
+	}
+	p.require(token.RBRACE, true)
+}
+
+// 构造结构体: Point(1, 2)
+func (p *Parser) newRecord(record *Record) *NewRecordExpr {
+
+	fields := p.positionalParams(record.Name)
+
+	if len(fields) != len(record.Fields) {
+		panic(p.error(TypeError, "类型 %s 需要 %d 个字段, 实际提供 %d 个", record.Name, len(record.Fields), len(fields)))
+	}
+
+	return &NewRecordExpr{
+		Record: record,
+		Fields: fields,
+	}
+}
+
+// 构造结构体: Point{x: 1, y: 2}
+func (p *Parser) newRecordByName(record *Record) *NewRecordExpr {
+
+	fields := make([]Expr, len(record.Fields))
+
+	p.require(token.LBRACE, true)
+	p.skipLineBreak()
+	for p.Token().Type != token.RBRACE {
+		// {[x]: 1}
+		name := p.require(token.IDENTITY, true)
+		i := record.FieldIndex(name)
+		if i < 0 {
+			panic(p.error(AttributeError, "类型 %s 没有字段 %s", record.Name, name))
+		}
+		if fields[i] != nil {
+			panic(p.error(SyntaxError, "字段 %s 重复赋值", name))
+		}
+
+		// {x: [1]}
+		p.require(token.COLON, true)
+		fields[i] = p.parseExpr(0)
+
+		if p.Token().Type == token.COMMA {
+			p.next()
+		}
+		p.skipLineBreak()
+	}
+	p.require(token.RBRACE, true)
+
+	for i, field := range fields {
+		if field == nil {
+			panic(p.error(TypeError, "构造类型 %s 时缺少字段 %s", record.Name, record.Fields[i]))
+		}
+	}
+
+	return &NewRecordExpr{
+		Record: record,
+		Fields: fields,
+	}
+}
@@ -1,35 +1,54 @@
 package ast
 
-import (
-	"fmt"
-	"my-lang/token"
-)
+import "my-lang/token"
 
 type (
 
 	// ExprStmt 单表达式的语句
 	ExprStmt struct {
-		Expr
+		At
+		Expr Expr
 	}
 
 	// AssignStmt 赋值语句
 	AssignStmt struct {
+		At
 		Name  string
+		Type  string // 类型注解 (没有则为空)
+		Value Expr
+	}
+
+	// FieldAssignStmt 成员赋值语句
+	FieldAssignStmt struct {
+		At
+		X     Expr
+		Name  string
+		Value Expr
+	}
+
+	// IndexAssignStmt 下标赋值语句
+	IndexAssignStmt struct {
+		At
+		X     Expr
+		Index Expr
 		Value Expr
 	}
 
 	// PrintStmt 打印 (暂时) deprecated
 	PrintStmt struct {
-		Expr
+		At
+		Expr Expr
 	}
 
 	// ReturnStmt 返回语句
 	ReturnStmt struct {
-		Expr
+		At
+		Expr Expr
 	}
 
 	// IfStmt 选择语句
 	IfStmt struct {
+		At
 		Cond      Expr
 		TrueBody  []token.Token
 		FalseBody []token.Token
@@ -37,17 +56,79 @@ type (
 
 	// ForStmt 循环语句
 	ForStmt struct {
+		At
 		Cond Expr
+		Name string // for [x] in xs (条件循环为空)
+		Iter Expr   // for x in [xs] (条件循环为 nil)
+		Body []token.Token
+	}
+
+	// TryStmt 异常处理语句
+	TryStmt struct {
+		At
+		Body        []token.Token
+		CatchName   string        // catch [e] { ... }
+		CatchBody   []token.Token // 没有 catch 时为 nil
+		FinallyBody []token.Token // 没有 finally 时为 nil
+	}
+
+	// ThrowStmt 抛出异常
+	ThrowStmt struct {
+		At
+		Expr Expr
+	}
+
+	// YieldStmt 生成器产出值
+	YieldStmt struct {
+		At
+		Expr Expr
+	}
+
+	// SelectStmt 同时等待多个通道操作
+	SelectStmt struct {
+		At
+		Cases   []SelectCase
+		Default []token.Token // else { ... } (没有则为 nil)
+	}
+
+	// SelectCase select 的分支: [v =] recv(c) { ... } 或者 send(c, x) { ... }
+	SelectCase struct {
+		At
+		Name string // 接收到的值绑定的变量名 (可以为空)
+		Call *CallBuiltinExpr
+		Body []token.Token
+	}
+
+	// ImportStmt 导入语句
+	ImportStmt struct {
+		At
+		Path  string   // 模块路径或者模块名
+		Alias string   // import mod as [m]
+		Names []string // from mod import [a, b]
+	}
+
+	// TestStmt 测试块: test "name" { ... }, 只在运行测试时执行
+	TestStmt struct {
+		At
+		Name string
 		Body []token.Token
 	}
 )
 
-func (*ExprStmt) stmt()   {}
-func (*AssignStmt) stmt() {}
-func (*PrintStmt) stmt()  {}
-func (*ReturnStmt) stmt() {}
-func (*IfStmt) stmt()     {}
-func (*ForStmt) stmt()    {}
+func (*ExprStmt) stmt()        {}
+func (*AssignStmt) stmt()      {}
+func (*FieldAssignStmt) stmt() {}
+func (*IndexAssignStmt) stmt() {}
+func (*PrintStmt) stmt()       {}
+func (*ReturnStmt) stmt()      {}
+func (*IfStmt) stmt()          {}
+func (*ForStmt) stmt()         {}
+func (*TryStmt) stmt()         {}
+func (*ThrowStmt) stmt()       {}
+func (*YieldStmt) stmt()       {}
+func (*SelectStmt) stmt()      {}
+func (*ImportStmt) stmt()      {}
+func (*TestStmt) stmt()        {}
 
 // 获取当前token的identity
 func (p *Parser) identity() (obj Object) {
@@ -59,14 +140,45 @@ func (p *Parser) identity() (obj Object) {
 		return
 	}
 
-	panic(fmt.Sprintf("错误: 表达式未知的 token: %s", token.TypeString(p.Token().Type)))
+	panic(p.error(SyntaxError, "表达式未知的 token: %s", token.TypeString(p.Token().Type)))
 }
 
 // 表达式语句 (语句里只包含表达式)
 func (p *Parser) parseExprStatement() *ExprStmt {
 	expr := p.parseExpr(0)
 	return &ExprStmt{
-		expr,
+		Expr: expr,
+	}
+}
+
+// 成员赋值语句, 下标赋值语句或者表达式语句
+func (p *Parser) parseFieldStatement() Stmt {
+	expr := p.parseExpr(0)
+
+	if p.Token().Type == token.ASSIGN {
+		switch target := expr.(type) {
+		case *FieldExpr:
+			// [a.b = ...]
+			p.next()
+			return &FieldAssignStmt{
+				X:     target.X,
+				Name:  target.Name,
+				Value: p.parseExpr(0),
+			}
+		case *IndexExpr:
+			// [a[0] = ...]
+			p.next()
+			return &IndexAssignStmt{
+				X:     target.X,
+				Index: target.Index,
+				Value: p.parseExpr(0),
+			}
+		}
+	}
+
+	// [a.b + 1]
+	return &ExprStmt{
+		Expr: expr,
 	}
 }
 
@@ -131,6 +243,19 @@ func (p *Parser) parseIfStatement() *IfStmt {
 func (p *Parser) parseForStatement() *ForStmt {
 	p.require(token.FOR, true)
 
+	// for x in xs { ... }
+	if p.Token().Type == token.IDENTITY && p.Tokens[p.Offset+1].Type == token.IN {
+		name := p.Token().Lit
+		p.next()
+		p.next()
+		iter := p.parseExpr(0)
+		return &ForStmt{
+			Name: name,
+			Iter: iter,
+			Body: p.block(),
+		}
+	}
+
 	// 条件
 	cond := p.parseExpr(0)
 
@@ -142,3 +267,188 @@ func (p *Parser) parseForStatement() *ForStmt {
 		Body: body,
 	}
 }
+
+// 异常处理语句: try { ... } catch e { ... } finally { ... }
+func (p *Parser) parseTryStatement() *TryStmt {
+
+	p.require(token.TRY, true)
+
+	stmt := &TryStmt{
+		Body: p.block(),
+	}
+
+	if p.Token().Type == token.CATCH {
+		p.next()
+		if p.Token().Type == token.IDENTITY {
+			stmt.CatchName = p.require(token.IDENTITY, true)
+		}
+		stmt.CatchBody = p.block()
+		if stmt.CatchBody == nil {
+			stmt.CatchBody = []token.Token{}
+		}
+	}
+
+	if p.Token().Type == token.FINALLY {
+		p.next()
+		stmt.FinallyBody = p.block()
+		if stmt.FinallyBody == nil {
+			stmt.FinallyBody = []token.Token{}
+		}
+	}
+
+	if stmt.CatchBody == nil && stmt.FinallyBody == nil {
+		panic(p.error(SyntaxError, "try 语句至少需要 catch 或者 finally"))
+	}
+
+	return stmt
+}
+
+// 抛出异常
+func (p *Parser) parseThrowStatement() *ThrowStmt {
+
+	p.require(token.THROW, true)
+
+	expr := p.parseExpr(0)
+	return &ThrowStmt{
+		Expr: expr,
+	}
+}
+
+// 生成器产出值: yield x
+func (p *Parser) parseYieldStatement() *YieldStmt {
+
+	p.require(token.YIELD, true)
+
+	expr := p.parseExpr(0)
+	return &YieldStmt{
+		Expr: expr,
+	}
+}
+
+// 同时等待多个通道操作
+//
+//	select {
+//	    v = recv(c1) { ... }
+//	    send(c2, x) { ... }
+//	    else { ... }
+//	}
+func (p *Parser) parseSelectStatement() *SelectStmt {
+
+	p.require(token.SELECT, true)
+	p.require(token.LBRACE, true)
+	p.skipLineBreak()
+
+	stmt := &SelectStmt{}
+	for p.Token().Type != token.RBRACE {
+		if p.Token().Type == token.ELSE {
+			// else { ... }
+			if stmt.Default != nil {
+				panic(p.error(SyntaxError, "select 只能有一个 else 分支"))
+			}
+			p.next()
+			stmt.Default = p.block()
+			if stmt.Default == nil {
+				stmt.Default = []token.Token{}
+			}
+			p.skipLineBreak()
+			continue
+		}
+
+		selectCase := SelectCase{
+			At: At{p.Token().Pos},
+		}
+
+		// [v =] recv(c)
+		if p.Token().Type == token.IDENTITY && p.Tokens[p.Offset+1].Type == token.ASSIGN {
+			selectCase.Name = p.Token().Lit
+			p.next()
+			p.next()
+		}
+
+		call, ok := p.parseExpr(0).(*CallBuiltinExpr)
+		if !ok || (call.Builtin.Name != "recv" && call.Builtin.Name != "send") {
+			panic(p.error(SyntaxError, "select 的分支必须是 recv(...) 或者 send(...)"))
+		}
+		if selectCase.Name != "" && call.Builtin.Name != "recv" {
+			panic(p.error(SyntaxError, "只有 recv 分支可以绑定变量"))
+		}
+		selectCase.Call = call
+		selectCase.Body = p.block()
+
+		stmt.Cases = append(stmt.Cases, selectCase)
+		p.skipLineBreak()
+	}
+
+	// 没有通道操作的 select 会永远阻塞
+	if len(stmt.Cases) == 0 {
+		panic(p.error(SyntaxError, "select 至少需要一个 recv(...) 或者 send(...) 分支"))
+	}
+	p.require(token.RBRACE, true)
+
+	return stmt
+}
+
+// 测试块: test "name" { ... }, test 不是关键字, 只在后面是字符串时有这个含义
+func (p *Parser) parseTestStatement() *TestStmt {
+
+	p.require(token.IDENTITY, true)
+
+	name := p.require(token.STRINGLIT, true)
+	body := p.block()
+	if body == nil {
+		body = []token.Token{}
+	}
+
+	return &TestStmt{
+		Name: name,
+		Body: body,
+	}
+}
+
+// 模块路径: "path/to/mod.m" 或者 mod
+func (p *Parser) modulePath() string {
+	switch p.Token().Type {
+	case token.STRINGLIT, token.IDENTITY:
+		path := p.Token().Lit
+		p.next()
+		return path
+	}
+	panic(p.error(ImportError, "需要模块路径, 实际提供的 token: %s", token.TypeString(p.Token().Type)))
+}
+
+// 导入语句: import mod [as m]
+func (p *Parser) parseImportStatement() *ImportStmt {
+
+	p.require(token.IMPORT, true)
+
+	stmt := &ImportStmt{
+		Path: p.modulePath(),
+	}
+	if p.Token().Type == token.AS {
+		p.next()
+		stmt.Alias = p.require(token.IDENTITY, true)
+	}
+
+	return stmt
+}
+
+// 导入语句: from mod import a, b
+func (p *Parser) parseFromImportStatement() *ImportStmt {
+
+	p.require(token.FROM, true)
+
+	stmt := &ImportStmt{
+		Path: p.modulePath(),
+	}
+
+	p.require(token.IMPORT, true)
+	for {
+		stmt.Names = append(stmt.Names, p.require(token.IDENTITY, true))
+		if p.Token().Type != token.COMMA {
+			break
+		}
+		p.next()
+	}
+
+	return stmt
+}
@@ -14,6 +14,13 @@ const (
 	FLOAT
 	STRING
 	BOOL
+	RECORD
+	ERROR
+	LIST
+	GENERATOR
+	CHANNEL
+	TASK
+	NONE // 没有值 (例如没有 return 的方法的结果)
 )
 
 var types = map[string]Type{
@@ -21,6 +28,14 @@ var types = map[string]Type{
 	"float64": FLOAT,
 	"string":  STRING,
 	"bool":    BOOL,
+
+	"*ast.RecordValue": RECORD,
+	"*ast.Error":       ERROR,
+	"*ast.ListValue":   LIST,
+
+	"*ast.GeneratorValue": GENERATOR,
+	"*ast.ChannelValue":   CHANNEL,
+	"*ast.TaskValue":      TASK,
 }
 
 func TypeString(t Type) string {
@@ -35,17 +50,34 @@ func TypeString(t Type) string {
 		return "string"
 	case BOOL:
 		return "bool"
+	case RECORD:
+		return "record"
+	case ERROR:
+		return "error"
+	case LIST:
+		return "list"
+	case GENERATOR:
+		return "generator"
+	case CHANNEL:
+		return "channel"
+	case TASK:
+		return "task"
+	case NONE:
+		return "none"
 	}
 	panic(fmt.Sprintf("错误: 未知类型 %v", t))
 }
 
 // GetType 反射并转换成规定的类型
 func GetType(val interface{}) Type {
+	if val == nil {
+		return NONE
+	}
 	return types[reflect.TypeOf(val).String()]
 }
 
 // ProcessType 根据两个type与运算符进行加工
-// 1. type按照顺序摆放: INT FLOAT STRING BOOL
+// 1. type按照顺序摆放: INT FLOAT STRING BOOL RECORD ERROR LIST
 // 2. 类型自动隐式转换
 func ProcessType(typ1 Type, typ2 Type) (Type, Type) {
 
@@ -78,7 +110,61 @@ func SameType(typ1 Type, typ2 Type, p Type) bool {
 	return typ1 == typ2 && typ1 == p
 }
 
+// ToFloat 将整数转换成浮点数
+func ToFloat(val interface{}) interface{} {
+	if i, ok := val.(int64); ok {
+		return float64(i)
+	}
+	return val
+}
+
 // Int64ToInt 将 int64 转换成 int 类型
 func Int64ToInt(i int64) int {
 	return *(*int)(unsafe.Pointer(&i))
 }
+
+// BinaryResult 二元运算的结果类型 (与解释器的运算规则一致)
+// 如果运算不合法则返回 false
+func BinaryResult(op int, typ1 Type, typ2 Type) (Type, bool) {
+	ltype, rtype := ProcessType(typ1, typ2)
+
+	switch op {
+	case ADD:
+		// 'abc' + 'def', 1 + 2, 1.1 + 2.2
+		if SameType(ltype, rtype, STRING) || SameType(ltype, rtype, INT) || SameType(ltype, rtype, FLOAT) {
+			return ltype, true
+		}
+	case SUB, MOD:
+		// 1 - 2, 1.1 - 2.2, 3 % 2, 2.3 % 1.2
+		if SameType(ltype, rtype, INT) || SameType(ltype, rtype, FLOAT) {
+			return ltype, true
+		}
+	case MUL:
+		// 'str' * 3
+		if ltype == INT && rtype == STRING {
+			return STRING, true
+		}
+		// 1 * 2, 1.1 * 2.2
+		if SameType(ltype, rtype, INT) || SameType(ltype, rtype, FLOAT) {
+			return ltype, true
+		}
+	case DIV:
+		// 1 / 2 = 0.5
+		if SameType(ltype, rtype, INT) || SameType(ltype, rtype, FLOAT) {
+			return FLOAT, true
+		}
+	case EQ, NQ:
+		if ltype == rtype {
+			switch ltype {
+			case INT, FLOAT, STRING, BOOL, RECORD, LIST:
+				return BOOL, true
+			}
+		}
+	case GT, GE, LT, LE:
+		if SameType(ltype, rtype, INT) || SameType(ltype, rtype, FLOAT) || SameType(ltype, rtype, STRING) {
+			return BOOL, true
+		}
+	}
+
+	return INVALID, false
+}
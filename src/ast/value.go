@@ -0,0 +1,315 @@
+package ast
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// RecordValue 结构体实例
+// 结构体可能被多个任务共享, 创建后通过 Get/Set/Values 读写
+type RecordValue struct {
+	Type   *Record
+	Fields []interface{} // 字段值 (与 Type.Fields 一一对应)
+	mu     sync.RWMutex
+}
+
+// Super 父类型引用 (方法内的 super)
+type Super struct {
+	Self   *RecordValue // 接收者
+	Record *Record      // 从该类型开始查找方法
+}
+
+func NewRecordValue(record *Record) *RecordValue {
+	return &RecordValue{
+		Type:   record,
+		Fields: make([]interface{}, len(record.Fields)),
+	}
+}
+
+// Get 读取字段
+func (r *RecordValue) Get(name string) interface{} {
+	i := r.Type.FieldIndex(name)
+	if i < 0 {
+		panic(NewError(AttributeError, "类型 %s 没有字段 %s", r.Type.Name, name))
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.Fields[i]
+}
+
+// Set 修改字段
+func (r *RecordValue) Set(name string, value interface{}) {
+	i := r.Type.FieldIndex(name)
+	if i < 0 {
+		panic(NewError(AttributeError, "类型 %s 没有字段 %s", r.Type.Name, name))
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Fields[i] = value
+}
+
+// Values 所有字段值的副本
+func (r *RecordValue) Values() []interface{} {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]interface{}(nil), r.Fields...)
+}
+
+// String 打印格式: Point{x: 1, y: 2}
+func (r *RecordValue) String() string {
+	var sb strings.Builder
+	values := r.Values()
+	sb.WriteString(r.Type.Name)
+	sb.WriteString("{")
+	for i, field := range r.Type.Fields {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(field)
+		sb.WriteString(": ")
+		sb.WriteString(Repr(values[i]))
+	}
+	sb.WriteString("}")
+	return sb.String()
+}
+
+// ListValue 列表
+// 列表可能被多个任务共享, 创建后通过 Len/Index/SetIndex/Values 读写
+type ListValue struct {
+	Elements []interface{}
+	mu       sync.RWMutex
+}
+
+func NewListValue(elements []interface{}) *ListValue {
+	return &ListValue{
+		Elements: elements,
+	}
+}
+
+// Len 列表长度
+func (l *ListValue) Len() int {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return len(l.Elements)
+}
+
+// Index 读取元素, 下标由调用方检查
+func (l *ListValue) Index(i int) interface{} {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.Elements[i]
+}
+
+// SetIndex 修改元素, 下标由调用方检查
+func (l *ListValue) SetIndex(i int, value interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.Elements[i] = value
+}
+
+// Values 所有元素的副本
+func (l *ListValue) Values() []interface{} {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return append([]interface{}(nil), l.Elements...)
+}
+
+// String 打印格式: [1, 'a', true]
+func (l *ListValue) String() string {
+	var sb strings.Builder
+	sb.WriteString("[")
+	for i, element := range l.Values() {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		sb.WriteString(Repr(element))
+	}
+	sb.WriteString("]")
+	return sb.String()
+}
+
+// GeneratorValue 生成器 (调用含有 yield 的方法得到)
+type GeneratorValue struct {
+	Fn     *Function
+	Resume func() (value interface{}, done bool) // 执行到下一个 yield, 由解释器提供
+}
+
+// String 打印格式: <generator count>
+func (g *GeneratorValue) String() string {
+	return "<generator " + g.Fn.Name + ">"
+}
+
+// Repr 值的字面量形式 (字符串带引号)
+func Repr(val interface{}) string {
+	if str, ok := val.(string); ok {
+		return "'" + str + "'"
+	}
+	return fmt.Sprint(val)
+}
+
+// Equal 判断两个值是否相等 (结构体按字段逐个比较, 列表按元素逐个比较)
+func Equal(v1 interface{}, v2 interface{}) bool {
+	l1, ok1 := v1.(*ListValue)
+	l2, ok2 := v2.(*ListValue)
+	if ok1 && ok2 {
+		e1, e2 := l1.Values(), l2.Values()
+		if len(e1) != len(e2) {
+			return false
+		}
+		for i := range e1 {
+			if !Equal(e1[i], e2[i]) {
+				return false
+			}
+		}
+		return true
+	}
+
+	r1, ok1 := v1.(*RecordValue)
+	r2, ok2 := v2.(*RecordValue)
+	if !ok1 || !ok2 {
+		return v1 == v2
+	}
+
+	if r1.Type != r2.Type {
+		return false
+	}
+	f1, f2 := r1.Values(), r2.Values()
+	for i := range f1 {
+		if !Equal(f1[i], f2[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// ChannelValue 通道 (任务之间传递消息)
+type ChannelValue struct {
+	Elem string // 元素类型名 (为空则不限制)
+
+	ch     chan interface{}
+	mu     sync.Mutex
+	closed bool
+}
+
+func NewChannelValue(elem string, size int) *ChannelValue {
+	return &ChannelValue{
+		Elem: elem,
+		ch:   make(chan interface{}, size),
+	}
+}
+
+// Chan 底层的通道 (用于 select)
+func (c *ChannelValue) Chan() chan interface{} {
+	return c.ch
+}
+
+// Check 检查发送的值是否符合元素类型, int 会隐式转换成 float
+func (c *ChannelValue) Check(val interface{}) interface{} {
+	if c.Elem == "" {
+		return val
+	}
+	if c.Elem == "float" {
+		val = ToFloat(val)
+	}
+	if name := TypeName(val); name != c.Elem {
+		panic(NewError(TypeError, "不能向 %s 类型的通道发送 %s 类型的值", c.Elem, name))
+	}
+	return val
+}
+
+// Send 发送值, 通道已满时阻塞, ctx 取消时返回 ctx.Err()
+func (c *ChannelValue) Send(ctx context.Context, val interface{}) error {
+	val = c.Check(val)
+
+	// 向已关闭的通道发送会引起 Go 的 panic
+	defer func() {
+		if r := recover(); r != nil {
+			panic(NewError(ChannelError, "向已关闭的通道发送数据"))
+		}
+	}()
+	select {
+	case c.ch <- val:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Recv 接收值, 通道为空时阻塞, 通道关闭且没有数据时 ok 为 false
+func (c *ChannelValue) Recv(ctx context.Context) (val interface{}, ok bool, err error) {
+	select {
+	case val, ok = <-c.ch:
+		return val, ok, nil
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	}
+}
+
+// Close 关闭通道
+func (c *ChannelValue) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		panic(NewError(ChannelError, "通道已经关闭"))
+	}
+	c.closed = true
+	close(c.ch)
+}
+
+// String 打印格式: <channel int>
+func (c *ChannelValue) String() string {
+	if c.Elem == "" {
+		return "<channel>"
+	}
+	return "<channel " + c.Elem + ">"
+}
+
+// TaskValue 并发任务 (spawn 启动的方法调用)
+type TaskValue struct {
+	Fn *Function
+
+	done   chan struct{}
+	result interface{}
+	err    *Error
+}
+
+func NewTaskValue(fn *Function) *TaskValue {
+	return &TaskValue{
+		Fn:   fn,
+		done: make(chan struct{}),
+	}
+}
+
+// Finish 任务结束, 保存返回值或者错误
+func (t *TaskValue) Finish(result interface{}, err *Error) {
+	t.result, t.err = result, err
+	close(t.done)
+}
+
+// Wait 等待任务结束并返回结果, 任务出错时抛出同样的错误, ctx 取消时返回 ctx.Err()
+func (t *TaskValue) Wait(ctx context.Context) (interface{}, error) {
+	select {
+	case <-t.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if t.err != nil {
+		panic(t.err)
+	}
+	return t.result, nil
+}
+
+// String 打印格式: <task work>
+func (t *TaskValue) String() string {
+	return "<task " + t.Fn.Name + ">"
+}
+
+// TypeName 值的类型名, 结构体为结构体名
+func TypeName(val interface{}) string {
+	if record, ok := val.(*RecordValue); ok {
+		return record.Type.Name
+	}
+	return TypeString(GetType(val))
+}
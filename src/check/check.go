@@ -0,0 +1,577 @@
+package check
+
+import (
+	"fmt"
+	"my-lang/ast"
+	"my-lang/cst"
+	"my-lang/rt"
+	"my-lang/token"
+	"os"
+	"sort"
+)
+
+// Diagnostic 检查出的问题
+type Diagnostic struct {
+	Pos     token.Pos
+	Msg     string
+	Warning bool // 在 try 语句内的错误会被捕获, 只作为警告
+}
+
+func (d *Diagnostic) String() string {
+	level := "错误"
+	if d.Warning {
+		level = "警告"
+	}
+	if d.Pos.IsValid() {
+		return fmt.Sprintf("%s: %s: %s", d.Pos, level, d.Msg)
+	}
+	return fmt.Sprintf("%s: %s", level, d.Msg)
+}
+
+// Checker 类型检查器
+// 与解释器一样按顺序逐条解析语句, 但是对象表里的变量保存的是静态类型而不是值
+type Checker struct {
+	Diagnostics []*Diagnostic
+	Refs        []*Ref     // 名字的定义与引用
+	Scopes      []*Scope   // 每条语句开始处的对象表
+	Findings    []*Finding // 可疑的写法
+
+	// 与解释器一致: 没有 fs:read 时不能导入模块, 没有 env 时不从 MYLANG_PATH 查找模块
+	Capabilities rt.Capabilities
+
+	fns     map[*ast.Function]*fnInfo // 已检查的方法
+	order   []*ast.Function           // 按检查顺序排列的方法
+	modules *rt.ModuleLoader          // 已检查的模块, 与解释器一样检测循环导入
+	tests   map[[2]string]bool        // 已定义的测试块 (文件, 名字)
+}
+
+// 变量的静态信息 (保存在 ast.Variable.Value 里)
+type varInfo struct {
+	typ      Type
+	declared bool    // 是否有类型注解
+	assigned kindSet // 赋过的值的类型
+}
+
+// 方法的静态信息
+type fnInfo struct {
+	args   []Type
+	result Type
+	level  int  // 定义所在的方法层数, 比它更深的类型变量在调用时泛化
+	done   bool // 方法体是否已经检查完毕 (递归调用时返回值类型还不确定)
+}
+
+// 语法块的上下文
+type scope struct {
+	file    string
+	objs    *ast.ObjectList
+	top     *ast.ObjectList // 文件顶层的对象表
+	local   *ast.ObjectList // 所在方法的对象表 (顶层为文件的对象表)
+	fn      *fnInfo         // 所在的方法 (顶层与语句块表达式内为 nil)
+	level   int             // 方法嵌套层数
+	declare *Type           // 方法声明的返回值类型
+	returns *[]Type         // 语法块内所有 return 的类型
+	inTry   bool            // 是否在 try 语句内
+}
+
+func NewChecker() *Checker {
+	return &Checker{
+		Capabilities: rt.AllCapabilities(),
+
+		fns:     make(map[*ast.Function]*fnInfo),
+		modules: rt.NewModuleLoader(),
+		tests:   make(map[[2]string]bool),
+	}
+}
+
+// CheckFile 检查源文件
+func (c *Checker) CheckFile(path string) {
+	c.modules.Enter(path)
+	c.checkFile(path)
+}
+
+// CheckSource 检查内存中的源码 (例如编辑器里尚未保存的文件), path 用于记录位置与查找模块
+func (c *Checker) CheckSource(path string, src []byte) {
+	c.modules.Enter(path)
+	c.checkSource(path, src)
+}
+
+// HasErrors 是否有错误 (不包括警告)
+func (c *Checker) HasErrors() bool {
+	for _, d := range c.Diagnostics {
+		if !d.Warning {
+			return true
+		}
+	}
+	return false
+}
+
+// Sorted 按位置排序的检查结果
+func (c *Checker) Sorted() []*Diagnostic {
+	diagnostics := make([]*Diagnostic, len(c.Diagnostics))
+	copy(diagnostics, c.Diagnostics)
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		return less(diagnostics[i].Pos, diagnostics[j].Pos)
+	})
+	return diagnostics
+}
+
+// 位置排序
+func less(pi token.Pos, pj token.Pos) bool {
+	if pi.File != pj.File {
+		return pi.File < pj.File
+	}
+	if pi.Line != pj.Line {
+		return pi.Line < pj.Line
+	}
+	return pi.Col < pj.Col
+}
+
+// 检查源文件, 返回文件顶层的对象表
+func (c *Checker) checkFile(path string) *ast.ObjectList {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		c.Diagnostics = append(c.Diagnostics, &Diagnostic{Pos: token.Pos{File: path}, Msg: fmt.Sprintf("%s: %s", ast.IOError, err)})
+		return ast.NewObjectList(rt.Builtins())
+	}
+	return c.checkSource(path, src)
+}
+
+// 检查源码, 语义分析的 token 由具体语法树得到
+func (c *Checker) checkSource(path string, src []byte) *ast.ObjectList {
+	objs := ast.NewObjectList(rt.Builtins())
+	sc := &scope{
+		file:  path,
+		objs:  objs,
+		top:   objs,
+		local: objs,
+	}
+	tree, errs := cst.Parse(path, src)
+	for _, err := range errs {
+		c.errorf(err.Pos, sc, "%s: %s", ast.SyntaxError, err.Msg)
+	}
+	c.block(tree.Tokens(), sc)
+	return objs
+}
+
+func (c *Checker) errorf(pos token.Pos, sc *scope, format string, args ...interface{}) {
+	c.Diagnostics = append(c.Diagnostics, &Diagnostic{
+		Pos:     pos,
+		Msg:     fmt.Sprintf(format, args...),
+		Warning: sc.inTry,
+	})
+}
+
+// 逐条检查语法块内的语句
+// 语法分析出错时无法继续分析这个语法块, 记录错误后直接跳过
+func (c *Checker) block(toks []token.Token, sc *scope) {
+	p := ast.NewParser(toks, sc.objs)
+
+	defer func() {
+		if r := recover(); r != nil {
+			err, ok := r.(*ast.Error)
+			if !ok {
+				panic(r)
+			}
+			pos := err.Pos
+			if !pos.IsValid() && !p.IsEnd() {
+				pos = p.Token().Pos
+			}
+			c.errorf(pos, sc, "%s", err.Error())
+		}
+	}()
+
+	returned := false // 是否已经执行了 return 或者 throw
+	for !p.IsEnd() {
+		length := sc.objs.Len()
+		c.Scopes = append(c.Scopes, &Scope{Pos: p.Token().Pos, Objs: sc.objs})
+
+		// return 之后的第一条语句
+		if tok := p.Token(); returned && tok.Type != token.LINEBREAK && tok.Type != token.SEMICOLON {
+			c.note(tok.Pos, RuleUnreachable, "这条语句永远不会执行")
+			returned = false
+		}
+
+		// impl [Point] { ... }
+		impl := ""
+		if p.Token().Type == token.IMPL && p.Offset+1 < len(p.Tokens) {
+			impl = p.Tokens[p.Offset+1].Lit
+		}
+
+		stmt := p.ParseStmt()
+		if stmt != nil {
+			c.stmt(stmt, sc)
+			switch stmt.(type) {
+			case *ast.ReturnStmt, *ast.ThrowStmt:
+				returned = true
+			}
+			continue
+		}
+
+		// 新定义的方法与类型
+		for i := length; i < sc.objs.Len(); i++ {
+			switch obj := sc.objs.Get(i).(type) {
+			case *ast.Function:
+				c.checkFn(obj, sc)
+			case *ast.Record:
+				c.def(obj.Pos(), obj, sc)
+			}
+		}
+
+		// impl 新定义的类型方法
+		if impl != "" {
+			c.checkImpl(impl, sc)
+		}
+	}
+}
+
+// 检查 impl 里定义的类型方法
+func (c *Checker) checkImpl(name string, sc *scope) {
+	record, ok := sc.objs.FindObject(name).(*ast.Record)
+	if !ok {
+		return
+	}
+
+	// 按定义的顺序检查
+	fns := make([]*ast.Function, 0, len(record.Methods))
+	for _, fn := range record.Methods {
+		fns = append(fns, fn)
+	}
+	sort.Slice(fns, func(i, j int) bool {
+		return less(fns[i].Pos(), fns[j].Pos())
+	})
+	for _, fn := range fns {
+		c.checkFn(fn, sc)
+	}
+}
+
+// 子语法块的上下文
+func (sc *scope) child(objs *ast.ObjectList) *scope {
+	child := *sc
+	child.objs = objs
+	return &child
+}
+
+// 根据类型注解获取类型
+func (c *Checker) resolveType(name string, pos token.Pos, sc *scope) Type {
+	if name == "" {
+		return Any
+	}
+	if t, ok := typeNames[name]; ok {
+		return t
+	}
+	if record, ok := sc.objs.FindObject(name).(*ast.Record); ok {
+		return RecordType(record)
+	}
+	c.errorf(pos, sc, "未知类型 %s", name)
+	return Any
+}
+
+// 检查方法体
+func (c *Checker) checkFn(fn *ast.Function, sc *scope) *fnInfo {
+	if info, ok := c.fns[fn]; ok {
+		return info
+	}
+
+	info := &fnInfo{level: sc.level}
+	c.fns[fn] = info
+	c.order = append(c.order, fn)
+	c.def(fn.Pos(), fn, sc)
+
+	fnObjs := ast.NewObjectList(fn.ParentObjs)
+	if fn.Owner != nil {
+		fnObjs.Add(&ast.Variable{Name: "self", Value: &varInfo{typ: RecordType(fn.Owner)}})
+		if fn.Owner.Parent != nil {
+			fnObjs.Add(&ast.Variable{Name: "super", Value: &varInfo{typ: RecordType(fn.Owner.Parent)}})
+		}
+	}
+
+	fnScope := &scope{
+		file:  sc.file,
+		objs:  fnObjs,
+		top:   sc.top,
+		local: fnObjs,
+		fn:    info,
+		level: sc.level + 1,
+		inTry: sc.inTry,
+	}
+
+	for _, arg := range fn.Args {
+		// 没有注解的参数用类型变量表示, 由方法体推导
+		typ := newVar(fnScope.level)
+		if arg.Type != "" {
+			typ = c.resolveType(arg.Type, fn.Pos(), sc)
+		}
+		if arg.Rest {
+			typ = List
+		}
+		if arg.Default != nil {
+			def := c.expr(arg.Default, sc)
+			if typ.Var != nil && !def.IsAny() {
+				typ.Var.hint |= def.kinds()
+			} else if !unify(typ, def) {
+				c.errorf(arg.Default.Pos(), sc, "参数 %s 的默认值类型 %s 与声明的 %s 不一致", arg.Name, def, typ)
+			}
+		}
+		info.args = append(info.args, typ)
+		variable := &ast.Variable{Name: arg.Name, Value: &varInfo{typ: typ, declared: arg.Type != ""}}
+		fnObjs.Add(variable)
+		if ref := c.def(fn.Pos(), variable, fnScope); ref != nil {
+			ref.Param = true
+		}
+	}
+
+	var returns []Type
+	fnScope.returns = &returns
+	if fn.Generator {
+		// 生成器方法的调用结果总是生成器, return 只是结束生成器
+		if fn.Result != "" && c.resolveType(fn.Result, fn.Pos(), sc) != Generator {
+			c.errorf(fn.Pos(), sc, "生成器方法 %s 的返回值类型必须是 generator", fn.Name)
+		}
+		info.result = Generator
+	} else if fn.Result != "" {
+		declare := c.resolveType(fn.Result, fn.Pos(), sc)
+		fnScope.declare = &declare
+		info.result = declare
+	} else {
+		info.result = newVar(fnScope.level)
+	}
+
+	c.block(fn.Body, fnScope)
+
+	// 没有 return 语句的方法没有返回值
+	if info.result.Var != nil && len(returns) == 0 {
+		info.result.Var.bind(Any)
+	}
+	info.done = true
+	return info
+}
+
+// 检查语句
+func (c *Checker) stmt(stmt ast.Stmt, sc *scope) {
+	switch stmt := stmt.(type) {
+	case *ast.ExprStmt:
+		c.expr(stmt.Expr, sc)
+	case *ast.AssignStmt:
+		c.assign(stmt, sc)
+	case *ast.FieldAssignStmt:
+		c.field(c.expr(stmt.X, sc), stmt.Name, stmt.Pos(), sc)
+		c.expr(stmt.Value, sc)
+	case *ast.IndexAssignStmt:
+		c.index(c.expr(stmt.X, sc), stmt.Index, sc)
+		c.expr(stmt.Value, sc)
+	case *ast.PrintStmt:
+		c.expr(stmt.Expr, sc)
+	case *ast.ReturnStmt:
+		typ := c.expr(stmt.Expr, sc)
+		if sc.returns == nil {
+			c.errorf(stmt.Pos(), sc, "return 语句在不合法的位置")
+			return
+		}
+		*sc.returns = append(*sc.returns, typ)
+		switch {
+		case sc.declare != nil:
+			if !unify(*sc.declare, typ) {
+				c.errorf(stmt.Pos(), sc, "返回值类型 %s 与声明的 %s 不一致", typ, *sc.declare)
+			}
+		case sc.fn != nil && sc.fn.result.Var != nil:
+			// 多个 return 的类型不一致时, 返回值放宽为任意类型
+			if typ.IsAny() || !unify(sc.fn.result, typ) {
+				sc.fn.result.Var.bind(Any)
+			}
+		}
+	case *ast.IfStmt:
+		c.constantCond(stmt, stmt.Cond)
+		if cond := c.expr(stmt.Cond, sc); !unify(Bool, cond) {
+			c.errorf(stmt.Cond.Pos(), sc, "if 条件必须是 bool 类型, 实际是 %s", cond)
+		}
+		c.block(stmt.TrueBody, sc.child(ast.NewObjectList(sc.objs)))
+		c.block(stmt.FalseBody, sc.child(ast.NewObjectList(sc.objs)))
+	case *ast.ForStmt:
+		if stmt.Iter != nil {
+			c.forIn(stmt, sc)
+			return
+		}
+		c.constantCond(stmt, stmt.Cond)
+		if cond := c.expr(stmt.Cond, sc); !unify(Bool, cond) {
+			c.errorf(stmt.Cond.Pos(), sc, "for 条件必须是 bool 类型, 实际是 %s", cond)
+		}
+		c.block(stmt.Body, sc.child(ast.NewObjectList(sc.objs)))
+	case *ast.TryStmt:
+		body := sc.child(ast.NewObjectList(sc.objs))
+		body.inTry = true
+		c.block(stmt.Body, body)
+		if stmt.CatchBody != nil {
+			objs := ast.NewObjectList(sc.objs)
+			if stmt.CatchName != "" {
+				variable := &ast.Variable{Name: stmt.CatchName, Value: &varInfo{typ: Error}}
+				objs.Add(variable)
+				c.def(stmt.Pos(), variable, sc.child(objs))
+			}
+			c.block(stmt.CatchBody, sc.child(objs))
+		}
+		if stmt.FinallyBody != nil {
+			c.block(stmt.FinallyBody, sc.child(ast.NewObjectList(sc.objs)))
+		}
+	case *ast.ThrowStmt:
+		c.expr(stmt.Expr, sc)
+	case *ast.YieldStmt:
+		c.expr(stmt.Expr, sc)
+	case *ast.SelectStmt:
+		for _, selectCase := range stmt.Cases {
+			c.expr(selectCase.Call, sc)
+			objs := ast.NewObjectList(sc.objs)
+			if selectCase.Name != "" {
+				variable := &ast.Variable{Name: selectCase.Name, Value: &varInfo{typ: Any}}
+				objs.Add(variable)
+				c.def(stmt.Pos(), variable, sc.child(objs))
+			}
+			c.block(selectCase.Body, sc.child(objs))
+		}
+		c.block(stmt.Default, sc.child(ast.NewObjectList(sc.objs)))
+	case *ast.ImportStmt:
+		c.importModule(stmt, sc)
+	case *ast.TestStmt:
+		c.testBlock(stmt, sc)
+	}
+}
+
+// 检查测试块: 只能在文件顶层定义, 同一个文件里名字不能重复, 块内与方法体一样可以 return
+func (c *Checker) testBlock(stmt *ast.TestStmt, sc *scope) {
+	if sc.objs != sc.top {
+		c.errorf(stmt.Pos(), sc, "test 块只能在文件顶层定义")
+	}
+	key := [2]string{sc.file, stmt.Name}
+	if c.tests[key] {
+		c.errorf(stmt.Pos(), sc, "测试 %s 重复定义", stmt.Name)
+	}
+	c.tests[key] = true
+
+	var returns []Type
+	body := sc.child(ast.NewObjectList(sc.objs))
+	body.returns = &returns
+	c.block(stmt.Body, body)
+}
+
+// 检查 for x in xs 语句, 可以遍历列表, 字符串与生成器
+func (c *Checker) forIn(stmt *ast.ForStmt, sc *scope) {
+	iter := c.expr(stmt.Iter, sc).resolve()
+
+	iterable := kindOf(ast.LIST) | kindOf(ast.STRING) | kindOf(ast.GENERATOR) | kindOf(ast.CHANNEL)
+	kinds := iter.kinds() & iterable
+	if kinds == 0 {
+		c.errorf(stmt.Iter.Pos(), sc, "TypeError: %s 类型不能遍历", iter)
+	} else if iter.Var != nil {
+		iter.Var.narrow(kinds)
+	}
+
+	// 字符串逐个字符遍历, 其它元素类型不确定
+	elem := Any
+	if iter.resolve() == String {
+		elem = String
+	}
+
+	objs := ast.NewObjectList(sc.objs)
+	variable := &ast.Variable{Name: stmt.Name, Value: &varInfo{typ: elem}}
+	objs.Add(variable)
+	c.def(stmt.Pos(), variable, sc.child(objs))
+	c.block(stmt.Body, sc.child(objs))
+}
+
+// 检查赋值语句
+func (c *Checker) assign(stmt *ast.AssignStmt, sc *scope) {
+	typ := c.expr(stmt.Value, sc)
+
+	declared := stmt.Type != ""
+	if declared {
+		declare := c.resolveType(stmt.Type, stmt.Pos(), sc)
+		if !unify(declare, typ) {
+			c.errorf(stmt.Value.Pos(), sc, "不能将 %s 类型的值赋给 %s 类型的 %s", typ, declare, stmt.Name)
+		}
+		typ = declare
+	}
+
+	obj := sc.objs.FindObject(stmt.Name)
+	if obj == nil {
+		variable := &ast.Variable{Name: stmt.Name, Value: &varInfo{typ: typ, declared: declared, assigned: typ.kinds()}}
+		sc.objs.Add(variable)
+		c.def(stmt.Pos(), variable, sc)
+		return
+	}
+	c.assignRef(stmt.Pos(), obj)
+
+	variable, ok := obj.(*ast.Variable)
+	if !ok {
+		c.errorf(stmt.Pos(), sc, "%s 不是变量, 不能赋值", stmt.Name)
+		return
+	}
+
+	if !definedWithin(sc.objs, sc.local, variable) {
+		c.note(stmt.Pos(), RuleOuterAssign, "赋值修改了外层的变量 %s", stmt.Name)
+	}
+
+	info := variable.Value.(*varInfo)
+	info.assigned |= typ.kinds()
+	switch {
+	case info.declared:
+		if !unify(info.typ, typ) {
+			c.errorf(stmt.Value.Pos(), sc, "不能将 %s 类型的值赋给 %s 类型的 %s", typ, info.typ, stmt.Name)
+		}
+	case declared:
+		info.typ, info.declared = typ, true
+	case info.typ.resolve() != typ.resolve():
+		// 没有类型注解的变量可以被赋予不同类型的值
+		info.typ = join([]Type{info.typ, typ})
+	}
+}
+
+// 检查导入语句, 被导入的模块同样会被检查
+func (c *Checker) importModule(stmt *ast.ImportStmt, sc *scope) {
+	if !c.Capabilities.Has(rt.CapFsRead) {
+		c.errorf(stmt.Pos(), sc, "%s: import 需要 %s 权限", ast.PermissionError, rt.CapFsRead)
+		return
+	}
+
+	// 找不到模块与循环导入的错误与解释器一致
+	var module *ast.Module
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				err, ok := r.(*ast.Error)
+				if !ok {
+					panic(r)
+				}
+				c.errorf(stmt.Pos(), sc, "%s", err.Error())
+			}
+		}()
+		path := rt.FindModule(sc.file, stmt.Path, c.Capabilities)
+		module = c.modules.Load(path, c.checkSource)
+	}()
+	if module == nil {
+		return
+	}
+
+	if stmt.Names == nil {
+		name := module.Name
+		if stmt.Alias != "" {
+			name = stmt.Alias
+		}
+		alias := &ast.Module{
+			Name:    name,
+			Path:    module.Path,
+			Objects: module.Objects,
+		}
+		sc.objs.Add(alias)
+		c.def(stmt.Pos(), alias, sc)
+		return
+	}
+
+	for _, name := range stmt.Names {
+		obj := module.Objects.FindObject(name)
+		if obj == nil {
+			c.errorf(stmt.Pos(), sc, "ImportError: 模块 %s 没有对象: %s", module.Name, name)
+			continue
+		}
+		sc.objs.Add(obj)
+		c.ref(stmt.Pos(), obj)
+	}
+}
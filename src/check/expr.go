@@ -0,0 +1,296 @@
+package check
+
+import (
+	"my-lang/ast"
+	"my-lang/token"
+)
+
+// 推导表达式的类型
+func (c *Checker) expr(expr ast.Expr, sc *scope) Type {
+	switch expr := expr.(type) {
+	case *ast.LitExpr:
+		return Type{Kind: expr.Type}
+	case *ast.IdentityExpr:
+		c.ref(expr.Pos(), expr.Object)
+		switch obj := expr.Object.(type) {
+		case *ast.Variable:
+			return obj.Value.(*varInfo).typ
+		case *ast.Function:
+			c.errorf(expr.Pos(), sc, "方法 %s 不能作为值使用", obj.Name)
+		case *ast.Builtin:
+			c.errorf(expr.Pos(), sc, "内置方法 %s 不能作为值使用", obj.Name)
+		}
+		return Any
+	case *ast.BinaryExpr:
+		return c.binary(expr, sc)
+	case *ast.BlockExpr:
+		// 语句块的值是块内 return 的值
+		var returns []Type
+		block := sc.child(ast.NewObjectList(sc.objs))
+		block.fn, block.declare, block.returns = nil, nil, &returns
+		c.block(expr.Toks, block)
+		return join(returns)
+	case *ast.CallFnExpr:
+		c.ref(expr.Pos(), expr.Fn)
+		args, result := c.instantiate(c.checkFn(expr.Fn, sc), sc)
+		c.params(expr.Fn, args, expr.Params, expr.Pos(), sc)
+		return result
+	case *ast.CallBuiltinExpr:
+		c.ref(expr.Pos(), expr.Builtin)
+		for _, param := range expr.Params {
+			c.expr(param, sc)
+		}
+		return Type{Kind: expr.Builtin.Result}
+	case *ast.NewRecordExpr:
+		c.ref(expr.Pos(), expr.Record)
+		for _, field := range expr.Fields {
+			c.expr(field, sc)
+		}
+		return RecordType(expr.Record)
+	case *ast.FieldExpr:
+		return c.field(c.expr(expr.X, sc), expr.Name, expr.Pos(), sc)
+	case *ast.MethodCallExpr:
+		recv := c.expr(expr.X, sc).resolve()
+		if recv.Var != nil && recv.Var.kinds.has(ast.RECORD) {
+			// 只有结构体有方法, 但是具体的类型还不确定
+			recv.Var.narrow(kindOf(ast.RECORD))
+			recv = Any
+		}
+		if recv.IsAny() {
+			for _, param := range expr.Params {
+				c.expr(param.Value, sc)
+			}
+			return Any
+		}
+		if recv.Kind != ast.RECORD {
+			c.errorf(expr.Pos(), sc, "%s: %s 类型没有方法 %s", ast.AttributeError, recv, expr.Name)
+			return Any
+		}
+		fn := recv.Record.FindMethod(expr.Name)
+		if fn == nil {
+			c.errorf(expr.Pos(), sc, "%s: 类型 %s 没有方法 %s", ast.AttributeError, recv, expr.Name)
+			return Any
+		}
+		c.ref(expr.Pos(), fn)
+		args, result := c.instantiate(c.checkFn(fn, sc), sc)
+		c.params(fn, args, expr.Params, expr.Pos(), sc)
+		return result
+	case *ast.ListExpr:
+		for _, element := range expr.Elements {
+			c.expr(element, sc)
+		}
+		return List
+	case *ast.IndexExpr:
+		return c.index(c.expr(expr.X, sc), expr.Index, sc)
+	case *ast.SpawnExpr:
+		c.expr(expr.Call, sc)
+		return Task
+	}
+	return Any
+}
+
+// 二元表达式, 运算规则与解释器一致
+func (c *Checker) binary(expr *ast.BinaryExpr, sc *scope) Type {
+	left, right := c.expr(expr.Left, sc).resolve(), c.expr(expr.Right, sc).resolve()
+	c.compareTypes(expr, left, right)
+
+	if left.IsAny() || right.IsAny() {
+		switch expr.Op {
+		case ast.EQ, ast.NQ, ast.GT, ast.GE, ast.LT, ast.LE:
+			return Bool
+		}
+		return Any
+	}
+
+	if left.Var != nil || right.Var != nil {
+		return c.binaryVar(expr, left, right, sc)
+	}
+
+	result, ok := ast.BinaryResult(expr.Op, left.Kind, right.Kind)
+	if !ok {
+		c.errorf(expr.Pos(), sc, "TypeError: 不合法的运算 %s %s %s", left, ast.OperatorString(expr.Op), right)
+		return Any
+	}
+	return Type{Kind: result}
+}
+
+// 含有类型变量的二元表达式
+// 类型变量收窄到能参与运算的类型, 例如 n % 2 里的 n 只能是 int 或者 float
+func (c *Checker) binaryVar(expr *ast.BinaryExpr, left Type, right Type, sc *scope) Type {
+	var lkinds, rkinds, results kindSet
+	for _, l := range left.kinds().list() {
+		for _, r := range right.kinds().list() {
+			// x * x 两边是同一个类型
+			if left.Var == right.Var && l != r {
+				continue
+			}
+			if result, ok := ast.BinaryResult(expr.Op, l, r); ok {
+				lkinds, rkinds, results = lkinds|kindOf(l), rkinds|kindOf(r), results|kindOf(result)
+			}
+		}
+	}
+	if results == 0 {
+		c.errorf(expr.Pos(), sc, "TypeError: 不合法的运算 %s %s %s", left, ast.OperatorString(expr.Op), right)
+		return Any
+	}
+
+	hint := hintOf(left) | hintOf(right)
+	if left.Var != nil {
+		left.Var.hint |= hintOf(right)
+		left.Var.narrow(lkinds)
+	}
+	if right.Var != nil && right.Var != left.Var {
+		right.Var.hint |= hintOf(left)
+		right.Var.narrow(rkinds)
+	}
+	left, right = left.resolve(), right.resolve()
+
+	switch {
+	case len(results.list()) == 1:
+		return Type{Kind: results.list()[0]}
+	case left.Var != nil && left.Var.kinds == results && (right.Var == nil || right.Var == left.Var):
+		// n + 1 的类型与 n 相同
+		return left
+	case right.Var != nil && right.Var.kinds == results && left.Var == nil:
+		return right
+	}
+	result := newVar(sc.level)
+	result.Var.kinds, result.Var.hint = results, hint
+	return result
+}
+
+// 运算中遇到的具体类型
+func hintOf(t Type) kindSet {
+	if t.Var != nil {
+		return t.Var.hint
+	}
+	return kindOf(t.Kind)
+}
+
+// 成员访问
+func (c *Checker) field(x Type, name string, pos token.Pos, sc *scope) Type {
+	x = x.resolve()
+	if x.Var != nil {
+		// 只有结构体与错误有字段
+		kinds := x.Var.kinds & (kindOf(ast.RECORD) | kindOf(ast.ERROR))
+		if kinds == 0 {
+			c.errorf(pos, sc, "AttributeError: %s 类型没有字段 %s", x, name)
+			return Any
+		}
+		x.Var.narrow(kinds)
+		return Any
+	}
+
+	switch x.Kind {
+	case ast.INVALID:
+		return Any
+	case ast.RECORD:
+		if x.Record != nil && x.Record.FieldIndex(name) < 0 {
+			c.errorf(pos, sc, "AttributeError: 类型 %s 没有字段 %s", x, name)
+		}
+		return Any
+	case ast.ERROR:
+		switch name {
+		case "kind", "message", "trace":
+			return String
+		case "value":
+			return Any
+		}
+	}
+	c.errorf(pos, sc, "AttributeError: %s 类型没有字段 %s", x, name)
+	return Any
+}
+
+// 下标访问
+func (c *Checker) index(x Type, index ast.Expr, sc *scope) Type {
+	if typ := c.expr(index, sc); !unify(Int, typ) {
+		c.errorf(index.Pos(), sc, "TypeError: 下标必须是 int 类型, 实际是 %s", typ)
+	}
+	if !unify(List, x) {
+		c.errorf(index.Pos(), sc, "TypeError: %s 类型不支持下标访问", x)
+	}
+	return Any
+}
+
+// 调用时的参数与返回值类型
+// 方法体检查完毕后, 方法自己的类型变量在每次调用时换成新的类型变量 (泛化)
+// 递归调用时方法体还没检查完毕, 直接使用同一组类型变量
+func (c *Checker) instantiate(info *fnInfo, sc *scope) ([]Type, Type) {
+	if !info.done {
+		return info.args, info.result
+	}
+
+	fresh := make(map[*TypeVar]*TypeVar)
+	inst := func(t Type) Type {
+		t = t.resolve()
+		if t.Var == nil || t.Var.level <= info.level {
+			return t
+		}
+		v, ok := fresh[t.Var]
+		if !ok {
+			v = &TypeVar{kinds: t.Var.kinds, hint: t.Var.hint, level: sc.level}
+			fresh[t.Var] = v
+		}
+		return Type{Var: v}
+	}
+
+	args := make([]Type, len(info.args))
+	for i, arg := range info.args {
+		args[i] = inst(arg)
+	}
+	return args, inst(info.result)
+}
+
+// 检查调用参数, 规则与解释器绑定参数时一致
+func (c *Checker) params(fn *ast.Function, args []Type, params []ast.Param, pos token.Pos, sc *scope) {
+	bound := make([]bool, len(fn.Args))
+
+	fixed := len(fn.Args)
+	rest := fixed > 0 && fn.Args[fixed-1].Rest
+	if rest {
+		fixed -= 1
+		bound[fixed] = true
+	}
+
+	position := 0
+	for _, param := range params {
+		typ := c.expr(param.Value, sc)
+
+		i := position
+		if param.Name != "" {
+			i = -1
+			for j, arg := range fn.Args {
+				if arg.Name == param.Name && !arg.Rest {
+					i = j
+				}
+			}
+			if i < 0 {
+				c.errorf(param.Value.Pos(), sc, "TypeError: %s 没有参数 %s", fn.Signature(), param.Name)
+				continue
+			}
+			if bound[i] {
+				c.errorf(param.Value.Pos(), sc, "TypeError: %s 的参数 %s 重复赋值", fn.Signature(), param.Name)
+				continue
+			}
+		} else {
+			position += 1
+			if i >= fixed {
+				if !rest {
+					c.errorf(param.Value.Pos(), sc, "TypeError: %s 最多需要 %d 个参数, 实际提供 %d 个", fn.Signature(), fixed, len(params))
+				}
+				continue
+			}
+		}
+
+		bound[i] = true
+		if i < len(args) && !unify(args[i], typ) {
+			c.errorf(param.Value.Pos(), sc, "TypeError: 参数 %s 需要 %s 类型, 实际是 %s", fn.Args[i].Name, args[i], typ)
+		}
+	}
+
+	for i, arg := range fn.Args {
+		if !bound[i] && arg.Default == nil {
+			c.errorf(pos, sc, "TypeError: %s 缺少参数 %s", fn.Signature(), arg.Name)
+		}
+	}
+}
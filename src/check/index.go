@@ -0,0 +1,144 @@
+package check
+
+import (
+	"fmt"
+	"my-lang/ast"
+	"my-lang/token"
+	"strings"
+)
+
+// Ref 名字在源码中的一次出现 (定义或者引用), 供编辑器跳转与查找引用
+// 位置是所在表达式或者语句的位置, 不一定正好指向名字本身 (例如 m.f 指向 m, for x in 指向 for)
+type Ref struct {
+	Pos    token.Pos
+	Name   string
+	Obj    ast.Object // *ast.Variable, *ast.Function, *ast.Builtin, *ast.Record 或者 *ast.Module
+	Def    bool       // 是否是定义
+	Top    bool       // 是否定义在文件顶层
+	Assign bool       // 是否是对已有变量的赋值 (只写不读)
+	Param  bool       // 是否是方法参数的定义
+}
+
+// Scope 语句开始处可见的对象表, 供编辑器补全
+type Scope struct {
+	Pos  token.Pos
+	Objs *ast.ObjectList
+}
+
+// 记录名字的引用
+func (c *Checker) ref(pos token.Pos, obj ast.Object) {
+	if name := objectName(obj); name != "" && pos.IsValid() {
+		c.Refs = append(c.Refs, &Ref{Pos: pos, Name: name, Obj: obj})
+	}
+}
+
+// 记录对已有变量的赋值
+func (c *Checker) assignRef(pos token.Pos, obj ast.Object) {
+	if name := objectName(obj); name != "" && pos.IsValid() {
+		c.Refs = append(c.Refs, &Ref{Pos: pos, Name: name, Obj: obj, Assign: true})
+	}
+}
+
+// 记录名字的定义
+func (c *Checker) def(pos token.Pos, obj ast.Object, sc *scope) *Ref {
+	name := objectName(obj)
+	if name == "" || !pos.IsValid() {
+		return nil
+	}
+	ref := &Ref{Pos: pos, Name: name, Obj: obj, Def: true, Top: sc.objs == sc.top}
+	c.Refs = append(c.Refs, ref)
+	c.shadow(pos, obj, sc)
+	return ref
+}
+
+func objectName(obj ast.Object) string {
+	switch obj := obj.(type) {
+	case *ast.Variable:
+		return obj.Name
+	case *ast.Function:
+		return obj.Name
+	case *ast.Builtin:
+		return obj.Name
+	case *ast.Record:
+		return obj.Name
+	case *ast.Module:
+		return obj.Name
+	}
+	return ""
+}
+
+// Definition 对象的定义, 没有则返回 nil (例如内置方法)
+func (c *Checker) Definition(obj ast.Object) *Ref {
+	for _, ref := range c.Refs {
+		if ref.Def && ref.Obj == obj {
+			return ref
+		}
+	}
+	return nil
+}
+
+// References 对象的所有出现 (包括定义)
+func (c *Checker) References(obj ast.Object) (refs []*Ref) {
+	for _, ref := range c.Refs {
+		if ref.Obj == obj {
+			refs = append(refs, ref)
+		}
+	}
+	return
+}
+
+// ScopeAt 位置 pos 处可见的对象表 (同一文件中 pos 之前最近的语句)
+func (c *Checker) ScopeAt(pos token.Pos) *ast.ObjectList {
+	var found *Scope
+	for _, sc := range c.Scopes {
+		if sc.Pos.File != pos.File || less(pos, sc.Pos) {
+			continue
+		}
+		if found == nil || !less(sc.Pos, found.Pos) {
+			found = sc
+		}
+	}
+	if found == nil {
+		return nil
+	}
+	return found.Objs
+}
+
+// Describe 对象的说明: 变量的推导类型, 方法的签名, 类型的字段
+func (c *Checker) Describe(obj ast.Object) string {
+	switch obj := obj.(type) {
+	case *ast.Variable:
+		info, ok := obj.Value.(*varInfo)
+		if !ok {
+			return obj.Name
+		}
+		return obj.Name + ": " + typeString(info.typ)
+	case *ast.Function:
+		return c.Signature(obj)
+	case *ast.Builtin:
+		if obj.Result == ast.INVALID {
+			return obj.Name + "(...)"
+		}
+		return obj.Name + "(...): " + ast.TypeString(obj.Result)
+	case *ast.Record:
+		name := obj.Name
+		if obj.Parent != nil {
+			name += ": " + obj.Parent.Name
+		}
+		return fmt.Sprintf("type %s { %s }", name, strings.Join(obj.Fields, ", "))
+	case *ast.Module:
+		return "import " + obj.Path
+	}
+	return ""
+}
+
+// 推导出的类型, 类型变量显示为默认类型或者可能的类型
+func typeString(t Type) string {
+	t = t.resolve()
+	if t.Var != nil {
+		if typ, ok := t.Var.defaultType(); ok {
+			return typ.String()
+		}
+	}
+	return t.String()
+}
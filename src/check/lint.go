@@ -0,0 +1,140 @@
+package check
+
+import (
+	"fmt"
+	"my-lang/ast"
+	"my-lang/token"
+)
+
+// 检查时顺便发现的可疑写法, 不是错误, 由 lint 命令按规则报告
+const (
+	RuleUnreachable  = "unreachable"   // return 或者 throw 之后的语句
+	RuleOuterAssign  = "outer-assign"  // 在方法内修改外层的变量
+	RuleShadow       = "shadow"        // 名字遮蔽了外层的方法
+	RuleCompareTypes = "compare-types" // 比较的两边可能是不同的类型
+	RuleConstantCond = "constant-cond" // if 与 for 的条件是常量
+	RuleUnusedVar    = "unused-var"    // 没有使用的局部变量 (由 lint 根据 Refs 计算)
+	RuleUnusedParam  = "unused-param"  // 没有使用的参数 (由 lint 根据 Refs 计算)
+)
+
+// Finding 可疑的写法
+type Finding struct {
+	Pos  token.Pos
+	Rule string
+	Msg  string
+}
+
+func (f *Finding) String() string {
+	return fmt.Sprintf("%s: %s [%s]", f.Pos, f.Msg, f.Rule)
+}
+
+func (c *Checker) note(pos token.Pos, rule string, format string, args ...interface{}) {
+	if pos.IsValid() {
+		c.Findings = append(c.Findings, &Finding{Pos: pos, Rule: rule, Msg: fmt.Sprintf(format, args...)})
+	}
+}
+
+// 新定义的名字是否遮蔽了外层的方法
+func (c *Checker) shadow(pos token.Pos, obj ast.Object, sc *scope) {
+	objs := sc.objs
+	if fn, ok := obj.(*ast.Function); ok {
+		if fn.Owner != nil {
+			// 类型方法只能通过 x.f() 调用, 不会遮蔽
+			return
+		}
+		objs = fn.ParentObjs
+	}
+	prev := objs.Previous()
+	if prev == nil {
+		return
+	}
+	name := objectName(obj)
+	switch prev.FindObject(name).(type) {
+	case *ast.Function:
+		c.note(pos, RuleShadow, "%s 遮蔽了外层的方法 %s", name, name)
+	case *ast.Builtin:
+		c.note(pos, RuleShadow, "%s 遮蔽了内置方法 %s", name, name)
+	}
+}
+
+// obj 是否定义在 objs 到 local (所在方法的对象表) 之间
+func definedWithin(objs *ast.ObjectList, local *ast.ObjectList, obj ast.Object) bool {
+	for ; objs != nil; objs = objs.Previous() {
+		for i := objs.Len() - 1; i > 0; i-- {
+			if objs.Get(i) == obj {
+				return true
+			}
+		}
+		if objs == local {
+			break
+		}
+	}
+	return false
+}
+
+// 表达式是否是常量: 字面量以及字面量之间的运算
+func constant(expr ast.Expr) bool {
+	switch expr := expr.(type) {
+	case *ast.LitExpr:
+		return true
+	case *ast.BinaryExpr:
+		return constant(expr.Left) && constant(expr.Right)
+	}
+	return false
+}
+
+// if 与 for 的条件是常量, for true { ... } 是常见的无限循环, 不算
+func (c *Checker) constantCond(stmt ast.Stmt, cond ast.Expr) {
+	if !constant(cond) {
+		return
+	}
+	if lit, ok := cond.(*ast.LitExpr); ok {
+		if _, loop := stmt.(*ast.ForStmt); loop && lit.Type == ast.BOOL && lit.Lit == "true" {
+			return
+		}
+	}
+	keyword := "if"
+	if _, loop := stmt.(*ast.ForStmt); loop {
+		keyword = "for"
+	}
+	c.note(cond.Pos(), RuleConstantCond, "%s 的条件是常量", keyword)
+}
+
+// 比较运算的一边运行时可能的类型, 不确定时返回 0
+// 没有类型注解的变量被赋过不同类型的值时, 静态类型放宽为任意类型, 这里使用赋过的值的类型
+func (c *Checker) operandKinds(expr ast.Expr, t Type) kindSet {
+	if ident, ok := expr.(*ast.IdentityExpr); ok {
+		if variable, ok := ident.Object.(*ast.Variable); ok {
+			if info, ok := variable.Value.(*varInfo); ok && !info.declared && info.assigned != 0 && info.assigned != allKinds {
+				return info.assigned
+			}
+		}
+	}
+	t = t.resolve()
+	if t.Var != nil || t.IsAny() {
+		return 0
+	}
+	return kindOf(t.Kind)
+}
+
+// 比较的两边可能是不同的类型时 (运行时会抛出 TypeError)
+// 两边的类型都确定且不能比较时检查器已经报告了错误
+func (c *Checker) compareTypes(expr *ast.BinaryExpr, left Type, right Type) {
+	switch expr.Op {
+	case ast.EQ, ast.NQ, ast.GT, ast.GE, ast.LT, ast.LE:
+	default:
+		return
+	}
+	lkinds, rkinds := c.operandKinds(expr.Left, left), c.operandKinds(expr.Right, right)
+	if lkinds == 0 || rkinds == 0 || (len(lkinds.list()) == 1 && len(rkinds.list()) == 1) {
+		return
+	}
+	for _, l := range lkinds.list() {
+		for _, r := range rkinds.list() {
+			if _, ok := ast.BinaryResult(expr.Op, l, r); !ok {
+				c.note(expr.Pos(), RuleCompareTypes, "%s %s %s 的两边可能是不同的类型", lkinds, ast.OperatorString(expr.Op), rkinds)
+				return
+			}
+		}
+	}
+}
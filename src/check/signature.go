@@ -0,0 +1,66 @@
+package check
+
+import (
+	"my-lang/ast"
+	"strings"
+)
+
+// Functions 已检查的方法 (按定义顺序)
+func (c *Checker) Functions() []*ast.Function {
+	return c.order
+}
+
+// Signature 推导出的方法签名: isPrime(n: int): bool
+// 完全不受约束的类型变量显示为 a, b, c ...
+func (c *Checker) Signature(fn *ast.Function) string {
+	info, ok := c.fns[fn]
+	if !ok {
+		return fn.Signature()
+	}
+
+	names := make(map[*TypeVar]string)
+	typeString := func(t Type) string {
+		t = t.resolve()
+		if t.Var == nil {
+			return t.String()
+		}
+		if typ, ok := t.Var.defaultType(); ok {
+			return typ.String()
+		}
+		if t.Var.kinds != allKinds {
+			return t.Var.kinds.String()
+		}
+		name, ok := names[t.Var]
+		if !ok {
+			name = string(rune('a' + len(names)))
+			names[t.Var] = name
+		}
+		return name
+	}
+
+	var sb strings.Builder
+	if fn.Owner != nil {
+		sb.WriteString(fn.Owner.Name)
+		sb.WriteString(".")
+	}
+	sb.WriteString(fn.Name)
+	sb.WriteString("(")
+	for i, arg := range fn.Args {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		if arg.Rest {
+			sb.WriteString("...")
+		}
+		sb.WriteString(arg.Name)
+		sb.WriteString(": ")
+		sb.WriteString(typeString(info.args[i]))
+		if arg.Default != nil {
+			sb.WriteString(" = ")
+			sb.WriteString(arg.DefaultLit)
+		}
+	}
+	sb.WriteString("): ")
+	sb.WriteString(typeString(info.result))
+	return sb.String()
+}
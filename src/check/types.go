@@ -0,0 +1,258 @@
+package check
+
+import (
+	"my-lang/ast"
+	"strings"
+)
+
+// Type 静态类型
+type Type struct {
+	Kind   ast.Type    // ast.INVALID 表示任意类型 (没有注解或者无法确定)
+	Record *ast.Record // Kind 为 ast.RECORD 时的具体类型
+	Var    *TypeVar    // 类型变量 (推导中尚未确定的类型)
+}
+
+// TypeVar 类型变量
+// 没有注解的参数与返回值先用类型变量表示, 随着检查方法体逐步收窄
+type TypeVar struct {
+	ref   *Type   // 已经确定的类型
+	kinds kindSet // 可能的类型
+	hint  kindSet // 运算中遇到过的具体类型, 用于推导默认类型
+	level int     // 创建时所在的方法层数, 用于泛化
+}
+
+// 类型集合 (按 ast.Type 的位)
+type kindSet uint
+
+const allKinds = kindSet(1<<ast.INT | 1<<ast.FLOAT | 1<<ast.STRING | 1<<ast.BOOL | 1<<ast.RECORD | 1<<ast.ERROR | 1<<ast.LIST | 1<<ast.GENERATOR | 1<<ast.CHANNEL | 1<<ast.TASK)
+
+var (
+	Any    = Type{Kind: ast.INVALID}
+	Int    = Type{Kind: ast.INT}
+	Float  = Type{Kind: ast.FLOAT}
+	String = Type{Kind: ast.STRING}
+	Bool   = Type{Kind: ast.BOOL}
+	Error  = Type{Kind: ast.ERROR}
+	List   = Type{Kind: ast.LIST}
+
+	Generator = Type{Kind: ast.GENERATOR}
+	Channel   = Type{Kind: ast.CHANNEL}
+	Task      = Type{Kind: ast.TASK}
+)
+
+// 注解中可以使用的类型名
+var typeNames = map[string]Type{
+	"any":    Any,
+	"int":    Int,
+	"float":  Float,
+	"string": String,
+	"bool":   Bool,
+	"error":  Error,
+	"list":   List,
+
+	"generator": Generator,
+	"channel":   Channel,
+	"task":      Task,
+}
+
+func RecordType(record *ast.Record) Type {
+	return Type{
+		Kind:   ast.RECORD,
+		Record: record,
+	}
+}
+
+// 新的类型变量
+func newVar(level int) Type {
+	return Type{Var: &TypeVar{kinds: allKinds, level: level}}
+}
+
+func kindOf(kind ast.Type) kindSet {
+	return 1 << kind
+}
+
+func (s kindSet) has(kind ast.Type) bool {
+	return s&kindOf(kind) != 0
+}
+
+// 集合里的类型 (按 ast.Type 的顺序)
+func (s kindSet) list() (kinds []ast.Type) {
+	for kind := ast.INT; kind <= ast.TASK; kind++ {
+		if s.has(kind) {
+			kinds = append(kinds, kind)
+		}
+	}
+	return
+}
+
+func (s kindSet) String() string {
+	if s == allKinds {
+		return "any"
+	}
+	names := make([]string, 0)
+	for _, kind := range s.list() {
+		names = append(names, ast.TypeString(kind))
+	}
+	return strings.Join(names, "|")
+}
+
+// 沿着类型变量找到最终的类型
+func (t Type) resolve() Type {
+	for t.Var != nil && t.Var.ref != nil {
+		t = *t.Var.ref
+	}
+	return t
+}
+
+// 可能的类型集合
+func (t Type) kinds() kindSet {
+	t = t.resolve()
+	switch {
+	case t.Var != nil:
+		return t.Var.kinds
+	case t.Kind == ast.INVALID:
+		return allKinds
+	}
+	return kindOf(t.Kind)
+}
+
+func (t Type) IsAny() bool {
+	t = t.resolve()
+	return t.Kind == ast.INVALID && t.Var == nil
+}
+
+func (t Type) String() string {
+	t = t.resolve()
+	switch {
+	case t.Var != nil:
+		return t.Var.kinds.String()
+	case t.Kind == ast.INVALID:
+		return "any"
+	case t.Kind == ast.RECORD && t.Record != nil:
+		return t.Record.Name
+	}
+	return ast.TypeString(t.Kind)
+}
+
+// 确定类型变量
+func (v *TypeVar) bind(t Type) {
+	v.ref = &t
+}
+
+// 收窄类型变量的可能类型, 只剩一种 (非结构体) 类型时直接确定
+func (v *TypeVar) narrow(kinds kindSet) {
+	v.kinds = kinds
+	if kinds := kinds.list(); len(kinds) == 1 && kinds[0] != ast.RECORD {
+		v.bind(Type{Kind: kinds[0]})
+	}
+}
+
+// 推导出的默认类型, 优先使用运算中遇到过的类型
+// 例如 n % 2 中的 n 可以是 int 或 float, 默认为 int
+func (v *TypeVar) defaultType() (Type, bool) {
+	hint := v.hint & v.kinds
+	if hint == kindOf(ast.INT)|kindOf(ast.FLOAT) {
+		return Float, true
+	}
+	if kinds := hint.list(); len(kinds) == 1 && kinds[0] != ast.RECORD {
+		return Type{Kind: kinds[0]}, true
+	}
+	return Any, false
+}
+
+// 是否可以把 from 类型的值赋给 to 类型 (不含类型变量)
+// 1. 任意类型可以互相赋值
+// 2. int 可以隐式转换成 float
+// 3. 子类型可以赋值给父类型
+func assignable(to Type, from Type) bool {
+	to, from = to.resolve(), from.resolve()
+	if to.IsAny() || from.IsAny() || to.Var != nil || from.Var != nil {
+		return true
+	}
+	if to.Kind == ast.FLOAT && from.Kind == ast.INT {
+		return true
+	}
+	if to.Kind == ast.RECORD && from.Kind == ast.RECORD {
+		for record := from.Record; record != nil; record = record.Parent {
+			if record == to.Record {
+				return true
+			}
+		}
+		return false
+	}
+	return to.Kind == from.Kind
+}
+
+// 约束 from 类型的值可以赋给 to 类型, 必要时收窄或者确定类型变量
+func unify(to Type, from Type) bool {
+	to, from = to.resolve(), from.resolve()
+	switch {
+	case to.IsAny() || from.IsAny():
+		return true
+	case to.Var != nil && to.Var == from.Var:
+		return true
+	case to.Var != nil && from.Var != nil:
+		// 两个类型变量合并为一个
+		kinds := to.Var.kinds & from.Var.kinds
+		if kinds == 0 {
+			return false
+		}
+		from.Var.hint |= to.Var.hint
+		if to.Var.level < from.Var.level {
+			from.Var.level = to.Var.level
+		}
+		to.Var.bind(from)
+		from.Var.narrow(kinds)
+		return true
+	case to.Var != nil:
+		if to.Var.kinds.has(from.Kind) {
+			to.Var.bind(from)
+			return true
+		}
+		// int 隐式转换成 float
+		if from.Kind == ast.INT && to.Var.kinds.has(ast.FLOAT) {
+			to.Var.bind(Float)
+			return true
+		}
+		return false
+	case from.Var != nil:
+		kinds := from.Var.kinds & kindOf(to.Kind)
+		if to.Kind == ast.FLOAT {
+			kinds |= from.Var.kinds & kindOf(ast.INT)
+		}
+		if kinds == 0 {
+			return false
+		}
+		if to.Kind == ast.RECORD && to.Record != nil {
+			from.Var.bind(to)
+			return true
+		}
+		from.Var.narrow(kinds)
+		return true
+	}
+	return assignable(to, from)
+}
+
+// 合并多个可能的类型 (例如多个 return 语句)
+func join(types []Type) Type {
+	if len(types) == 0 {
+		return Any
+	}
+
+	result := types[0].resolve()
+	for _, t := range types[1:] {
+		t = t.resolve()
+		switch {
+		case t == result:
+		case result.Var != nil || t.Var != nil:
+			return Any
+		case assignable(result, t) && !result.IsAny() && !t.IsAny():
+			// int, float -> float
+		case assignable(t, result) && !result.IsAny() && !t.IsAny():
+			result = t
+		default:
+			return Any
+		}
+	}
+	return result
+}
@@ -0,0 +1,220 @@
+package cover
+
+import (
+	"fmt"
+	"my-lang/rt"
+	"my-lang/syntax"
+	"sort"
+	"strings"
+)
+
+// 代码覆盖率
+// 解释器只记录执行次数 (rt.Coverage), 源文件中有哪些语句, 分支与方法由完整的语法树得到
+
+type (
+	// File 一个源文件的覆盖率
+	File struct {
+		Path      string
+		Lines     []*Line     // 有语句的行, 按行号排序
+		Functions []*Function // 按位置排序
+		Branches  []*Branch   // 按位置排序
+	}
+
+	// Line 一行的执行次数 (行内有多条语句时为最多的一条)
+	Line struct {
+		Line int
+		Hits int64
+	}
+
+	// Function 方法的调用次数, 类型方法的名字为 类型.方法
+	Function struct {
+		Name  string
+		Line  int
+		Calls int64
+	}
+
+	// Branch if 的 then 与 else 分支, for 的循环体
+	Branch struct {
+		Line    int
+		Block   int    // 所在的 if 或者 for 语句在文件中的编号 (从 0 开始)
+		Index   int    // rt.BranchThen, rt.BranchElse 或者 rt.BranchBody
+		Name    string // then, else 或者 body
+		Reached bool   // 所在的语句是否执行过
+		Hits    int64
+	}
+)
+
+// Report 统计执行过的源文件的覆盖率
+// 源文件有语法错误时无法统计, 返回错误
+func Report(c *rt.Coverage) ([]*File, error) {
+	var files []*File
+	for _, path := range c.Files() {
+		tree, err := syntax.ParseFile(path)
+		if tree == nil {
+			return nil, err
+		}
+		f := &File{Path: path}
+		r := &reporter{c: c, file: f, lines: make(map[int]*Line)}
+		r.nodes(tree.Get("body").([]*syntax.Node), "")
+
+		for _, line := range r.lines {
+			f.Lines = append(f.Lines, line)
+		}
+		sort.Slice(f.Lines, func(i, j int) bool {
+			return f.Lines[i].Line < f.Lines[j].Line
+		})
+		sort.SliceStable(f.Functions, func(i, j int) bool {
+			return f.Functions[i].Line < f.Functions[j].Line
+		})
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+type reporter struct {
+	c     *rt.Coverage
+	file  *File
+	lines map[int]*Line
+}
+
+// 遍历语法树, owner 为类型方法所属的类型
+func (r *reporter) nodes(nodes []*syntax.Node, owner string) {
+	for _, node := range nodes {
+		r.node(node, owner)
+	}
+}
+
+func (r *reporter) node(node *syntax.Node, owner string) {
+	if node == nil {
+		return
+	}
+
+	switch node.Kind {
+	case "FnDef":
+		name, _ := node.Get("name").(string)
+		if owner != "" {
+			name = owner + "." + name
+		}
+		r.file.Functions = append(r.file.Functions, &Function{
+			Name:  name,
+			Line:  node.Pos.Line,
+			Calls: r.c.Calls(node.Pos),
+		})
+		owner = ""
+	case "ImplDef":
+		owner, _ = node.Get("type").(string)
+	case "BadStmt":
+		// 无法解析的语句不会执行
+		return
+	case "IfStmt":
+		r.branch(node, rt.BranchThen, "then")
+		r.branch(node, rt.BranchElse, "else")
+	case "ForStmt":
+		r.branch(node, rt.BranchBody, "body")
+	}
+
+	if strings.HasSuffix(node.Kind, "Stmt") {
+		line := r.lines[node.Pos.Line]
+		if line == nil {
+			line = &Line{Line: node.Pos.Line}
+			r.lines[node.Pos.Line] = line
+		}
+		if hits := r.c.Stmt(node.Pos); hits > line.Hits {
+			line.Hits = hits
+		}
+	}
+
+	for _, f := range node.Fields {
+		switch value := f.Value.(type) {
+		case *syntax.Node:
+			r.node(value, "")
+		case []*syntax.Node:
+			r.nodes(value, owner)
+		}
+	}
+}
+
+func (r *reporter) branch(node *syntax.Node, index int, name string) {
+	block := 0
+	if n := len(r.file.Branches); n > 0 {
+		block = r.file.Branches[n-1].Block
+		if index == 0 {
+			block += 1
+		}
+	}
+	r.file.Branches = append(r.file.Branches, &Branch{
+		Line:    node.Pos.Line,
+		Block:   block,
+		Index:   index,
+		Name:    name,
+		Reached: r.c.Stmt(node.Pos) > 0,
+		Hits:    r.c.Branch(node.Pos, index),
+	})
+}
+
+// Counts 执行过的数量与总数
+type Counts struct {
+	Hit   int
+	Total int
+}
+
+// Percent 百分比, 总数为 0 时为 100
+func (c Counts) Percent() float64 {
+	if c.Total == 0 {
+		return 100
+	}
+	return float64(c.Hit) * 100 / float64(c.Total)
+}
+
+// 3/4 (75.0%)
+func (c Counts) String() string {
+	return fmt.Sprintf("%d/%d (%.1f%%)", c.Hit, c.Total, c.Percent())
+}
+
+// Summary 行, 方法与分支的覆盖率
+type Summary struct {
+	Lines     Counts
+	Functions Counts
+	Branches  Counts
+}
+
+func (s *Summary) add(other Summary) {
+	for _, c := range [][2]*Counts{{&s.Lines, &other.Lines}, {&s.Functions, &other.Functions}, {&s.Branches, &other.Branches}} {
+		c[0].Hit += c[1].Hit
+		c[0].Total += c[1].Total
+	}
+}
+
+// Summary 文件的覆盖率
+func (f *File) Summary() Summary {
+	s := Summary{
+		Lines:     Counts{Total: len(f.Lines)},
+		Functions: Counts{Total: len(f.Functions)},
+		Branches:  Counts{Total: len(f.Branches)},
+	}
+	for _, line := range f.Lines {
+		if line.Hits > 0 {
+			s.Lines.Hit += 1
+		}
+	}
+	for _, fn := range f.Functions {
+		if fn.Calls > 0 {
+			s.Functions.Hit += 1
+		}
+	}
+	for _, b := range f.Branches {
+		if b.Hits > 0 {
+			s.Branches.Hit += 1
+		}
+	}
+	return s
+}
+
+// Total 所有文件合计的覆盖率
+func Total(files []*File) Summary {
+	var s Summary
+	for _, f := range files {
+		s.add(f.Summary())
+	}
+	return s
+}
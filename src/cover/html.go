@@ -0,0 +1,122 @@
+package cover
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"strings"
+)
+
+// 报告中的一行源码
+type htmlLine struct {
+	Line     int
+	Class    string // hit, partial (有分支没有执行), miss 或者空 (没有语句)
+	Hits     string
+	Branches string
+	Source   string
+}
+
+type htmlFile struct {
+	ID      string
+	Path    string
+	Summary Summary
+	Source  []htmlLine
+}
+
+// WriteHTML 输出按行标注执行次数的 HTML 报告
+func WriteHTML(w io.Writer, files []*File) error {
+	var data struct {
+		Files []htmlFile
+		Total Summary
+	}
+	for i, f := range files {
+		src, err := os.ReadFile(f.Path)
+		if err != nil {
+			return err
+		}
+		data.Files = append(data.Files, htmlFile{
+			ID:      fmt.Sprintf("file%d", i),
+			Path:    f.Path,
+			Summary: f.Summary(),
+			Source:  annotate(f, string(src)),
+		})
+	}
+	data.Total = Total(files)
+	return htmlTemplate.Execute(w, data)
+}
+
+// 每一行源码与执行次数, 分支写作 then 3, else 0
+func annotate(f *File, src string) []htmlLine {
+	hits := make(map[int]int64)
+	for _, line := range f.Lines {
+		hits[line.Line] = line.Hits
+	}
+	branches := make(map[int][]string)
+	missed := make(map[int]bool)
+	for _, b := range f.Branches {
+		taken := "-"
+		if b.Reached {
+			taken = fmt.Sprint(b.Hits)
+		}
+		branches[b.Line] = append(branches[b.Line], b.Name+" "+taken)
+		if b.Hits == 0 {
+			missed[b.Line] = true
+		}
+	}
+
+	var lines []htmlLine
+	for i, source := range strings.Split(strings.TrimSuffix(src, "\n"), "\n") {
+		line := htmlLine{Line: i + 1, Source: source}
+		if n, ok := hits[line.Line]; ok {
+			line.Hits = fmt.Sprint(n)
+			switch {
+			case n == 0:
+				line.Class = "miss"
+			case missed[line.Line]:
+				line.Class = "partial"
+			default:
+				line.Class = "hit"
+			}
+		}
+		line.Branches = strings.Join(branches[line.Line], ", ")
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+var htmlTemplate = template.Must(template.New("cover").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>覆盖率</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { padding: 0 0.8em; text-align: left; }
+.summary td, .summary th { border-bottom: 1px solid #ddd; padding: 0.3em 0.8em; }
+.source { font-family: monospace; white-space: pre; margin-bottom: 2em; }
+.source td { padding: 0 0.5em; }
+.source .num, .source .hits { color: #888; text-align: right; }
+.source .branches { color: #888; }
+tr.hit td.code { background: #dfd; }
+tr.partial td.code { background: #ffd; }
+tr.miss td.code { background: #fdd; }
+</style>
+</head>
+<body>
+<h1>覆盖率</h1>
+<table class="summary">
+<tr><th>文件</th><th>行</th><th>方法</th><th>分支</th></tr>
+{{range .Files}}<tr><td><a href="#{{.ID}}">{{.Path}}</a></td><td>{{.Summary.Lines}}</td><td>{{.Summary.Functions}}</td><td>{{.Summary.Branches}}</td></tr>
+{{end}}<tr><th>合计</th><th>{{.Total.Lines}}</th><th>{{.Total.Functions}}</th><th>{{.Total.Branches}}</th></tr>
+</table>
+{{range .Files}}
+<h2 id="{{.ID}}">{{.Path}}</h2>
+<table class="source">
+{{range .Source}}<tr class="{{.Class}}"><td class="num">{{.Line}}</td><td class="hits">{{.Hits}}</td><td class="code">{{.Source}}</td><td class="branches">{{.Branches}}</td></tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+`))
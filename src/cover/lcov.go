@@ -0,0 +1,41 @@
+package cover
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// WriteLCOV 输出 lcov 格式 (genhtml 等工具可以读取)
+func WriteLCOV(w io.Writer, files []*File) error {
+	bw := bufio.NewWriter(w)
+	for _, f := range files {
+		fmt.Fprintf(bw, "TN:\nSF:%s\n", f.Path)
+		summary := f.Summary()
+
+		for _, fn := range f.Functions {
+			fmt.Fprintf(bw, "FN:%d,%s\n", fn.Line, fn.Name)
+		}
+		for _, fn := range f.Functions {
+			fmt.Fprintf(bw, "FNDA:%d,%s\n", fn.Calls, fn.Name)
+		}
+		fmt.Fprintf(bw, "FNF:%d\nFNH:%d\n", summary.Functions.Total, summary.Functions.Hit)
+
+		// 所在的语句没有执行过的分支记为 -
+		for _, b := range f.Branches {
+			taken := "-"
+			if b.Reached {
+				taken = fmt.Sprint(b.Hits)
+			}
+			fmt.Fprintf(bw, "BRDA:%d,%d,%d,%s\n", b.Line, b.Block, b.Index, taken)
+		}
+		fmt.Fprintf(bw, "BRF:%d\nBRH:%d\n", summary.Branches.Total, summary.Branches.Hit)
+
+		for _, line := range f.Lines {
+			fmt.Fprintf(bw, "DA:%d,%d\n", line.Line, line.Hits)
+		}
+		fmt.Fprintf(bw, "LF:%d\nLH:%d\n", summary.Lines.Total, summary.Lines.Hit)
+		fmt.Fprintf(bw, "end_of_record\n")
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,160 @@
+package cst
+
+import (
+	"io"
+	"my-lang/token"
+	"strings"
+)
+
+// 具体语法树 (Concrete Syntax Tree)
+// 无损地保留源码里的每一个字符: 按括号与语句分组的 token, 空白与注释在 token 的 Trivia 里
+// 语义分析使用的 token 序列由 File.Tokens 得到, 与直接扫描源码的结果相同
+
+type (
+	Node interface {
+		// 依次调用节点内的 token (按源码顺序)
+		each(fn func(tok token.Token))
+	}
+
+	// File 源文件
+	File struct {
+		Stmts []*Stmt
+		EOF   token.Token // 文件末尾的空白与注释在 EOF 的 Leading 里
+	}
+
+	// Stmt 语句: 到换行或者分号为止 (括号内的换行不算)
+	// else, catch, finally 开头的行属于上一条语句
+	Stmt struct {
+		Kind  Kind
+		Nodes []Node
+		End   *token.Token // 结尾的换行或者分号, 文件最后一条语句可能没有
+	}
+
+	// Group 括号: (...), [...] 里是 token 与括号, {...} 里是语句
+	Group struct {
+		Open  token.Token
+		Nodes []Node       // ( 与 [ 内的节点
+		Stmts []*Stmt      // { 内的语句
+		Close *token.Token // 没有闭合时为 nil
+	}
+
+	// Leaf 单个 token
+	Leaf struct {
+		Token token.Token
+	}
+)
+
+// Kind 语句的类型 (根据开头的 token 判断)
+type Kind int
+
+const (
+	EmptyStmt  Kind = iota // 空行
+	ExprStmt               // f(x)
+	AssignStmt             // a = 1
+	FnDef                  // f(x) = ...
+	TypeDef                // type Point { ... }
+	ImplDef                // impl Point { ... }
+	IfStmt
+	ForStmt
+	TryStmt
+	SelectStmt
+	ReturnStmt
+	PrintStmt
+	ThrowStmt
+	YieldStmt
+	ImportStmt // import m, from m import a
+	TestStmt   // test "name" { ... }
+)
+
+var kinds = [...]string{
+	EmptyStmt:  "EmptyStmt",
+	ExprStmt:   "ExprStmt",
+	AssignStmt: "AssignStmt",
+	FnDef:      "FnDef",
+	TypeDef:    "TypeDef",
+	ImplDef:    "ImplDef",
+	IfStmt:     "IfStmt",
+	ForStmt:    "ForStmt",
+	TryStmt:    "TryStmt",
+	SelectStmt: "SelectStmt",
+	ReturnStmt: "ReturnStmt",
+	PrintStmt:  "PrintStmt",
+	ThrowStmt:  "ThrowStmt",
+	YieldStmt:  "YieldStmt",
+	ImportStmt: "ImportStmt",
+	TestStmt:   "TestStmt",
+}
+
+func (k Kind) String() string {
+	return kinds[k]
+}
+
+func (f *File) each(fn func(tok token.Token)) {
+	for _, stmt := range f.Stmts {
+		stmt.each(fn)
+	}
+	fn(f.EOF)
+}
+
+func (s *Stmt) each(fn func(tok token.Token)) {
+	for _, node := range s.Nodes {
+		node.each(fn)
+	}
+	if s.End != nil {
+		fn(*s.End)
+	}
+}
+
+func (g *Group) each(fn func(tok token.Token)) {
+	fn(g.Open)
+	for _, node := range g.Nodes {
+		node.each(fn)
+	}
+	for _, stmt := range g.Stmts {
+		stmt.each(fn)
+	}
+	if g.Close != nil {
+		fn(*g.Close)
+	}
+}
+
+func (l *Leaf) each(fn func(tok token.Token)) {
+	fn(l.Token)
+}
+
+// Tokens 节点内的 token (不含 EOF), 用于语法分析
+func Tokens(node Node) (toks []token.Token) {
+	node.each(func(tok token.Token) {
+		if tok.Type != token.EOF {
+			toks = append(toks, tok)
+		}
+	})
+	return
+}
+
+// Write 输出节点的源码, 整个文件的输出与原来的源码完全相同
+func Write(w io.Writer, node Node) error {
+	var err error
+	node.each(func(tok token.Token) {
+		if err == nil && tok.Trivia != nil {
+			_, err = io.WriteString(w, tok.Trivia.Leading+tok.Trivia.Text+tok.Trivia.Trailing)
+		}
+	})
+	return err
+}
+
+// String 节点的源码
+func String(node Node) string {
+	var sb strings.Builder
+	Write(&sb, node)
+	return sb.String()
+}
+
+// Tokens 语义分析使用的 token 序列
+func (f *File) Tokens() []token.Token {
+	return Tokens(f)
+}
+
+func (f *File) String() string {
+	return String(f)
+}
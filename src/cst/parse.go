@@ -0,0 +1,230 @@
+package cst
+
+import (
+	"my-lang/token"
+)
+
+// Parse 无损地扫描源码并按括号与语句分组
+// 词法错误不影响树的构建 (不合法的字符留在 Trivia 里), 括号不匹配时 Group.Close 为 nil
+func Parse(file string, src []byte) (*File, []token.Error) {
+	scanner := token.NewSourceScanner(file, src).Lossless()
+	p := &parser{toks: scanner.ScanTokens()}
+
+	f := &File{}
+	f.Stmts = p.stmts(false)
+	f.EOF = p.peek()
+	return f, scanner.Errors()
+}
+
+type parser struct {
+	toks   []token.Token // 以 EOF 结尾
+	offset int
+}
+
+func (p *parser) peek() token.Token {
+	return p.toks[p.offset]
+}
+
+func (p *parser) next() token.Token {
+	tok := p.toks[p.offset]
+	if tok.Type != token.EOF {
+		p.offset += 1
+	}
+	return tok
+}
+
+// 语句序列, 直到文件结尾或者 (inBrace 时) 右大括号
+func (p *parser) stmts(inBrace bool) (stmts []*Stmt) {
+	for {
+		switch p.peek().Type {
+		case token.EOF:
+			return
+		case token.RBRACE:
+			if inBrace {
+				return
+			}
+		}
+
+		stmt := p.stmt(inBrace)
+		if len(stmt.Nodes) > 0 && continues(stmt.Nodes[0]) {
+			stmts = join(stmts, stmt)
+			continue
+		}
+		stmts = append(stmts, stmt)
+	}
+}
+
+// 是否是 else, catch, finally (接在上一条语句后面)
+func continues(node Node) bool {
+	leaf, ok := node.(*Leaf)
+	if !ok {
+		return false
+	}
+	switch leaf.Token.Type {
+	case token.ELSE, token.CATCH, token.FINALLY:
+		return true
+	}
+	return false
+}
+
+// 把 stmt 接到最后一条非空语句后面, 中间的换行成为普通的 token
+func join(stmts []*Stmt, stmt *Stmt) []*Stmt {
+	last := len(stmts) - 1
+	for last >= 0 && stmts[last].Kind == EmptyStmt {
+		last -= 1
+	}
+	if last < 0 {
+		return append(stmts, stmt)
+	}
+
+	prev := stmts[last]
+	for _, s := range stmts[last:] {
+		if s != prev {
+			prev.Nodes = append(prev.Nodes, s.Nodes...)
+		}
+		if s.End != nil {
+			prev.Nodes = append(prev.Nodes, &Leaf{Token: *s.End})
+		}
+	}
+	prev.Nodes = append(prev.Nodes, stmt.Nodes...)
+	prev.End = stmt.End
+	return stmts[:last+1]
+}
+
+// 一条语句
+func (p *parser) stmt(inBrace bool) *Stmt {
+	stmt := &Stmt{}
+	defer func() {
+		stmt.Kind = classify(stmt.Nodes)
+	}()
+
+	for {
+		tok := p.peek()
+		switch tok.Type {
+		case token.EOF:
+			return stmt
+		case token.LINEBREAK, token.SEMICOLON:
+			p.next()
+			stmt.End = &tok
+			return stmt
+		case token.RBRACE:
+			if inBrace {
+				return stmt
+			}
+			// 多余的右括号
+			stmt.Nodes = append(stmt.Nodes, &Leaf{Token: p.next()})
+		case token.LPAREN, token.LBRACK, token.LBRACE:
+			stmt.Nodes = append(stmt.Nodes, p.group())
+		default:
+			stmt.Nodes = append(stmt.Nodes, &Leaf{Token: p.next()})
+		}
+	}
+}
+
+// 括号
+func (p *parser) group() *Group {
+	g := &Group{Open: p.next()}
+	if g.Open.Type == token.LBRACE {
+		g.Stmts = p.stmts(true)
+		if p.peek().Type == token.RBRACE {
+			tok := p.next()
+			g.Close = &tok
+		}
+		return g
+	}
+
+	closing := token.RPAREN
+	if g.Open.Type == token.LBRACK {
+		closing = token.RBRACK
+	}
+	for {
+		tok := p.peek()
+		switch tok.Type {
+		case closing:
+			p.next()
+			g.Close = &tok
+			return g
+		case token.EOF, token.RBRACE:
+			// 没有闭合, 右大括号留给外层
+			return g
+		case token.LPAREN, token.LBRACK, token.LBRACE:
+			g.Nodes = append(g.Nodes, p.group())
+		default:
+			g.Nodes = append(g.Nodes, &Leaf{Token: p.next()})
+		}
+	}
+}
+
+// 根据开头的 token 判断语句的类型
+func classify(nodes []Node) Kind {
+	if len(nodes) == 0 {
+		return EmptyStmt
+	}
+
+	if leaf, ok := nodes[0].(*Leaf); ok {
+		switch leaf.Token.Type {
+		case token.TYPE:
+			return TypeDef
+		case token.IMPL:
+			return ImplDef
+		case token.IF:
+			return IfStmt
+		case token.FOR:
+			return ForStmt
+		case token.TRY:
+			return TryStmt
+		case token.SELECT:
+			return SelectStmt
+		case token.RETURN:
+			return ReturnStmt
+		case token.PRINT:
+			return PrintStmt
+		case token.THROW:
+			return ThrowStmt
+		case token.YIELD:
+			return YieldStmt
+		case token.IMPORT, token.FROM:
+			return ImportStmt
+		case token.IDENTITY:
+			// test 不是关键字, 后面是字符串时才是测试块
+			if leaf.Token.Lit == "test" && len(nodes) > 1 && isToken(nodes[1], token.STRINGLIT) {
+				return TestStmt
+			}
+		}
+	}
+
+	// f(x) = ... 或者 f(x): int = ...
+	if len(nodes) >= 3 && isToken(nodes[0], token.IDENTITY) && isGroup(nodes[1], token.LPAREN) {
+		if isToken(nodes[2], token.ASSIGN) || (len(nodes) >= 5 && isToken(nodes[2], token.COLON) && isToken(nodes[4], token.ASSIGN)) {
+			return FnDef
+		}
+	}
+
+	for _, node := range nodes {
+		if isToken(node, token.ASSIGN) {
+			return AssignStmt
+		}
+	}
+	return ExprStmt
+}
+
+func isToken(node Node, t token.Type) bool {
+	leaf, ok := node.(*Leaf)
+	return ok && leaf.Token.Type == t
+}
+
+func isGroup(node Node, open token.Type) bool {
+	group, ok := node.(*Group)
+	return ok && group.Open.Type == open
+}
+
+// Pos 节点的位置 (第一个 token 的位置)
+func Pos(node Node) (pos token.Pos) {
+	found := false
+	node.each(func(tok token.Token) {
+		if !found {
+			pos, found = tok.Pos, true
+		}
+	})
+	return
+}
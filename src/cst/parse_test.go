@@ -0,0 +1,53 @@
+package cst
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// 输出的源码与原来的源码完全相同
+func TestParseRoundTrip(t *testing.T) {
+	tests := map[string]string{
+		"empty":             "",
+		"crlf":              "a = 1\r\nprint a\r\n",
+		"tabs":              "f(x) = {\n\tif x > 0 {\n\t\treturn x\n\t}\n\treturn -x\n}\n",
+		"comments":          "# 注释\na = 1 # 行尾注释\n",
+		"unterminated":      "f(x) = {\n    if x {\n        print x\n",
+		"extra close":       "a = 1\n}\n)\nprint a\n",
+		"unclosed paren":    "print (1 + (2\n",
+		"bad chars":         "a = 1 @ 2\nb = $ ` ~\n",
+		"unterminated str":  "print 'abc\nprint 1\n",
+		"no final newline":  "a = [1, 2,\n  3]",
+		"else on next line": "if a {\n} \n\nelse {\n}\n",
+	}
+
+	files, err := filepath.Glob("../../sample/*.m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	libs, err := filepath.Glob("../../sample/lib/*.m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files = append(files, libs...)
+	if len(files) == 0 {
+		t.Fatal("没有找到示例程序")
+	}
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tests[file] = string(src)
+	}
+
+	for name, src := range tests {
+		t.Run(name, func(t *testing.T) {
+			f, _ := Parse(name, []byte(src))
+			if got := f.String(); got != src {
+				t.Errorf("输出的源码应该是 %q, 实际是 %q", src, got)
+			}
+		})
+	}
+}
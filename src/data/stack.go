@@ -66,3 +66,10 @@ func (s *Stack) Load(newStack *Stack) {
 	copy(newList, newStack.list)
 	s.list = newList
 }
+
+// Elements 从栈底到栈顶的所有元素
+func (s *Stack) Elements() []Element {
+	list := make([]Element, s.Len())
+	copy(list, s.list)
+	return list
+}
@@ -0,0 +1,168 @@
+package debug
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"my-lang/ast"
+	"my-lang/rt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const cliHelp = `命令:
+  b, break <行号|文件:行号|方法名>   设置断点
+  d, delete <行号|文件:行号|方法名>  删除断点
+  breakpoints                      列出断点
+  c, continue                      继续执行
+  s, step                          单步执行 (进入方法)
+  n, next                          单步执行 (不进入方法)
+  o, out                           执行到当前方法返回
+  p, print <表达式>                 在当前作用域求值
+  l, locals                        列出局部变量
+  bt, stack                        显示调用栈
+  q, quit                          结束执行
+  h, help                          显示帮助`
+
+// CLI 命令行调试界面: 暂停时读取命令, 直到继续执行
+type CLI struct {
+	*Debugger
+
+	file    string // 主文件 (断点只写行号时使用)
+	in      *bufio.Scanner
+	out     io.Writer
+	quit    func()              // 结束执行
+	sources map[string][]string // 源文件的内容 (按行)
+}
+
+// NewCLI 调试 file, quit 用于结束执行 (例如取消 rt.Options.Context)
+func NewCLI(file string, in io.Reader, out io.Writer, quit func()) *CLI {
+	c := &CLI{
+		Debugger: New(),
+		file:     file,
+		in:       bufio.NewScanner(in),
+		out:      out,
+		quit:     quit,
+		sources:  make(map[string][]string),
+	}
+	c.StopOnEntry = true
+	c.OnStop = c.stop
+	return c
+}
+
+// 暂停时显示当前位置并读取命令
+func (c *CLI) stop(e *rt.Exec, reason Reason) {
+	c.where(e, reason)
+	for {
+		fmt.Fprint(c.out, "(debug) ")
+		if !c.in.Scan() {
+			// 输入结束时结束执行
+			fmt.Fprintln(c.out)
+			c.quit()
+			return
+		}
+		cmd, arg, _ := strings.Cut(strings.TrimSpace(c.in.Text()), " ")
+		arg = strings.TrimSpace(arg)
+
+		switch cmd {
+		case "":
+		case "c", "continue":
+			c.Continue()
+			return
+		case "s", "step":
+			c.StepIn(e)
+			return
+		case "n", "next":
+			c.StepOver(e)
+			return
+		case "o", "out":
+			c.StepOut(e)
+			return
+		case "q", "quit":
+			c.quit()
+			return
+		case "b", "break":
+			c.breakpoint(arg, true)
+		case "d", "delete":
+			c.breakpoint(arg, false)
+		case "breakpoints":
+			for _, bp := range c.Breakpoints() {
+				fmt.Fprintln(c.out, bp)
+			}
+		case "p", "print":
+			value, err := e.Eval(arg)
+			if err != nil {
+				fmt.Fprintln(c.out, err)
+			} else {
+				fmt.Fprintln(c.out, ast.Repr(value))
+			}
+		case "l", "locals":
+			for _, v := range e.Locals() {
+				fmt.Fprintf(c.out, "%s = %s\n", v.Name, ast.Repr(v.Load()))
+			}
+		case "bt", "stack":
+			stack := e.Stack()
+			for i := len(stack) - 1; i >= 0; i-- {
+				fmt.Fprintf(c.out, "#%d %s\n", len(stack)-1-i, stack[i])
+			}
+		case "h", "help":
+			fmt.Fprintln(c.out, cliHelp)
+		default:
+			fmt.Fprintf(c.out, "未知的命令 %s, 输入 help 查看帮助\n", cmd)
+		}
+	}
+}
+
+// 显示暂停的位置与源码
+func (c *CLI) where(e *rt.Exec, reason Reason) {
+	frame := e.Frame()
+	pos := frame.Pos
+	fmt.Fprintf(c.out, "> %s %s (%s)\n", pos, frame.Name(), reason)
+	if lines := c.source(pos.File); pos.Line <= len(lines) {
+		fmt.Fprintf(c.out, "%4d | %s\n", pos.Line, lines[pos.Line-1])
+	}
+}
+
+// 设置或者删除断点: 10, mod.m:10, fib, Point.norm
+func (c *CLI) breakpoint(arg string, set bool) {
+	if arg == "" {
+		fmt.Fprintln(c.out, "需要行号, 文件:行号或者方法名")
+		return
+	}
+
+	file, lineStr := c.file, arg
+	if i := strings.LastIndex(arg, ":"); i >= 0 {
+		file, lineStr = arg[:i], arg[i+1:]
+	}
+	line, err := strconv.Atoi(lineStr)
+	switch {
+	case err != nil && set:
+		c.SetFunctionBreakpoint(arg)
+		fmt.Fprintf(c.out, "断点: 方法 %s\n", arg)
+	case err != nil:
+		if !c.ClearFunctionBreakpoint(arg) {
+			fmt.Fprintf(c.out, "没有断点 %s\n", arg)
+		}
+	case set:
+		c.SetBreakpoint(file, line)
+		fmt.Fprintf(c.out, "断点: %s:%d\n", file, line)
+	default:
+		if !c.ClearBreakpoint(file, line) {
+			fmt.Fprintf(c.out, "没有断点 %s:%d\n", file, line)
+		}
+	}
+}
+
+// 源文件的内容, 读取失败时为空
+func (c *CLI) source(file string) []string {
+	lines, ok := c.sources[file]
+	if !ok {
+		bytes, err := os.ReadFile(file)
+		if err == nil {
+			lines = strings.Split(string(bytes), "\n")
+		}
+		c.sources[file] = lines
+	}
+	return lines
+}
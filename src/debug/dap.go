@@ -0,0 +1,545 @@
+package debug
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"my-lang/ast"
+	"my-lang/rt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DAP 调试适配器 (Debug Adapter Protocol), 通过 JSON 消息与编辑器通信
+// 收到 launch 与 configurationDone 后在新的 goroutine 里运行脚本
+// 脚本暂停时, 需要读取脚本状态的请求 (stackTrace, variables, evaluate 等) 交给暂停的任务执行
+type DAP struct {
+	*Debugger
+
+	in  *bufio.Reader
+	out io.Writer
+
+	wmu sync.Mutex // 写消息
+	seq int
+
+	mu      sync.Mutex
+	paused  *rt.Exec                 // 暂停的任务 (运行中为 nil)
+	work    chan func(*rt.Exec) bool // 交给暂停的任务执行, 返回 true 时继续执行
+	threads map[*rt.Frame]int        // 任务 -> 线程 id
+	refs    map[int]interface{}      // 变量引用, 只在暂停期间有效
+	ending  bool                     // 正在结束脚本, 不再暂停
+	cancel  context.CancelFunc       // 结束脚本
+	done    chan struct{}            // 脚本已经结束
+
+	program    string
+	launched   bool
+	configured bool
+}
+
+// 收到的请求
+type dapRequest struct {
+	Seq       int             `json:"seq"`
+	Type      string          `json:"type"`
+	Command   string          `json:"command"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+type dapResponse struct {
+	Seq        int         `json:"seq"`
+	Type       string      `json:"type"`
+	RequestSeq int         `json:"request_seq"`
+	Command    string      `json:"command"`
+	Success    bool        `json:"success"`
+	Message    string      `json:"message,omitempty"`
+	Body       interface{} `json:"body,omitempty"`
+}
+
+type dapEvent struct {
+	Seq   int         `json:"seq"`
+	Type  string      `json:"type"`
+	Event string      `json:"event"`
+	Body  interface{} `json:"body,omitempty"`
+}
+
+// 消息体
+type object map[string]interface{}
+
+// 主线程的 id, 其它任务从 2 开始
+const mainThread = 1
+
+func NewDAP(in io.Reader, out io.Writer) *DAP {
+	s := &DAP{
+		Debugger: New(),
+		in:       bufio.NewReader(in),
+		out:      out,
+		work:     make(chan func(*rt.Exec) bool),
+		threads:  make(map[*rt.Frame]int),
+		refs:     make(map[int]interface{}),
+		done:     make(chan struct{}),
+	}
+	s.OnStop = s.stop
+	return s
+}
+
+// Serve 处理请求, 直到 disconnect 或者输入结束
+func (s *DAP) Serve() error {
+	for {
+		req, err := s.read()
+		if err == io.EOF {
+			s.terminate()
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if req.Type != "request" {
+			continue
+		}
+		if !s.handle(req) {
+			return nil
+		}
+	}
+}
+
+// 读取一条消息: Content-Length 头, 空行, JSON
+func (s *DAP) read() (*dapRequest, error) {
+	length := -1
+	for {
+		line, err := s.in.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		if name, value, ok := strings.Cut(line, ":"); ok && strings.EqualFold(name, "Content-Length") {
+			length, err = strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				return nil, fmt.Errorf("不合法的 Content-Length: %s", value)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, errors.New("消息缺少 Content-Length")
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(s.in, data); err != nil {
+		return nil, err
+	}
+	var req dapRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// 写一条消息
+func (s *DAP) write(msg interface{}) {
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+
+	s.seq += 1
+	switch msg := msg.(type) {
+	case *dapResponse:
+		msg.Seq = s.seq
+	case *dapEvent:
+		msg.Seq = s.seq
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(data), data)
+}
+
+func (s *DAP) respond(req *dapRequest, body interface{}) {
+	s.write(&dapResponse{Type: "response", RequestSeq: req.Seq, Command: req.Command, Success: true, Body: body})
+}
+
+func (s *DAP) fail(req *dapRequest, format string, args ...interface{}) {
+	s.write(&dapResponse{Type: "response", RequestSeq: req.Seq, Command: req.Command, Message: fmt.Sprintf(format, args...)})
+}
+
+func (s *DAP) event(name string, body interface{}) {
+	s.write(&dapEvent{Type: "event", Event: name, Body: body})
+}
+
+// 处理请求, 返回 false 时结束
+func (s *DAP) handle(req *dapRequest) bool {
+	var args struct {
+		Program     string `json:"program"`
+		StopOnEntry bool   `json:"stopOnEntry"`
+		Source      struct {
+			Path string `json:"path"`
+		} `json:"source"`
+		Breakpoints []struct {
+			Line int    `json:"line"`
+			Name string `json:"name"`
+		} `json:"breakpoints"`
+		ThreadID           int    `json:"threadId"`
+		FrameID            int    `json:"frameId"`
+		VariablesReference int    `json:"variablesReference"`
+		Expression         string `json:"expression"`
+	}
+	if len(req.Arguments) > 0 {
+		if err := json.Unmarshal(req.Arguments, &args); err != nil {
+			s.fail(req, "参数错误: %s", err)
+			return true
+		}
+	}
+
+	switch req.Command {
+	case "initialize":
+		s.respond(req, object{
+			"supportsConfigurationDoneRequest": true,
+			"supportsFunctionBreakpoints":      true,
+			"supportsEvaluateForHovers":        true,
+			"supportsTerminateRequest":         true,
+		})
+		s.event("initialized", nil)
+	case "launch":
+		if args.Program == "" {
+			s.fail(req, "launch 需要 program 参数")
+			return true
+		}
+		s.program, s.StopOnEntry, s.launched = args.Program, args.StopOnEntry, true
+		s.respond(req, nil)
+		s.start()
+	case "configurationDone":
+		s.configured = true
+		s.respond(req, nil)
+		s.start()
+	case "setBreakpoints":
+		s.ClearFile(args.Source.Path)
+		breakpoints := make([]object, 0)
+		for _, bp := range args.Breakpoints {
+			s.SetBreakpoint(args.Source.Path, bp.Line)
+			breakpoints = append(breakpoints, object{"verified": true, "line": bp.Line})
+		}
+		s.respond(req, object{"breakpoints": breakpoints})
+	case "setFunctionBreakpoints":
+		s.ClearFunctions()
+		breakpoints := make([]object, 0)
+		for _, bp := range args.Breakpoints {
+			s.SetFunctionBreakpoint(bp.Name)
+			breakpoints = append(breakpoints, object{"verified": true})
+		}
+		s.respond(req, object{"breakpoints": breakpoints})
+	case "threads":
+		s.respond(req, object{"threads": s.threadList()})
+	case "stackTrace":
+		s.whilePaused(req, s.stackTrace)
+	case "scopes":
+		s.whilePaused(req, func(e *rt.Exec) (interface{}, error) {
+			return s.scopes(e, args.FrameID)
+		})
+	case "variables":
+		s.whilePaused(req, func(e *rt.Exec) (interface{}, error) {
+			return s.variables(args.VariablesReference)
+		})
+	case "evaluate":
+		s.whilePaused(req, func(e *rt.Exec) (interface{}, error) {
+			return s.evaluate(e, args.FrameID, args.Expression)
+		})
+	case "continue":
+		s.resume(req, object{"allThreadsContinued": true}, func(e *rt.Exec) { s.Continue() })
+	case "next":
+		s.resume(req, nil, s.StepOver)
+	case "stepIn":
+		s.resume(req, nil, s.StepIn)
+	case "stepOut":
+		s.resume(req, nil, s.StepOut)
+	case "pause":
+		s.Pause()
+		s.respond(req, nil)
+	case "terminate":
+		s.terminate()
+		s.respond(req, nil)
+	case "disconnect":
+		s.terminate()
+		s.respond(req, nil)
+		return false
+	default:
+		s.fail(req, "不支持的请求 %s", req.Command)
+	}
+	return true
+}
+
+// launch 与 configurationDone 都收到后开始运行
+func (s *DAP) start() {
+	if !s.launched || !s.configured || s.cancel != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+
+	options := rt.DefaultOptions()
+	options.Context = ctx
+	options.Hook = s.Hook
+	options.Stdout = &dapOutput{s: s, category: "stdout"}
+
+	go func() {
+		defer close(s.done)
+		_, err := rt.ExecuteFile(s.program, options)
+
+		exitCode := 0
+		if err != nil && !errors.Is(err, context.Canceled) {
+			msg := err.Error()
+			if scriptErr, ok := err.(*ast.Error); ok {
+				msg = scriptErr.Traceback()
+			}
+			s.event("output", object{"category": "stderr", "output": msg + "\n"})
+			exitCode = 1
+		}
+		s.event("exited", object{"exitCode": exitCode})
+		s.event("terminated", nil)
+	}()
+}
+
+// 结束脚本
+func (s *DAP) terminate() {
+	if s.cancel == nil {
+		return
+	}
+	s.mu.Lock()
+	s.ending = true
+	s.mu.Unlock()
+
+	s.cancel()
+	s.resume(nil, nil, func(e *rt.Exec) { s.Continue() })
+	<-s.done
+}
+
+// 脚本暂停: 通知编辑器, 然后执行编辑器的请求直到继续执行
+func (s *DAP) stop(e *rt.Exec, reason Reason) {
+	s.mu.Lock()
+	if s.ending {
+		s.mu.Unlock()
+		return
+	}
+	s.paused = e
+	s.refs = make(map[int]interface{})
+	id := s.threadID(e.Root())
+	s.mu.Unlock()
+
+	s.event("stopped", object{"reason": string(reason), "threadId": id, "allThreadsStopped": true})
+	for fn := range s.work {
+		if fn(e) {
+			return
+		}
+	}
+}
+
+// 在暂停的任务里处理请求
+func (s *DAP) whilePaused(req *dapRequest, fn func(e *rt.Exec) (interface{}, error)) {
+	s.mu.Lock()
+	paused := s.paused != nil
+	s.mu.Unlock()
+	if !paused {
+		s.fail(req, "程序正在运行")
+		return
+	}
+
+	s.work <- func(e *rt.Exec) bool {
+		body, err := fn(e)
+		if err != nil {
+			s.fail(req, "%s", err)
+		} else {
+			s.respond(req, body)
+		}
+		return false
+	}
+}
+
+// 继续执行, step 决定之后在哪里暂停
+func (s *DAP) resume(req *dapRequest, body interface{}, step func(e *rt.Exec)) {
+	if req != nil {
+		s.respond(req, body)
+	}
+
+	s.mu.Lock()
+	paused := s.paused != nil
+	s.paused = nil
+	s.mu.Unlock()
+	if paused {
+		s.work <- func(e *rt.Exec) bool {
+			step(e)
+			return true
+		}
+	}
+}
+
+// 任务的线程 id, 调用时需要持有 s.mu
+func (s *DAP) threadID(root *rt.Frame) int {
+	if root.Name() == "<main>" {
+		return mainThread
+	}
+	id, ok := s.threads[root]
+	if !ok {
+		id = len(s.threads) + mainThread + 1
+		s.threads[root] = id
+	}
+	return id
+}
+
+// 主线程与暂停过的任务
+func (s *DAP) threadList() []object {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	threads := []object{{"id": mainThread, "name": "main"}}
+	for root, id := range s.threads {
+		threads = append(threads, object{"id": id, "name": fmt.Sprintf("%s %d", root.Name(), id)})
+	}
+	return threads
+}
+
+// 调用栈, 由内到外, 最内层的 id 为 1
+func (s *DAP) stackTrace(e *rt.Exec) (interface{}, error) {
+	frames := e.Frames()
+	stack := make([]object, 0, len(frames))
+	for i := len(frames) - 1; i >= 0; i-- {
+		frame := frames[i]
+		item := object{
+			"id":     len(frames) - i,
+			"name":   frame.Name(),
+			"line":   frame.Pos.Line,
+			"column": frame.Pos.Col,
+		}
+		if frame.Pos.IsValid() {
+			path, _ := filepath.Abs(frame.Pos.File)
+			item["source"] = object{"name": filepath.Base(path), "path": path}
+		}
+		stack = append(stack, item)
+	}
+	return object{"stackFrames": stack, "totalFrames": len(stack)}, nil
+}
+
+// 栈帧 id 对应的栈帧
+func frameAt(e *rt.Exec, id int) (*rt.Frame, error) {
+	frames := e.Frames()
+	if id < 1 || id > len(frames) {
+		return nil, fmt.Errorf("没有栈帧 %d", id)
+	}
+	return frames[len(frames)-id], nil
+}
+
+// 栈帧的作用域: 最内层为当前可见的局部变量, 外层为方法的参数与变量
+func (s *DAP) scopes(e *rt.Exec, frameID int) (interface{}, error) {
+	frame, err := frameAt(e, frameID)
+	if err != nil {
+		return nil, err
+	}
+
+	vars := make([]*ast.Variable, 0)
+	switch {
+	case frameID == 1:
+		vars = e.Locals()
+	case frame.Objs != nil:
+		vars = frame.Objs.Variables()
+	}
+	scope := object{"name": "Locals", "variablesReference": s.ref(vars), "expensive": false}
+	return object{"scopes": []object{scope}}, nil
+}
+
+// 变量引用对应的变量: 作用域, 列表元素或者结构体字段
+func (s *DAP) variables(ref int) (interface{}, error) {
+	s.mu.Lock()
+	val, ok := s.refs[ref]
+	s.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("没有变量引用 %d", ref)
+	}
+
+	vars := make([]object, 0)
+	switch val := val.(type) {
+	case []*ast.Variable:
+		for _, v := range val {
+			vars = append(vars, s.variable(v.Name, v.Load()))
+		}
+	case *ast.ListValue:
+		for i, element := range val.Values() {
+			vars = append(vars, s.variable(fmt.Sprintf("[%d]", i), element))
+		}
+	case *ast.RecordValue:
+		values := val.Values()
+		for i, name := range val.Type.Fields {
+			vars = append(vars, s.variable(name, values[i]))
+		}
+	}
+	return object{"variables": vars}, nil
+}
+
+// 变量的显示, 列表与结构体可以展开
+func (s *DAP) variable(name string, val interface{}) object {
+	return object{
+		"name":               name,
+		"value":              ast.Repr(val),
+		"type":               ast.TypeName(val),
+		"variablesReference": s.valueRef(val),
+	}
+}
+
+// 求表达式的值
+func (s *DAP) evaluate(e *rt.Exec, frameID int, expr string) (interface{}, error) {
+	var val interface{}
+	var err error
+	if frameID <= 1 {
+		val, err = e.Eval(expr)
+	} else {
+		frame, ferr := frameAt(e, frameID)
+		if ferr != nil {
+			return nil, ferr
+		}
+		if frame.Objs == nil {
+			return nil, fmt.Errorf("栈帧 %s 没有作用域", frame.Name())
+		}
+		val, err = e.EvalIn(frame.Objs, expr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return object{"result": ast.Repr(val), "type": ast.TypeName(val), "variablesReference": s.valueRef(val)}, nil
+}
+
+// 新的变量引用
+func (s *DAP) ref(val interface{}) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := len(s.refs) + 1
+	s.refs[id] = val
+	return id
+}
+
+// 列表与结构体的变量引用, 其它值为 0 (不能展开)
+func (s *DAP) valueRef(val interface{}) int {
+	switch val := val.(type) {
+	case *ast.ListValue:
+		if val.Len() > 0 {
+			return s.ref(val)
+		}
+	case *ast.RecordValue:
+		if len(val.Fields) > 0 {
+			return s.ref(val)
+		}
+	}
+	return 0
+}
+
+// 脚本的输出作为 output 事件发送
+type dapOutput struct {
+	s        *DAP
+	category string
+}
+
+func (o *dapOutput) Write(p []byte) (int, error) {
+	o.s.event("output", object{"category": o.category, "output": string(p)})
+	return len(p), nil
+}
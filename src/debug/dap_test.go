@@ -0,0 +1,326 @@
+package debug
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 测试用的 DAP 客户端: 通过内存管道发送请求, 读取响应与事件
+type dapClient struct {
+	t    *testing.T
+	in   *io.PipeWriter
+	msgs chan map[string]interface{}
+	seq  int
+}
+
+func newDAPClient(t *testing.T) (*dapClient, chan error) {
+	reqR, reqW := io.Pipe()
+	respR, respW := io.Pipe()
+
+	c := &dapClient{t: t, in: reqW, msgs: make(chan map[string]interface{}, 100)}
+	go c.readLoop(respR)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- NewDAP(reqR, respW).Serve()
+		respW.Close()
+	}()
+	return c, done
+}
+
+// 读取 Content-Length 格式的消息
+func (c *dapClient) readLoop(r io.Reader) {
+	defer close(c.msgs)
+	br := bufio.NewReader(r)
+	for {
+		length := -1
+		for {
+			line, err := br.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimSpace(line)
+			if line == "" {
+				break
+			}
+			if name, value, ok := strings.Cut(line, ":"); ok && strings.EqualFold(name, "Content-Length") {
+				length, _ = strconv.Atoi(strings.TrimSpace(value))
+			}
+		}
+		data := make([]byte, length)
+		if _, err := io.ReadFull(br, data); err != nil {
+			return
+		}
+		var msg map[string]interface{}
+		if err := json.Unmarshal(data, &msg); err != nil {
+			c.t.Errorf("不合法的消息 %s: %s", data, err)
+			return
+		}
+		c.msgs <- msg
+	}
+}
+
+func (c *dapClient) send(command string, args interface{}) {
+	c.seq += 1
+	data, err := json.Marshal(map[string]interface{}{
+		"seq":       c.seq,
+		"type":      "request",
+		"command":   command,
+		"arguments": args,
+	})
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if _, err := fmt.Fprintf(c.in, "Content-Length: %d\r\n\r\n%s", len(data), data); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// 读取消息直到 kind (response 或 event) 为 name 的消息, 途中的 output 事件记录在 output 里
+func (c *dapClient) expect(kind string, name string, output *strings.Builder) map[string]interface{} {
+	c.t.Helper()
+	key := map[string]string{"response": "command", "event": "event"}[kind]
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case msg, ok := <-c.msgs:
+			if !ok {
+				c.t.Fatalf("等待 %s %s 时连接已关闭", kind, name)
+			}
+			if msg["type"] == "event" && msg["event"] == "output" && output != nil {
+				output.WriteString(body(msg)["output"].(string))
+			}
+			if msg["type"] != kind || msg[key] != name {
+				continue
+			}
+			if kind == "response" && msg["success"] != true {
+				c.t.Fatalf("%s 失败: %v", name, msg["message"])
+			}
+			return msg
+		case <-timeout:
+			c.t.Fatalf("等待 %s %s 超时", kind, name)
+		}
+	}
+}
+
+// 请求并等待响应
+func (c *dapClient) request(command string, args interface{}) map[string]interface{} {
+	c.t.Helper()
+	c.send(command, args)
+	return body(c.expect("response", command, nil))
+}
+
+func body(msg map[string]interface{}) map[string]interface{} {
+	b, _ := msg["body"].(map[string]interface{})
+	return b
+}
+
+func TestDAP(t *testing.T) {
+	src := `add(a, b) = {
+    c = a + b
+    return c
+}
+xs = [1, 2]
+print add(xs[0], xs[1])
+`
+	program := filepath.Join(t.TempDir(), "main.m")
+	if err := os.WriteFile(program, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, done := newDAPClient(t)
+
+	caps := c.request("initialize", map[string]interface{}{"adapterID": "my-lang"})
+	if caps["supportsConfigurationDoneRequest"] != true {
+		t.Errorf("initialize 没有声明 supportsConfigurationDoneRequest: %v", caps)
+	}
+	c.expect("event", "initialized", nil)
+
+	c.request("launch", map[string]interface{}{"program": program})
+	bps := c.request("setBreakpoints", map[string]interface{}{
+		"source":      map[string]interface{}{"path": program},
+		"breakpoints": []interface{}{map[string]interface{}{"line": 2}},
+	})
+	if list, _ := bps["breakpoints"].([]interface{}); len(list) != 1 || list[0].(map[string]interface{})["verified"] != true {
+		t.Errorf("setBreakpoints 的结果不正确: %v", bps)
+	}
+	c.request("configurationDone", nil)
+
+	// 在 add 的第一行暂停
+	stopped := body(c.expect("event", "stopped", nil))
+	if stopped["reason"] != "breakpoint" || stopped["threadId"] != float64(mainThread) {
+		t.Errorf("stopped 事件不正确: %v", stopped)
+	}
+
+	trace := c.request("stackTrace", map[string]interface{}{"threadId": mainThread})
+	frames, _ := trace["stackFrames"].([]interface{})
+	if len(frames) != 2 {
+		t.Fatalf("调用栈应该有 2 层, 实际是 %v", frames)
+	}
+	top, main := frames[0].(map[string]interface{}), frames[1].(map[string]interface{})
+	if top["name"] != "add" || top["line"] != float64(2) {
+		t.Errorf("最内层的栈帧不正确: %v", top)
+	}
+	if main["name"] != "<main>" || main["line"] != float64(6) {
+		t.Errorf("最外层的栈帧不正确: %v", main)
+	}
+
+	scopes := c.request("scopes", map[string]interface{}{"frameId": top["id"]})
+	list, _ := scopes["scopes"].([]interface{})
+	if len(list) != 1 {
+		t.Fatalf("应该有 1 个作用域, 实际是 %v", scopes)
+	}
+	ref := list[0].(map[string]interface{})["variablesReference"]
+
+	vars := c.request("variables", map[string]interface{}{"variablesReference": ref})
+	values := make(map[string]string)
+	for _, v := range vars["variables"].([]interface{}) {
+		v := v.(map[string]interface{})
+		values[v["name"].(string)] = v["value"].(string)
+	}
+	if values["a"] != "1" || values["b"] != "2" {
+		t.Errorf("局部变量不正确: %v", values)
+	}
+
+	// 继续执行到结束
+	var output strings.Builder
+	c.send("continue", map[string]interface{}{"threadId": mainThread})
+	c.expect("response", "continue", &output)
+	exited := body(c.expect("event", "exited", &output))
+	if exited["exitCode"] != float64(0) {
+		t.Errorf("退出码应该是 0, 实际是 %v", exited["exitCode"])
+	}
+	c.expect("event", "terminated", &output)
+	if output.String() != "3\n" {
+		t.Errorf("输出应该是 3, 实际是 %q", output.String())
+	}
+
+	c.request("disconnect", nil)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Serve 返回错误: %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("disconnect 之后 Serve 没有结束")
+	}
+}
+
+// 暂停时最内层的栈帧
+func (c *dapClient) top() map[string]interface{} {
+	c.t.Helper()
+	trace := c.request("stackTrace", map[string]interface{}{"threadId": mainThread})
+	frames, _ := trace["stackFrames"].([]interface{})
+	if len(frames) == 0 {
+		c.t.Fatalf("调用栈为空: %v", trace)
+	}
+	return frames[0].(map[string]interface{})
+}
+
+// 执行 next 或 stepIn, 等待暂停
+func (c *dapClient) step(command string) {
+	c.t.Helper()
+	c.request(command, map[string]interface{}{"threadId": mainThread})
+	stopped := body(c.expect("event", "stopped", nil))
+	if stopped["reason"] != "step" || stopped["threadId"] != float64(mainThread) {
+		c.t.Errorf("%s 之后的 stopped 事件不正确: %v", command, stopped)
+	}
+}
+
+// 在栈帧上求值
+func (c *dapClient) evaluate(frameID interface{}, expr string) string {
+	c.t.Helper()
+	result := c.request("evaluate", map[string]interface{}{"frameId": frameID, "expression": expr})
+	value, _ := result["result"].(string)
+	return value
+}
+
+func TestDAPStep(t *testing.T) {
+	src := `add(a, b) = {
+    c = a + b
+    return c
+}
+xs = [1, 2]
+y = add(xs[0], xs[1])
+print y
+`
+	program := filepath.Join(t.TempDir(), "main.m")
+	if err := os.WriteFile(program, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, done := newDAPClient(t)
+	c.request("initialize", map[string]interface{}{"adapterID": "my-lang"})
+	c.expect("event", "initialized", nil)
+	c.request("launch", map[string]interface{}{"program": program})
+	c.request("setBreakpoints", map[string]interface{}{
+		"source":      map[string]interface{}{"path": program},
+		"breakpoints": []interface{}{map[string]interface{}{"line": 5}},
+	})
+	c.request("configurationDone", nil)
+	c.expect("event", "stopped", nil)
+
+	threads, _ := c.request("threads", nil)["threads"].([]interface{})
+	if len(threads) != 1 {
+		t.Fatalf("应该只有 1 个线程, 实际是 %v", threads)
+	}
+	if thread := threads[0].(map[string]interface{}); thread["id"] != float64(mainThread) || thread["name"] != "main" {
+		t.Errorf("主线程不正确: %v", thread)
+	}
+
+	// next 跳过赋值, 停在调用 add 的一行
+	c.step("next")
+	top := c.top()
+	if top["name"] != "<main>" || top["line"] != float64(6) {
+		t.Errorf("next 之后的栈帧不正确: %v", top)
+	}
+	if value := c.evaluate(top["id"], "xs[0] + xs[1]"); value != "3" {
+		t.Errorf("xs[0] + xs[1] 应该是 3, 实际是 %q", value)
+	}
+
+	// stepIn 进入 add
+	c.step("stepIn")
+	top = c.top()
+	if top["name"] != "add" || top["line"] != float64(2) {
+		t.Errorf("stepIn 之后的栈帧不正确: %v", top)
+	}
+	if value := c.evaluate(top["id"], "a * 10 + b"); value != "12" {
+		t.Errorf("a * 10 + b 应该是 12, 实际是 %q", value)
+	}
+
+	// next 在 add 内部前进一行
+	c.step("next")
+	top = c.top()
+	if top["name"] != "add" || top["line"] != float64(3) {
+		t.Errorf("第二次 next 之后的栈帧不正确: %v", top)
+	}
+	if value := c.evaluate(top["id"], "c"); value != "3" {
+		t.Errorf("c 应该是 3, 实际是 %q", value)
+	}
+
+	var output strings.Builder
+	c.send("continue", map[string]interface{}{"threadId": mainThread})
+	c.expect("response", "continue", &output)
+	c.expect("event", "terminated", &output)
+	if output.String() != "3\n" {
+		t.Errorf("输出应该是 3, 实际是 %q", output.String())
+	}
+
+	c.request("disconnect", nil)
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Serve 返回错误: %s", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("disconnect 之后 Serve 没有结束")
+	}
+}
@@ -0,0 +1,270 @@
+package debug
+
+import (
+	"fmt"
+	"my-lang/ast"
+	"my-lang/rt"
+	"my-lang/token"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Reason 暂停的原因 (与 DAP 的 stopped 事件一致)
+type Reason string
+
+const (
+	ReasonEntry      Reason = "entry"
+	ReasonStep       Reason = "step"
+	ReasonBreakpoint Reason = "breakpoint"
+	ReasonFunction   Reason = "function breakpoint"
+	ReasonPause      Reason = "pause"
+)
+
+// 单步执行的方式
+type stepMode int
+
+const (
+	modeContinue stepMode = iota
+	modeStep              // 停在下一条语句
+	modeNext              // 停在当前方法或者外层的下一条语句
+	modeOut               // 停在外层的下一条语句
+)
+
+// Debugger 源码级调试器
+// 作为 rt.Options.Hook 在每条语句执行前检查断点与单步, 需要暂停时调用 OnStop, OnStop 返回后继续执行
+// 同一时刻只有一个任务暂停, 其它任务执行到下一条语句时等待
+type Debugger struct {
+	// 暂停时调用, 在其中调用 Continue, StepIn, StepOver 或者 StepOut 决定之后如何继续 (默认为 Continue)
+	OnStop func(e *rt.Exec, reason Reason)
+
+	// 在第一条语句前暂停
+	StopOnEntry bool
+
+	mu      sync.Mutex
+	mode    stepMode
+	root    *rt.Frame // 单步执行所在的任务
+	depth   int       // 单步执行开始时的调用深度
+	started bool
+	prev    map[*rt.Frame]line // 每个任务上一条语句所在的行
+
+	pause atomic.Bool // 在下一条语句暂停
+
+	bpMu  sync.RWMutex
+	lines map[int][]string // 断点: 行号 -> 文件
+	fns   map[string]bool  // 方法断点: 方法名 (类型方法为 Type.method)
+}
+
+// 语句所在的行与调用深度, 同一行的多条语句只暂停一次
+type line struct {
+	file  string
+	line  int
+	depth int
+}
+
+func New() *Debugger {
+	return &Debugger{
+		prev:  make(map[*rt.Frame]line),
+		lines: make(map[int][]string),
+		fns:   make(map[string]bool),
+	}
+}
+
+// Hook 每条语句执行前调用, 作为 rt.Options.Hook 使用
+func (d *Debugger) Hook(e *rt.Exec, stmt ast.Stmt) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	pos := stmt.Pos()
+	cur := line{pos.File, pos.Line, e.Depth()}
+	newLine := d.prev[e.Root()] != cur
+	d.prev[e.Root()] = cur
+
+	reason, ok := d.check(e, pos, newLine)
+	if !ok {
+		return
+	}
+	d.mode = modeContinue
+	if d.OnStop != nil {
+		d.OnStop(e, reason)
+	}
+}
+
+// 是否需要暂停
+func (d *Debugger) check(e *rt.Exec, pos token.Pos, newLine bool) (Reason, bool) {
+	if !d.started {
+		d.started = true
+		if d.StopOnEntry {
+			return ReasonEntry, true
+		}
+	}
+	if d.pause.CompareAndSwap(true, false) {
+		return ReasonPause, true
+	}
+
+	frame := e.Frame()
+	if frame.Fn != nil && frame.Entered() && d.hasFunction(frame.Name()) {
+		return ReasonFunction, true
+	}
+	if !newLine {
+		return "", false
+	}
+
+	switch d.mode {
+	case modeStep:
+		return ReasonStep, true
+	case modeNext:
+		if e.Root() == d.root && e.Depth() <= d.depth {
+			return ReasonStep, true
+		}
+	case modeOut:
+		if e.Root() == d.root && e.Depth() < d.depth {
+			return ReasonStep, true
+		}
+	}
+
+	if d.hasBreakpoint(pos) {
+		return ReasonBreakpoint, true
+	}
+	return "", false
+}
+
+// Continue 继续执行到下一个断点
+func (d *Debugger) Continue() {
+	d.mode = modeContinue
+}
+
+// StepIn 执行到下一条语句 (包括进入调用的方法)
+func (d *Debugger) StepIn(e *rt.Exec) {
+	d.step(e, modeStep)
+}
+
+// StepOver 执行到当前方法的下一条语句
+func (d *Debugger) StepOver(e *rt.Exec) {
+	d.step(e, modeNext)
+}
+
+// StepOut 执行到调用当前方法的地方
+func (d *Debugger) StepOut(e *rt.Exec) {
+	d.step(e, modeOut)
+}
+
+func (d *Debugger) step(e *rt.Exec, mode stepMode) {
+	d.mode, d.root, d.depth = mode, e.Root(), e.Depth()
+}
+
+// Pause 在下一条语句暂停 (可以在其它 goroutine 里调用)
+func (d *Debugger) Pause() {
+	d.pause.Store(true)
+}
+
+// SetBreakpoint 在 file 的第 line 行设置断点, file 可以只写文件名
+func (d *Debugger) SetBreakpoint(file string, line int) {
+	d.bpMu.Lock()
+	defer d.bpMu.Unlock()
+	for _, f := range d.lines[line] {
+		if f == file {
+			return
+		}
+	}
+	d.lines[line] = append(d.lines[line], file)
+}
+
+// ClearBreakpoint 删除断点, 没有这个断点时返回 false
+func (d *Debugger) ClearBreakpoint(file string, line int) bool {
+	d.bpMu.Lock()
+	defer d.bpMu.Unlock()
+	for i, f := range d.lines[line] {
+		if f == file {
+			d.lines[line] = append(d.lines[line][:i:i], d.lines[line][i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// ClearFile 删除 file 里的所有断点
+func (d *Debugger) ClearFile(file string) {
+	d.bpMu.Lock()
+	defer d.bpMu.Unlock()
+	for line, files := range d.lines {
+		kept := make([]string, 0, len(files))
+		for _, f := range files {
+			if f != file {
+				kept = append(kept, f)
+			}
+		}
+		d.lines[line] = kept
+	}
+}
+
+// SetFunctionBreakpoint 在进入方法时暂停, 类型方法写作 Type.method
+func (d *Debugger) SetFunctionBreakpoint(name string) {
+	d.bpMu.Lock()
+	defer d.bpMu.Unlock()
+	d.fns[name] = true
+}
+
+// ClearFunctionBreakpoint 删除方法断点, 没有这个断点时返回 false
+func (d *Debugger) ClearFunctionBreakpoint(name string) bool {
+	d.bpMu.Lock()
+	defer d.bpMu.Unlock()
+	ok := d.fns[name]
+	delete(d.fns, name)
+	return ok
+}
+
+// ClearFunctions 删除所有方法断点
+func (d *Debugger) ClearFunctions() {
+	d.bpMu.Lock()
+	defer d.bpMu.Unlock()
+	d.fns = make(map[string]bool)
+}
+
+// Breakpoints 所有断点: file:line 与方法名
+func (d *Debugger) Breakpoints() []string {
+	d.bpMu.RLock()
+	defer d.bpMu.RUnlock()
+	list := make([]string, 0)
+	for line, files := range d.lines {
+		for _, f := range files {
+			list = append(list, fmt.Sprintf("%s:%d", f, line))
+		}
+	}
+	for name := range d.fns {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+	return list
+}
+
+func (d *Debugger) hasBreakpoint(pos token.Pos) bool {
+	d.bpMu.RLock()
+	defer d.bpMu.RUnlock()
+	for _, f := range d.lines[pos.Line] {
+		if samePath(f, pos.File) {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Debugger) hasFunction(name string) bool {
+	d.bpMu.RLock()
+	defer d.bpMu.RUnlock()
+	return d.fns[name]
+}
+
+// 断点的文件与语句所在的文件是否相同, 断点可以只写文件名
+func samePath(bp string, file string) bool {
+	if bp == file {
+		return true
+	}
+	if filepath.Base(bp) == bp {
+		return filepath.Base(file) == bp
+	}
+	a, errA := filepath.Abs(bp)
+	b, errB := filepath.Abs(file)
+	return errA == nil && errB == nil && a == b
+}
@@ -0,0 +1,177 @@
+package format
+
+import (
+	"my-lang/ast"
+	"my-lang/token"
+	"strings"
+	"unicode"
+)
+
+// 缩进
+const indent = "    "
+
+// Source 把源码整理成统一的格式, 保留注释与原有的换行
+//
+//  1. 语法块内每层缩进 4 个空格, 括号内换行的续行多缩进一层
+//  2. 二元运算符与 = 两边各一个空格, 逗号与冒号之后一个空格
+//  3. 方法体只有一条 return 语句时写成一行: f(x) = x * 2
+//  4. 连续的空行合并为一行, 去掉语法块开头与结尾的空行
+//
+// 源码有词法错误或者括号不匹配时返回 *ast.Error
+func Source(file string, src []byte) ([]byte, error) {
+	scanner := token.NewSourceScanner(file, src).KeepComments()
+	toks := scanner.ScanTokens()
+	if errs := scanner.Errors(); len(errs) > 0 {
+		return nil, syntaxError(errs[0].Pos, "%s", errs[0].Msg)
+	}
+	if err := checkBrackets(toks); err != nil {
+		return nil, err
+	}
+
+	toks = oneLineFns(toks)
+	p := &printer{
+		lines: strings.Split(string(src), "\n"),
+	}
+	p.print(toks)
+	out := []byte(p.out.String())
+
+	// 格式化只能改变空白, 否则是格式化程序的错误
+	if !sameTokens(toks, token.NewSourceScanner(file, out).KeepComments().ScanTokens()) {
+		return nil, syntaxError(token.Pos{File: file}, "格式化改变了源码的内容")
+	}
+	return out, nil
+}
+
+func syntaxError(pos token.Pos, format string, args ...interface{}) *ast.Error {
+	err := ast.NewError(ast.SyntaxError, format, args...)
+	err.Pos = pos
+	return err
+}
+
+// 检查括号是否匹配
+func checkBrackets(toks []token.Token) error {
+	var stack []token.Token
+	for _, tok := range toks {
+		switch tok.Type {
+		case token.LPAREN, token.LBRACK, token.LBRACE:
+			stack = append(stack, tok)
+		case token.RPAREN, token.RBRACK, token.RBRACE:
+			if len(stack) == 0 || closing(stack[len(stack)-1].Type) != tok.Type {
+				return syntaxError(tok.Pos, "多余的 %s", tok.Text())
+			}
+			stack = stack[:len(stack)-1]
+		}
+	}
+	if len(stack) > 0 {
+		tok := stack[len(stack)-1]
+		return syntaxError(tok.Pos, "%s 没有匹配的 %s", tok.Text(), token.Token{Type: closing(tok.Type)}.Text())
+	}
+	return nil
+}
+
+// 左括号对应的右括号
+func closing(open token.Type) token.Type {
+	switch open {
+	case token.LPAREN:
+		return token.RPAREN
+	case token.LBRACK:
+		return token.RBRACK
+	case token.LBRACE:
+		return token.RBRACE
+	}
+	return token.EOF
+}
+
+// 与 toks[i] 的左括号匹配的右括号下标
+func matching(toks []token.Token, i int) int {
+	level := 0
+	for j := i; j < len(toks); j++ {
+		switch toks[j].Type {
+		case token.LPAREN, token.LBRACK, token.LBRACE:
+			level += 1
+		case token.RPAREN, token.RBRACK, token.RBRACE:
+			level -= 1
+			if level == 0 {
+				return j
+			}
+		}
+	}
+	return len(toks)
+}
+
+// 方法体只有一条 return 语句时改写成一行的形式
+// f(x) = { return x * 2 } -> f(x) = x * 2
+func oneLineFns(toks []token.Token) []token.Token {
+	result := make([]token.Token, 0, len(toks))
+	for i := 0; i < len(toks); i++ {
+		result = append(result, toks[i])
+		if toks[i].Type != token.ASSIGN || !isFnDef(toks[:i]) || i+1 >= len(toks) || toks[i+1].Type != token.LBRACE {
+			continue
+		}
+
+		end := matching(toks, i+1)
+		body := trimLineBreaks(toks[i+2 : end])
+		if len(body) < 2 || body[0].Type != token.RETURN || body[1].Type == token.LBRACE {
+			continue
+		}
+		simple := true
+		for _, tok := range body[1:] {
+			switch tok.Type {
+			case token.LINEBREAK, token.SEMICOLON, token.COMMENT, token.RETURN:
+				simple = false
+			}
+		}
+		if !simple {
+			continue
+		}
+
+		result = append(result, body[1:]...)
+		i = end
+	}
+	return result
+}
+
+// = 之前是否是方法签名: f(...) 或者 f(...): int
+func isFnDef(toks []token.Token) bool {
+	n := len(toks)
+	if n >= 3 && toks[n-2].Type == token.COLON && toks[n-1].Type == token.IDENTITY {
+		n -= 2
+	}
+	return n > 0 && toks[n-1].Type == token.RPAREN
+}
+
+func trimLineBreaks(toks []token.Token) []token.Token {
+	for len(toks) > 0 && toks[0].Type == token.LINEBREAK {
+		toks = toks[1:]
+	}
+	for len(toks) > 0 && toks[len(toks)-1].Type == token.LINEBREAK {
+		toks = toks[:len(toks)-1]
+	}
+	return toks
+}
+
+// 除了换行以外的 token 是否一致
+func sameTokens(toks1 []token.Token, toks2 []token.Token) bool {
+	filter := func(toks []token.Token) (result []token.Token) {
+		for _, tok := range toks {
+			if tok.Type != token.LINEBREAK {
+				result = append(result, tok)
+			}
+		}
+		return
+	}
+	toks1, toks2 = filter(toks1), filter(toks2)
+	if len(toks1) != len(toks2) {
+		return false
+	}
+	for i := range toks1 {
+		lit1, lit2 := toks1[i].Lit, toks2[i].Lit
+		if toks1[i].Type == token.COMMENT {
+			lit1, lit2 = strings.TrimRightFunc(lit1, unicode.IsSpace), strings.TrimRightFunc(lit2, unicode.IsSpace)
+		}
+		if toks1[i].Type != toks2[i].Type || lit1 != lit2 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,210 @@
+package format
+
+import (
+	"my-lang/token"
+	"strings"
+	"unicode"
+)
+
+// 按行输出 token
+type printer struct {
+	lines []string // 源码各行, 用于取字符串的引号与判断 token 是否紧挨着
+	out   strings.Builder
+
+	indents []int           // 未闭合的括号所在行的缩进 + 1
+	braces  []bool          // 未闭合的 { 是否是结构体字面量 Point{x: 1}
+	records map[string]bool // 本文件定义的类型名
+}
+
+func (p *printer) print(toks []token.Token) {
+	p.records = make(map[string]bool)
+	for i := 1; i < len(toks); i++ {
+		if toks[i-1].Type == token.TYPE && toks[i].Type == token.IDENTITY {
+			p.records[toks[i].Lit] = true
+		}
+	}
+
+	var line []token.Token
+	blank, printed := 0, false
+	lastOpen := false // 上一行是否以左括号结尾
+
+	for _, tok := range append(toks, token.Token{Type: token.LINEBREAK}) {
+		if tok.Type != token.LINEBREAK {
+			line = append(line, tok)
+			continue
+		}
+		if len(line) == 0 {
+			blank += 1
+			continue
+		}
+
+		// 语法块开头与结尾不留空行
+		if blank > 0 && printed && !lastOpen && !isClose(line[0].Type) {
+			p.out.WriteString("\n")
+		}
+		p.printLine(line)
+		printed, blank = true, 0
+		lastOpen = isOpen(line[len(line)-1].Type)
+		line = nil
+	}
+}
+
+// 输出一行: 行首的右括号先减少缩进
+func (p *printer) printLine(line []token.Token) {
+	i := 0
+	for ; i < len(line) && isClose(line[i].Type); i++ {
+		p.pop()
+	}
+	level := 0
+	if n := len(p.indents); n > 0 {
+		level = p.indents[n-1]
+	}
+	p.out.WriteString(strings.Repeat(indent, level))
+
+	var prev *token.Token
+	unary := false // prev 是否是一元运算符
+	for j, tok := range line {
+		if j >= i {
+			switch {
+			case isOpen(tok.Type):
+				p.indents = append(p.indents, level+1)
+			case isClose(tok.Type):
+				p.pop()
+			}
+		}
+		if tok.Type == token.LBRACE {
+			p.braces = append(p.braces, p.isRecord(line, j))
+		}
+		if prev != nil && p.space(prev, &line[j], unary) {
+			p.out.WriteString(" ")
+		}
+		if n := len(p.braces); tok.Type == token.RBRACE && n > 0 {
+			p.braces = p.braces[:n-1]
+		}
+		p.out.WriteString(p.text(tok))
+		unary = isUnary(tok, prev)
+		prev = &line[j]
+	}
+	p.out.WriteString("\n")
+}
+
+func (p *printer) pop() {
+	if n := len(p.indents); n > 0 {
+		p.indents = p.indents[:n-1]
+	}
+}
+
+// 当前的 { 是否是结构体字面量
+func (p *printer) inRecord() bool {
+	n := len(p.braces)
+	return n > 0 && p.braces[n-1]
+}
+
+// line[j] 的 { 是否是结构体字面量: 前面是本文件定义的类型名, 或者紧挨着名字并且不在 if, for 等语句的条件里
+func (p *printer) isRecord(line []token.Token, j int) bool {
+	if j == 0 || line[j-1].Type != token.IDENTITY {
+		return false
+	}
+	if j >= 2 {
+		switch line[j-2].Type {
+		case token.TYPE, token.IMPL, token.COLON:
+			// type Point {, impl Point {, type Point3: Point {
+			return false
+		}
+	}
+	if p.records[line[j-1].Lit] {
+		return true
+	}
+	for _, tok := range line[:j] {
+		switch tok.Type {
+		case token.IF, token.FOR, token.ELSE, token.CATCH, token.SELECT:
+			return false
+		}
+	}
+	return p.adjacent(&line[j-1], &line[j])
+}
+
+// prev 与 tok 之间是否需要空格, unary 表示 prev 是一元运算符
+func (p *printer) space(prev *token.Token, tok *token.Token, unary bool) bool {
+	if tok.Type == token.COMMENT {
+		return true
+	}
+	if unary {
+		return false
+	}
+	switch prev.Type {
+	case token.LPAREN, token.LBRACK, token.DOT, token.ELLIPSIS:
+		return false
+	case token.LBRACE:
+		return !p.inRecord() || tok.Type == token.RBRACE
+	}
+
+	switch tok.Type {
+	case token.RPAREN, token.RBRACK, token.COMMA, token.SEMICOLON, token.DOT, token.COLON:
+		return false
+	case token.RBRACE:
+		return !p.inRecord()
+	case token.LPAREN:
+		// 调用与定义: f(x)
+		return prev.Type != token.IDENTITY
+	case token.LBRACK:
+		// 下标: a[0], f()[0]
+		switch prev.Type {
+		case token.IDENTITY, token.RPAREN, token.RBRACK, token.STRINGLIT:
+			return false
+		}
+	case token.LBRACE:
+		// 结构体字面量: Point{x: 1}
+		return !p.inRecord()
+	}
+	return true
+}
+
+// tok 是否是一元运算符, prev 是它前面的 token (行首为 nil)
+func isUnary(tok token.Token, prev *token.Token) bool {
+	switch tok.Type {
+	case token.NOT:
+		return true
+	case token.MINUS:
+		if prev == nil {
+			return true
+		}
+		switch prev.Type {
+		case token.IDENTITY, token.INTLIT, token.FLOATLIT, token.STRINGLIT, token.TRUE, token.FALSE,
+			token.RPAREN, token.RBRACK, token.RBRACE:
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+// 两个 token 在源码里是否紧挨着
+func (p *printer) adjacent(prev *token.Token, tok *token.Token) bool {
+	return prev.Line == tok.Line && prev.Col+len([]rune(prev.Text())) == tok.Col
+}
+
+// token 的源码, 字符串的引号从源码里取
+func (p *printer) text(tok token.Token) string {
+	switch tok.Type {
+	case token.STRINGLIT:
+		quote := "'"
+		if tok.Line >= 1 && tok.Line <= len(p.lines) {
+			if runes := []rune(p.lines[tok.Line-1]); tok.Col >= 1 && tok.Col <= len(runes) {
+				quote = string(runes[tok.Col-1])
+			}
+		}
+		return quote + tok.Lit + quote
+	case token.COMMENT:
+		return strings.TrimRightFunc(tok.Lit, unicode.IsSpace)
+	}
+	return tok.Text()
+}
+
+func isOpen(t token.Type) bool {
+	return t == token.LPAREN || t == token.LBRACK || t == token.LBRACE
+}
+
+func isClose(t token.Type) bool {
+	return t == token.RPAREN || t == token.RBRACK || t == token.RBRACE
+}
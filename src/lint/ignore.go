@@ -0,0 +1,79 @@
+package lint
+
+import (
+	"my-lang/cst"
+	"my-lang/token"
+	"strings"
+)
+
+// 注释中忽略规则的指令
+//
+//	x = 1 // lint:ignore unused-var       行尾的注释作用于所在的行
+//	// lint:ignore shadow, unused-param   独占一行的注释作用于下一行代码
+//	// lint:ignore                        没有规则名时忽略所有的规则
+const directive = "lint:ignore"
+
+// 行号 -> 忽略的规则 (空字符串表示所有的规则)
+type ignores map[int][]string
+
+func (ig ignores) has(line int, rule string) bool {
+	for _, name := range ig[line] {
+		if name == "" || name == rule {
+			return true
+		}
+	}
+	return false
+}
+
+// 从源码的注释中找出所有的指令, 注释在具体语法树的 Trivia 里
+func parseIgnores(path string, src []byte) ignores {
+	ig := make(ignores)
+	tree, _ := cst.Parse(path, src)
+
+	var pending []string // 独占一行的注释里的规则, 等待下一行代码
+	for _, tok := range tree.Tokens() {
+		if tok.Trivia == nil {
+			continue
+		}
+		if tok.Type == token.LINEBREAK {
+			// 独占一行的注释在换行的 Leading 里
+			if rules, ok := parseDirective(tok.Trivia.Leading); ok {
+				pending = append(pending, rules...)
+			}
+			continue
+		}
+		if pending != nil {
+			ig[tok.Line] = append(ig[tok.Line], pending...)
+			pending = nil
+		}
+		if rules, ok := parseDirective(tok.Trivia.Trailing); ok {
+			ig[tok.Line] = append(ig[tok.Line], rules...)
+		}
+	}
+	return ig
+}
+
+// 解析注释里的指令, 返回忽略的规则
+func parseDirective(trivia string) ([]string, bool) {
+	i := strings.Index(trivia, "//")
+	if i < 0 {
+		return nil, false
+	}
+	comment := strings.TrimSpace(trivia[i+2:])
+	if !strings.HasPrefix(comment, directive) {
+		return nil, false
+	}
+
+	rest := strings.TrimPrefix(comment, directive)
+	if rest != "" && rest[0] != ' ' && rest[0] != '\t' {
+		// lint:ignored 之类的其它文字
+		return nil, false
+	}
+	rules := strings.FieldsFunc(rest, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	})
+	if len(rules) == 0 {
+		return []string{""}, true
+	}
+	return rules, true
+}
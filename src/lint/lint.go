@@ -0,0 +1,143 @@
+package lint
+
+import (
+	"fmt"
+	"my-lang/ast"
+	"my-lang/check"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Rule 检查规则
+type Rule struct {
+	Name string
+	Doc  string
+}
+
+// Rules 所有的规则
+var Rules = []Rule{
+	{check.RuleUnusedVar, "没有使用的局部变量 (以 _ 开头的名字除外)"},
+	{check.RuleUnusedParam, "没有使用的方法参数 (以 _ 开头的名字除外)"},
+	{check.RuleUnreachable, "return 或者 throw 之后永远不会执行的语句"},
+	{check.RuleOuterAssign, "在方法内赋值修改了外层的变量"},
+	{check.RuleShadow, "参数, 变量或者方法的名字遮蔽了外层的方法"},
+	{check.RuleCompareTypes, "比较的两边可能是不同的类型 (运行时抛出 TypeError)"},
+	{check.RuleConstantCond, "if 与 for 的条件是常量 (for true 除外)"},
+}
+
+// Config 启用的规则
+type Config map[string]bool
+
+// DefaultConfig 启用所有的规则
+func DefaultConfig() Config {
+	config := make(Config)
+	for _, rule := range Rules {
+		config[rule.Name] = true
+	}
+	return config
+}
+
+// ParseConfig 解析启用与禁用的规则列表: "unused-var,shadow", 或者 "all"
+func ParseConfig(enable string, disable string) (Config, error) {
+	config := DefaultConfig()
+	if enable != "all" {
+		config = make(Config)
+		names, err := ruleNames(enable)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			config[name] = true
+		}
+	}
+
+	names, err := ruleNames(disable)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		delete(config, name)
+	}
+	return config, nil
+}
+
+func ruleNames(s string) ([]string, error) {
+	var names []string
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !DefaultConfig()[name] {
+			return nil, fmt.Errorf("未知的规则 %s", name)
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// File 检查源文件
+// 源文件 (或者导入的模块) 有错误时只返回错误, 因为语法块没有完整解析, 规则的结果不可靠
+func File(path string, config Config) ([]*check.Diagnostic, []*check.Finding) {
+	c := check.NewChecker()
+	c.CheckFile(path)
+
+	var errors []*check.Diagnostic
+	for _, d := range c.Sorted() {
+		if !d.Warning {
+			errors = append(errors, d)
+		}
+	}
+	if len(errors) > 0 {
+		return errors, nil
+	}
+
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return []*check.Diagnostic{{Msg: fmt.Sprintf("%s: %s", ast.IOError, err)}}, nil
+	}
+	ignores := parseIgnores(path, src)
+
+	var findings []*check.Finding
+	seen := make(map[check.Finding]bool)
+	for _, f := range append(c.Findings, unused(c, path)...) {
+		if f.Pos.File != path || !config[f.Rule] || ignores.has(f.Pos.Line, f.Rule) || seen[*f] {
+			continue
+		}
+		seen[*f] = true
+		findings = append(findings, f)
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		pi, pj := findings[i].Pos, findings[j].Pos
+		if pi.Line != pj.Line {
+			return pi.Line < pj.Line
+		}
+		return pi.Col < pj.Col
+	})
+	return nil, findings
+}
+
+// 没有使用的局部变量与参数: 只有定义与赋值, 没有读取
+// 顶层的变量可能被其它模块导入, 不检查
+func unused(c *check.Checker, path string) (findings []*check.Finding) {
+	used := make(map[ast.Object]bool)
+	for _, ref := range c.Refs {
+		if !ref.Def && !ref.Assign {
+			used[ref.Obj] = true
+		}
+	}
+
+	for _, ref := range c.Refs {
+		variable, ok := ref.Obj.(*ast.Variable)
+		if !ok || !ref.Def || ref.Top || used[variable] || ref.Pos.File != path || strings.HasPrefix(variable.Name, "_") {
+			continue
+		}
+		if ref.Param {
+			findings = append(findings, &check.Finding{Pos: ref.Pos, Rule: check.RuleUnusedParam, Msg: fmt.Sprintf("参数 %s 没有使用", variable.Name)})
+		} else {
+			findings = append(findings, &check.Finding{Pos: ref.Pos, Rule: check.RuleUnusedVar, Msg: fmt.Sprintf("变量 %s 没有使用", variable.Name)})
+		}
+	}
+	return
+}
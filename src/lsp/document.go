@@ -0,0 +1,365 @@
+package lsp
+
+import (
+	"fmt"
+	"my-lang/ast"
+	"my-lang/check"
+	"my-lang/rt"
+	"my-lang/token"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+)
+
+// 打开的文件与最近一次检查的结果
+type document struct {
+	uri     string
+	path    string
+	lines   []string
+	checker *check.Checker
+}
+
+// 编辑器中的位置 (从 0 开始, character 按 UTF-16 计数)
+type position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+func newDocument(uri string, text string) (doc *document) {
+	doc = &document{
+		uri:     uri,
+		path:    uriPath(uri),
+		lines:   strings.Split(text, "\n"),
+		checker: check.NewChecker(),
+	}
+
+	// 检查器的内部错误不应该让语言服务退出
+	defer func() {
+		if r := recover(); r != nil {
+			doc.checker.Diagnostics = append(doc.checker.Diagnostics, &check.Diagnostic{
+				Pos: token.Pos{File: doc.path},
+				Msg: fmt.Sprintf("内部错误: %v", r),
+			})
+		}
+	}()
+	doc.checker.CheckSource(doc.path, []byte(text))
+	return doc
+}
+
+// file:///a/b.m -> /a/b.m
+func uriPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+// /a/b.m -> file:///a/b.m
+func pathURI(path string) string {
+	u := url.URL{Scheme: "file", Path: filepath.ToSlash(path)}
+	return u.String()
+}
+
+// 诊断只发布本文件的, 被导入模块的问题在打开模块时发布
+func (doc *document) diagnostics() []object {
+	diagnostics := make([]object, 0)
+	for _, d := range doc.checker.Sorted() {
+		if d.Pos.File != doc.path {
+			continue
+		}
+		severity := 1
+		if d.Warning {
+			severity = 2
+		}
+		diagnostics = append(diagnostics, object{
+			"range":    wordRange(doc.lines, d.Pos),
+			"severity": severity,
+			"source":   "my-lang",
+			"message":  d.Msg,
+		})
+	}
+	return diagnostics
+}
+
+// 光标处的名字
+func (doc *document) refAt(at position) *check.Ref {
+	pos := doc.pos(at)
+	for _, ref := range doc.checker.Refs {
+		if ref.Pos.File != doc.path {
+			continue
+		}
+		line, start, end := nameAt(doc.lines, ref.Pos, ref.Name)
+		if line == pos.Line && start <= pos.Col && pos.Col <= end {
+			return ref
+		}
+	}
+	return nil
+}
+
+// 悬停: 变量的推导类型或者方法签名
+func (doc *document) hover(at position) object {
+	ref := doc.refAt(at)
+	if ref == nil {
+		return nil
+	}
+	return object{
+		"contents": object{
+			"kind":  "markdown",
+			"value": "```my-lang\n" + doc.checker.Describe(ref.Obj) + "\n```",
+		},
+		"range": nameRange(doc.lines, ref.Pos, ref.Name),
+	}
+}
+
+// 跳转到定义
+func (s *Server) definition(doc *document, at position) interface{} {
+	ref := doc.refAt(at)
+	if ref == nil {
+		return nil
+	}
+	def := doc.checker.Definition(ref.Obj)
+	if def == nil {
+		return nil
+	}
+	return s.location(def)
+}
+
+// 查找引用
+func (s *Server) references(doc *document, at position, declaration bool) []object {
+	ref := doc.refAt(at)
+	if ref == nil {
+		return nil
+	}
+	locations := make([]object, 0)
+	seen := make(map[token.Pos]bool)
+	for _, ref := range doc.checker.References(ref.Obj) {
+		if (ref.Def && !declaration) || seen[ref.Pos] {
+			continue
+		}
+		seen[ref.Pos] = true
+		locations = append(locations, s.location(ref))
+	}
+	return locations
+}
+
+// 名字所在的位置, 其它文件优先使用编辑器里打开的内容
+func (s *Server) location(ref *check.Ref) object {
+	uri := pathURI(ref.Pos.File)
+	var lines []string
+	if doc, ok := s.docs[uri]; ok {
+		lines = doc.lines
+	} else if src, err := os.ReadFile(ref.Pos.File); err == nil {
+		lines = strings.Split(string(src), "\n")
+	}
+	return object{
+		"uri":   uri,
+		"range": nameRange(lines, ref.Pos, ref.Name),
+	}
+}
+
+// 文件结构: 顶层的方法, 变量, 类型与导入, 类型方法放在类型下面
+func (doc *document) symbols() []object {
+	symbols := make([]object, 0)
+	records := make(map[*ast.Record]object)
+	for _, ref := range doc.checker.Refs {
+		if !ref.Def || !ref.Top || ref.Pos.File != doc.path {
+			continue
+		}
+		r := nameRange(doc.lines, ref.Pos, ref.Name)
+		symbol := object{
+			"name":           ref.Name,
+			"detail":         doc.checker.Describe(ref.Obj),
+			"kind":           symbolKind(ref.Obj),
+			"range":          r,
+			"selectionRange": r,
+		}
+
+		switch obj := ref.Obj.(type) {
+		case *ast.Record:
+			symbol["children"] = make([]object, 0)
+			records[obj] = symbol
+		case *ast.Function:
+			if owner, ok := records[obj.Owner]; ok {
+				owner["children"] = append(owner["children"].([]object), symbol)
+				continue
+			}
+			if obj.Owner != nil {
+				symbol["name"] = obj.Owner.Name + "." + obj.Name
+			}
+		}
+		symbols = append(symbols, symbol)
+	}
+	return symbols
+}
+
+// LSP 的 SymbolKind
+func symbolKind(obj ast.Object) int {
+	switch obj := obj.(type) {
+	case *ast.Function:
+		if obj.Owner != nil {
+			return 6 // Method
+		}
+		return 12 // Function
+	case *ast.Record:
+		return 23 // Struct
+	case *ast.Module:
+		return 2 // Module
+	}
+	return 13 // Variable
+}
+
+// 补全: 光标处对象表链上可见的名字与关键字
+func (doc *document) completion(at position) []object {
+	pos := doc.pos(at)
+	objs := doc.checker.ScopeAt(pos)
+	if objs == nil {
+		objs = rt.Builtins()
+	}
+
+	items := make([]object, 0)
+	seen := make(map[string]bool)
+	for ; objs != nil; objs = objs.Previous() {
+		// 内层的名字覆盖外层的, 同一层里后定义的覆盖先定义的
+		for i := objs.Len() - 1; i > 0; i-- {
+			obj := objs.Get(i)
+			name, kind := completionKind(obj)
+			if name == "" || seen[name] {
+				continue
+			}
+			// 光标之后才定义的名字还不可见
+			if def := doc.checker.Definition(obj); def != nil && def.Pos.File == pos.File && after(def.Pos, pos) {
+				continue
+			}
+			seen[name] = true
+			items = append(items, object{
+				"label":  name,
+				"kind":   kind,
+				"detail": doc.checker.Describe(obj),
+			})
+		}
+	}
+
+	for _, keyword := range token.Keywords {
+		if keyword.Name != "_" && !seen[keyword.Name] {
+			items = append(items, object{"label": keyword.Name, "kind": 14})
+		}
+	}
+	return items
+}
+
+// 补全项的名字与 LSP 的 CompletionItemKind
+func completionKind(obj ast.Object) (string, int) {
+	switch obj := obj.(type) {
+	case *ast.Variable:
+		return obj.Name, 6
+	case *ast.Function:
+		return obj.Name, 3
+	case *ast.Builtin:
+		return obj.Name, 3
+	case *ast.Record:
+		return obj.Name, 22
+	case *ast.Module:
+		return obj.Name, 9
+	}
+	return "", 0
+}
+
+// p1 是否在 p2 之后
+func after(p1 token.Pos, p2 token.Pos) bool {
+	if p1.Line != p2.Line {
+		return p1.Line > p2.Line
+	}
+	return p1.Col > p2.Col
+}
+
+// 编辑器位置 -> 源码位置 (列按字符计数)
+func (doc *document) pos(at position) token.Pos {
+	col := at.Character
+	if at.Line >= 0 && at.Line < len(doc.lines) {
+		col = runeIndex(doc.lines[at.Line], at.Character)
+	}
+	return token.Pos{File: doc.path, Line: at.Line + 1, Col: col + 1}
+}
+
+// 源码位置 -> 编辑器位置
+func editorPos(lines []string, line int, col int) position {
+	at := position{Line: line - 1, Character: col - 1}
+	if at.Line >= 0 && at.Line < len(lines) {
+		runes := []rune(lines[at.Line])
+		if col >= 1 && col-1 <= len(runes) {
+			at.Character = len(utf16.Encode(runes[:col-1]))
+		}
+	}
+	if at.Line < 0 || at.Character < 0 {
+		at = position{}
+	}
+	return at
+}
+
+// UTF-16 偏移 -> 字符下标
+func runeIndex(line string, offset int) int {
+	units := 0
+	for i, r := range []rune(line) {
+		if units >= offset {
+			return i
+		}
+		units += utf16.RuneLen(r)
+	}
+	return len([]rune(line))
+}
+
+// 名字在源码中的位置: 从 pos 开始在同一行查找完整的名字, 找不到则使用 pos
+// 返回行号与起止列 (结束列为名字之后的一列)
+func nameAt(lines []string, pos token.Pos, name string) (line int, start int, end int) {
+	length := len([]rune(name))
+	if pos.Line < 1 || pos.Line > len(lines) {
+		return pos.Line, pos.Col, pos.Col + length
+	}
+	runes := []rune(lines[pos.Line-1])
+	for i := pos.Col - 1; i >= 0 && i+length <= len(runes); i++ {
+		if string(runes[i:i+length]) != name {
+			continue
+		}
+		if (i > 0 && isIdent(runes[i-1])) || (i+length < len(runes) && isIdent(runes[i+length])) {
+			continue
+		}
+		return pos.Line, i + 1, i + 1 + length
+	}
+	return pos.Line, pos.Col, pos.Col + length
+}
+
+func isIdent(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// 名字的范围
+func nameRange(lines []string, pos token.Pos, name string) object {
+	line, start, end := nameAt(lines, pos, name)
+	return object{
+		"start": editorPos(lines, line, start),
+		"end":   editorPos(lines, line, end),
+	}
+}
+
+// 诊断的范围: 位置处的单词, 没有单词时为一个字符
+func wordRange(lines []string, pos token.Pos) object {
+	if !pos.IsValid() {
+		return nameRange(lines, token.Pos{Line: 1, Col: 1}, "")
+	}
+	length := 1
+	if pos.Line <= len(lines) {
+		runes := []rune(lines[pos.Line-1])
+		if i := pos.Col - 1; i < len(runes) && isIdent(runes[i]) {
+			for length = 0; i+length < len(runes) && isIdent(runes[i+length]); length++ {
+			}
+		}
+	}
+	return object{
+		"start": editorPos(lines, pos.Line, pos.Col),
+		"end":   editorPos(lines, pos.Line, pos.Col+length),
+	}
+}
@@ -0,0 +1,235 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Server 语言服务 (Language Server Protocol), 通过 JSON-RPC 消息与编辑器通信
+// 打开或者修改文件时重新检查整个文件并发布诊断, 其它请求使用最近一次检查的结果
+type Server struct {
+	in  *bufio.Reader
+	out io.Writer
+
+	docs     map[string]*document // 打开的文件 (uri -> 文件)
+	shutdown bool
+}
+
+// 收到的请求或者通知 (通知没有 id)
+type request struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result"`
+}
+
+type errorResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Error   object          `json:"error"`
+}
+
+type notification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+// 消息体
+type object map[string]interface{}
+
+// JSON-RPC 错误码
+const (
+	invalidParams  = -32602
+	methodNotFound = -32601
+	invalidRequest = -32600
+)
+
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{
+		in:   bufio.NewReader(in),
+		out:  out,
+		docs: make(map[string]*document),
+	}
+}
+
+// Serve 处理消息, 直到收到 exit 或者输入结束
+func (s *Server) Serve() error {
+	for {
+		req, err := s.read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if req.Method == "exit" {
+			return nil
+		}
+		s.handle(req)
+	}
+}
+
+// 读取一条消息: Content-Length 头, 空行, JSON
+func (s *Server) read() (*request, error) {
+	length := -1
+	for {
+		line, err := s.in.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		if name, value, ok := strings.Cut(line, ":"); ok && strings.EqualFold(name, "Content-Length") {
+			length, err = strconv.Atoi(strings.TrimSpace(value))
+			if err != nil {
+				return nil, fmt.Errorf("不合法的 Content-Length: %s", value)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, errors.New("消息缺少 Content-Length")
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(s.in, data); err != nil {
+		return nil, err
+	}
+	var req request
+	if err := json.Unmarshal(data, &req); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// 写一条消息
+func (s *Server) write(msg interface{}) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Fprintf(s.out, "Content-Length: %d\r\n\r\n%s", len(data), data)
+}
+
+func (s *Server) respond(req *request, result interface{}) {
+	s.write(&response{JSONRPC: "2.0", ID: req.ID, Result: result})
+}
+
+func (s *Server) fail(req *request, code int, format string, args ...interface{}) {
+	s.write(&errorResponse{JSONRPC: "2.0", ID: req.ID, Error: object{"code": code, "message": fmt.Sprintf(format, args...)}})
+}
+
+func (s *Server) notify(method string, params interface{}) {
+	s.write(&notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+// 处理一条消息, 通知不需要回复
+func (s *Server) handle(req *request) {
+	var params struct {
+		TextDocument struct {
+			URI  string `json:"uri"`
+			Text string `json:"text"`
+		} `json:"textDocument"`
+		ContentChanges []struct {
+			Text string `json:"text"`
+		} `json:"contentChanges"`
+		Position position `json:"position"`
+		Context  struct {
+			IncludeDeclaration bool `json:"includeDeclaration"`
+		} `json:"context"`
+	}
+	if len(req.Params) > 0 {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			if req.ID != nil {
+				s.fail(req, invalidParams, "参数错误: %s", err)
+			}
+			return
+		}
+	}
+
+	if s.shutdown && req.ID != nil {
+		s.fail(req, invalidRequest, "语言服务已经关闭")
+		return
+	}
+
+	uri := params.TextDocument.URI
+	switch req.Method {
+	case "initialize":
+		s.respond(req, object{
+			"capabilities": object{
+				"textDocumentSync":       1, // 每次修改发送完整内容
+				"hoverProvider":          true,
+				"definitionProvider":     true,
+				"referencesProvider":     true,
+				"documentSymbolProvider": true,
+				"completionProvider":     object{"triggerCharacters": []string{}},
+			},
+			"serverInfo": object{"name": "my-lang"},
+		})
+	case "shutdown":
+		s.shutdown = true
+		s.respond(req, nil)
+	case "textDocument/didOpen":
+		s.open(uri, params.TextDocument.Text)
+	case "textDocument/didChange":
+		if n := len(params.ContentChanges); n > 0 {
+			s.open(uri, params.ContentChanges[n-1].Text)
+		}
+	case "textDocument/didClose":
+		delete(s.docs, uri)
+		s.notify("textDocument/publishDiagnostics", object{"uri": uri, "diagnostics": []object{}})
+	case "textDocument/hover":
+		s.query(req, uri, func(doc *document) interface{} {
+			return doc.hover(params.Position)
+		})
+	case "textDocument/definition":
+		s.query(req, uri, func(doc *document) interface{} {
+			return s.definition(doc, params.Position)
+		})
+	case "textDocument/references":
+		s.query(req, uri, func(doc *document) interface{} {
+			return s.references(doc, params.Position, params.Context.IncludeDeclaration)
+		})
+	case "textDocument/documentSymbol":
+		s.query(req, uri, func(doc *document) interface{} {
+			return doc.symbols()
+		})
+	case "textDocument/completion":
+		s.query(req, uri, func(doc *document) interface{} {
+			return doc.completion(params.Position)
+		})
+	default:
+		if req.ID != nil {
+			s.fail(req, methodNotFound, "不支持的请求 %s", req.Method)
+		}
+	}
+}
+
+// 打开或者修改文件: 重新检查并发布诊断
+func (s *Server) open(uri string, text string) {
+	doc := newDocument(uri, text)
+	s.docs[uri] = doc
+	s.notify("textDocument/publishDiagnostics", object{"uri": uri, "diagnostics": doc.diagnostics()})
+}
+
+// 在打开的文件上回答请求, 文件没有打开时结果为 null
+func (s *Server) query(req *request, uri string, fn func(doc *document) interface{}) {
+	doc, ok := s.docs[uri]
+	if !ok {
+		s.respond(req, nil)
+		return
+	}
+	s.respond(req, fn(doc))
+}
@@ -1,48 +1,512 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"my-lang/ast"
+	"my-lang/check"
+	"my-lang/cover"
+	"my-lang/debug"
+	"my-lang/format"
+	"my-lang/lint"
+	"my-lang/lsp"
 	"my-lang/rt"
+	"my-lang/syntax"
+	"my-lang/test"
 	"my-lang/token"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"strings"
 )
 
-type MyArgs struct {
-	mainFile string
+// 子命令, 没有指定子命令时默认为 run
+var commands = map[string]func(args []string){
+	"run":    runCmd,
+	"check":  checkCmd,
+	"debug":  debugCmd,
+	"dap":    dapCmd,
+	"lsp":    lspCmd,
+	"fmt":    fmtCmd,
+	"lint":   lintCmd,
+	"tokens": tokensCmd,
+	"ast":    astCmd,
+	"test":   testCmd,
 }
 
-func initArgs() (args MyArgs) {
+func main() {
 
-	if len(os.Args) > 1 {
-		args.mainFile = os.Args[1]
+	args := os.Args[1:]
+	if len(args) > 0 {
+		if cmd, ok := commands[args[0]]; ok {
+			cmd(args[1:])
+			return
+		}
 	}
+	runCmd(args)
 
-	return
 }
 
-func main() {
+// 解析子命令参数, 返回源文件
+func parseArgs(flags *flag.FlagSet, args []string) string {
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		fmt.Fprintf(os.Stderr, "用法: my-lang %s [选项] file.m\n", flags.Name())
+		flags.PrintDefaults()
+		os.Exit(2)
+	}
+	return flags.Arg(0)
+}
+
+// 打印检查结果, 有错误时返回 false
+func report(c *check.Checker, warnings bool) bool {
+	for _, d := range c.Sorted() {
+		if d.Warning && !warnings {
+			continue
+		}
+		fmt.Fprintln(os.Stderr, d)
+	}
+	return !c.HasErrors()
+}
 
-	args := initArgs()
+// my-lang check file.m
+func checkCmd(args []string) {
+	flags := flag.NewFlagSet("check", flag.ExitOnError)
+	types := flags.Bool("types", false, "打印推导出的方法签名")
+	mainFile := parseArgs(flags, args)
 
-	// 新建扫描器
-	scanner := token.NewScanner(args.mainFile)
+	c := check.NewChecker()
+	c.CheckFile(mainFile)
+	if *types {
+		for _, fn := range c.Functions() {
+			fmt.Printf("%s: %s\n", fn.Pos(), c.Signature(fn))
+		}
+	}
+	if !report(c, true) {
+		os.Exit(1)
+	}
+}
+
+// my-lang fmt [--check | --write] file.m|dir ...
+// 默认把格式化的结果输出到标准输出
+func fmtCmd(args []string) {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	checkOnly := flags.Bool("check", false, "只列出需要格式化的文件, 有这样的文件时退出码为 1")
+	write := flags.Bool("write", false, "把格式化的结果写回源文件")
+	parseArgs(flags, args)
+
+	files, err := sourceFiles(flags.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
 
-	// 扫描所有的 tokens
+	failed := false
+	for _, file := range files {
+		src, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed = true
+			continue
+		}
+		out, err := format.Source(file, src)
+		if err != nil {
+			printError(err)
+			failed = true
+			continue
+		}
+
+		switch {
+		case *checkOnly:
+			if string(out) != string(src) {
+				fmt.Println(file)
+				failed = true
+			}
+		case *write:
+			if string(out) != string(src) {
+				if err := os.WriteFile(file, out, 0644); err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					failed = true
+				}
+			}
+		default:
+			os.Stdout.Write(out)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// my-lang lint [--rules r1,r2] [--disable r3] file.m|dir ...
+// 源码中的 // lint:ignore rule 注释可以忽略所在行 (或者下一行) 的规则
+func lintCmd(args []string) {
+	flags := flag.NewFlagSet("lint", flag.ExitOnError)
+	rules := flags.String("rules", "all", "启用的规则, 例如 unused-var,shadow")
+	disable := flags.String("disable", "", "禁用的规则")
+	list := flags.Bool("list", false, "列出所有的规则")
+	flags.Parse(args)
+
+	if *list {
+		for _, rule := range lint.Rules {
+			fmt.Printf("%-14s %s\n", rule.Name, rule.Doc)
+		}
+		return
+	}
+	parseArgs(flags, args)
+
+	config, err := lint.ParseConfig(*rules, *disable)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	files, err := sourceFiles(flags.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	failed := false
+	for _, file := range files {
+		errors, findings := lint.File(file, config)
+		for _, d := range errors {
+			fmt.Fprintln(os.Stderr, d)
+		}
+		for _, f := range findings {
+			fmt.Println(f)
+		}
+		failed = failed || len(errors) > 0 || len(findings) > 0
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// my-lang test [--run 正则] [--format tap|junit] [目录 | 目录/... | file_test.m ...]
+func testCmd(args []string) {
+	flags := flag.NewFlagSet("test", flag.ExitOnError)
+	run := flags.String("run", "", "只运行名字与正则表达式匹配的测试 (测试块或者测试方法)")
+	format := flags.String("format", test.TAP, "报告格式: tap, junit")
+	verbose := flags.Bool("v", false, "TAP 报告中也列出通过的测试的输出")
+	timeout := flags.Duration("timeout", 0, "每个测试的最长运行时间, 例如 10s (0 为不限制)")
+	caps := flags.String("caps", "all", "测试可以使用的能力, 例如 fs:read,env, 或者预设的 all, pure")
+	flags.Parse(args)
+
+	if *format != test.TAP && *format != test.JUnit {
+		fmt.Fprintf(os.Stderr, "未知的报告格式 %s, 可以是 tap, junit\n", *format)
+		os.Exit(2)
+	}
+	var filter *regexp.Regexp
+	if *run != "" {
+		re, err := regexp.Compile(*run)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		filter = re
+	}
+	capabilities, err := rt.ParseCapabilities(*caps)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	patterns := flags.Args()
+	if len(patterns) == 0 {
+		patterns = []string{"."}
+	}
+	files, err := test.Find(patterns)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	// Ctrl-C 时停止执行
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	options := rt.DefaultOptions()
+	options.Timeout = *timeout
+	options.Capabilities = capabilities
+	options.Context = ctx
+
+	var results []*test.Result
+	for _, file := range files {
+		results = append(results, test.RunFile(file, filter, options)...)
+	}
+	if err := test.Write(os.Stdout, *format, results, *verbose); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	for _, r := range results {
+		if !r.Passed() {
+			stop()
+			os.Exit(1)
+		}
+	}
+}
+
+// my-lang tokens [--format text|json|sexpr] file.m
+func tokensCmd(args []string) {
+	flags := flag.NewFlagSet("tokens", flag.ExitOnError)
+	format := flags.String("format", syntax.Text, "输出格式: text, json, sexpr")
+	comments := flags.Bool("comments", false, "保留注释 token")
+	mainFile := parseArgs(flags, args)
+
+	src, err := os.ReadFile(mainFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	scanner := token.NewSourceScanner(mainFile, src)
+	if *comments {
+		scanner.KeepComments()
+	}
 	toks := scanner.ScanTokens()
+	if err := syntax.Encode(os.Stdout, *format, syntax.Tokens(toks)); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	for _, err := range scanner.Errors() {
+		fmt.Fprintf(os.Stderr, "%s: %s\n", err.Pos, err.Msg)
+	}
+	if len(scanner.Errors()) > 0 {
+		os.Exit(1)
+	}
+}
 
-	// 调试 tokens 结果
-	// token.Debug(toks)
+// my-lang ast [--format text|json|sexpr|source] file.m
+// source 把语法树输出成源码
+func astCmd(args []string) {
+	flags := flag.NewFlagSet("ast", flag.ExitOnError)
+	format := flags.String("format", syntax.Text, "输出格式: text, json, sexpr, source")
+	mainFile := parseArgs(flags, args)
 
-	// 全局对象表
-	globalObjs := ast.NewObjectList(nil)
+	// 有错误的语句在树里是 BadStmt, 仍然输出语法树
+	tree, parseErr := syntax.ParseFile(mainFile)
+	if tree == nil {
+		printError(parseErr)
+		os.Exit(1)
+	}
 
-	// 新建解析器
-	p := ast.NewParser(toks, globalObjs)
+	var err error
+	if *format == "source" {
+		err = syntax.Print(os.Stdout, tree)
+	} else {
+		err = syntax.Encode(os.Stdout, *format, tree)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if parseErr != nil {
+		printError(parseErr)
+		os.Exit(1)
+	}
+}
 
-	// 新建解释器
-	e := rt.NewExec(p)
+// 命令行参数中的源文件, 目录会递归查找其中的 .m 文件
+func sourceFiles(args []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+		err = filepath.WalkDir(arg, func(path string, d os.DirEntry, err error) error {
+			if err == nil && !d.IsDir() && strings.HasSuffix(path, ".m") {
+				files = append(files, path)
+			}
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// my-lang [run] file.m
+func runCmd(args []string) {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	maxDepth := flags.Int("max-depth", rt.DefaultMaxDepth, fmt.Sprintf("最大调用深度, 不能超过 %d (0 为 %d)", rt.MaxDepthLimit, rt.MaxDepthLimit))
+	maxSteps := flags.Int64("max-steps", 0, "最多执行的步数 (0 为不限制)")
+	timeout := flags.Duration("timeout", 0, "最长运行时间, 例如 10s (0 为不限制)")
+	maxAlloc := flags.Int64("max-alloc", 0, "内存分配预算, 单位为字节, 按累计分配计算 (0 为不限制)")
+	caps := flags.String("caps", "all", "脚本可以使用的能力, 例如 fs:read,env, 或者预设的 all, pure")
+	noCheck := flags.Bool("no-check", false, "运行前不做类型检查")
+	profile := flags.String("profile", "", "把方法与行的调用次数, 执行时间与内存分配写入文件 (pprof 格式, 用 go tool pprof 查看)")
+	coverOut := flags.String("cover", "", "把语句, 分支与方法的覆盖率写入文件 (lcov 格式)")
+	coverHTML := flags.String("cover-html", "", "把按行标注执行次数的覆盖率报告写入文件 (HTML)")
+	mainFile := parseArgs(flags, args)
+
+	capabilities, err := rt.ParseCapabilities(*caps)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
 
-	// 运行
-	e.Run()
+	// 运行前先做类型检查
+	if !*noCheck {
+		c := check.NewChecker()
+		c.Capabilities = capabilities
+		c.CheckFile(mainFile)
+		if !report(c, false) {
+			os.Exit(1)
+		}
+	}
 
+	options := rt.DefaultOptions()
+	options.MaxDepth = *maxDepth
+	options.MaxSteps = *maxSteps
+	options.Timeout = *timeout
+	options.MaxAlloc = *maxAlloc
+	options.Capabilities = capabilities
+	if err := options.Validate(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	// Ctrl-C 时停止执行
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	options.Context = ctx
+
+	if *profile != "" {
+		options.Profiler = rt.NewProfiler()
+	}
+	if *coverOut != "" || *coverHTML != "" {
+		options.Coverage = rt.NewCoverage()
+	}
+
+	_, err = rt.ExecuteFile(mainFile, options)
+
+	// 出错时也写入已经统计的结果
+	if *profile != "" {
+		if err := writeProfile(*profile, options.Profiler); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	if options.Coverage != nil {
+		if err := writeCoverage(options.Coverage, *coverOut, *coverHTML); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	if err != nil {
+		printError(err)
+		stop()
+		os.Exit(1)
+	}
+}
+
+// 输出覆盖率报告, 在标准错误输出合计的覆盖率
+func writeCoverage(coverage *rt.Coverage, lcovPath string, htmlPath string) error {
+	files, err := cover.Report(coverage)
+	if err != nil {
+		return err
+	}
+
+	total := cover.Total(files)
+	fmt.Fprintf(os.Stderr, "覆盖率: 行 %.1f%%, 方法 %.1f%%, 分支 %.1f%%\n",
+		total.Lines.Percent(), total.Functions.Percent(), total.Branches.Percent())
+
+	for _, out := range []struct {
+		path  string
+		write func(io.Writer, []*cover.File) error
+	}{{lcovPath, cover.WriteLCOV}, {htmlPath, cover.WriteHTML}} {
+		if out.path == "" {
+			continue
+		}
+		f, err := os.Create(out.path)
+		if err != nil {
+			return err
+		}
+		if err := out.write(f, files); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeProfile(path string, profiler *rt.Profiler) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := profiler.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// my-lang debug file.m
+func debugCmd(args []string) {
+	flags := flag.NewFlagSet("debug", flag.ExitOnError)
+	mainFile := parseArgs(flags, args)
+
+	// quit 命令与 Ctrl-C 时停止执行
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	cli := debug.NewCLI(mainFile, os.Stdin, os.Stdout, cancel)
+	options := rt.DefaultOptions()
+	options.Context = ctx
+	options.Hook = cli.Hook
+
+	_, err := rt.ExecuteFile(mainFile, options)
+	switch {
+	case errors.Is(err, context.Canceled):
+	case err != nil:
+		printError(err)
+	default:
+		fmt.Println("程序已结束")
+	}
+}
+
+// my-lang dap: 通过标准输入输出提供 Debug Adapter Protocol 服务
+func dapCmd(args []string) {
+	flags := flag.NewFlagSet("dap", flag.ExitOnError)
+	flags.Parse(args)
+
+	if err := debug.NewDAP(os.Stdin, os.Stdout).Serve(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// my-lang lsp: 通过标准输入输出提供 Language Server Protocol 服务
+func lspCmd(args []string) {
+	flags := flag.NewFlagSet("lsp", flag.ExitOnError)
+	flags.Parse(args)
+
+	if err := lsp.NewServer(os.Stdin, os.Stdout).Serve(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// 打印运行错误, 脚本错误带有调用栈
+func printError(err error) {
+	if scriptErr, ok := err.(*ast.Error); ok {
+		fmt.Fprintln(os.Stderr, scriptErr.Traceback())
+	} else {
+		fmt.Fprintln(os.Stderr, err)
+	}
 }
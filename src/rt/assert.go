@@ -0,0 +1,161 @@
+package rt
+
+import (
+	"context"
+	"fmt"
+	"my-lang/ast"
+	"strings"
+)
+
+// 断言失败时最多列出的差异数
+const maxDiffs = 10
+
+// assert(cond, [message]) 条件不成立时抛出 AssertionError
+func builtinAssert(ctx context.Context, args []interface{}) interface{} {
+	if len(args) != 1 && len(args) != 2 {
+		panic(ast.NewError(ast.TypeError, "assert 需要 1 到 2 个参数, 实际提供 %d 个", len(args)))
+	}
+	cond, ok := args[0].(bool)
+	if !ok {
+		panic(ast.NewError(ast.TypeError, "assert 的条件必须是 bool 类型"))
+	}
+	if !cond {
+		panic(ast.NewError(ast.AssertionError, "%s", assertMessage("断言失败", args[1:])))
+	}
+	return nil
+}
+
+// assert_eq(actual, expected, [message]) 两个值不相等时抛出 AssertionError, 列出两个值与其中不同的部分
+func builtinAssertEq(ctx context.Context, args []interface{}) interface{} {
+	if len(args) != 2 && len(args) != 3 {
+		panic(ast.NewError(ast.TypeError, "assert_eq 需要 2 到 3 个参数, 实际提供 %d 个", len(args)))
+	}
+	actual, expected := args[0], args[1]
+	if ast.Equal(actual, expected) {
+		return nil
+	}
+
+	var sb strings.Builder
+	sb.WriteString(assertMessage("assert_eq 失败", args[2:]))
+	fmt.Fprintf(&sb, "\n  实际: %s\n  期望: %s", reprType(actual), reprType(expected))
+	if diffs := diff(actual, expected, "", nil); len(diffs) > 0 {
+		sb.WriteString("\n  差异:")
+		for i, d := range diffs {
+			if i == maxDiffs {
+				fmt.Fprintf(&sb, "\n    ... 还有 %d 处", len(diffs)-maxDiffs)
+				break
+			}
+			sb.WriteString("\n    ")
+			sb.WriteString(d)
+		}
+	}
+	panic(ast.NewError(ast.AssertionError, "%s", sb.String()))
+}
+
+// assert_throws(expr, [kind]) 由解释器执行 (参数需要在 try 中求值), 参数求值完成说明没有抛出错误
+func builtinAssertThrows(ctx context.Context, args []interface{}) interface{} {
+	panic(ast.NewError(ast.AssertionError, "没有抛出错误"))
+}
+
+// assert_throws(expr, [kind]) 求值 expr, 没有抛出错误或者错误类型不是 kind 时抛出 AssertionError
+// 返回捕获的错误, 可以继续检查 e.message
+func (e *Exec) assertThrows(expr *ast.CallBuiltinExpr) interface{} {
+	if len(expr.Params) != 1 && len(expr.Params) != 2 {
+		panic(ast.NewError(ast.TypeError, "assert_throws 需要 1 到 2 个参数, 实际提供 %d 个", len(expr.Params)))
+	}
+	kind := ""
+	if len(expr.Params) == 2 {
+		s, ok := e.expr(expr.Params[1]).(string)
+		if !ok {
+			panic(ast.NewError(ast.TypeError, "assert_throws 的错误类型必须是 string"))
+		}
+		kind = s
+	}
+
+	err := e.protect(func() {
+		e.expr(expr.Params[0])
+	})
+	if err == nil {
+		if kind != "" {
+			panic(ast.NewError(ast.AssertionError, "没有抛出错误, 期望 %s", kind))
+		}
+		panic(ast.NewError(ast.AssertionError, "没有抛出错误"))
+	}
+	if kind != "" && err.Kind != kind {
+		panic(ast.NewError(ast.AssertionError, "期望抛出 %s, 实际抛出 %s", kind, err))
+	}
+	return err
+}
+
+func assertMessage(msg string, args []interface{}) string {
+	if len(args) > 0 {
+		return fmt.Sprintf("%s: %v", msg, args[0])
+	}
+	return msg
+}
+
+// 值与类型: 1 (int)
+func reprType(val interface{}) string {
+	return fmt.Sprintf("%s (%s)", ast.Repr(val), ast.TypeString(ast.GetType(val)))
+}
+
+// 列表与结构体中不同的部分, 每一项的格式为 [1].x: 实际 1 (int), 期望 2 (int)
+// 列表按下标比较, 同类型的结构体按字段比较, 字符串列出第一个不同的字符
+// 两个值本身就是不同的标量时没有差异可以列出
+func diff(actual interface{}, expected interface{}, path string, diffs []string) []string {
+	if ast.Equal(actual, expected) {
+		return diffs
+	}
+
+	l1, ok1 := actual.(*ast.ListValue)
+	l2, ok2 := expected.(*ast.ListValue)
+	if ok1 && ok2 {
+		e1, e2 := l1.Values(), l2.Values()
+		n := len(e1)
+		if len(e2) < n {
+			n = len(e2)
+		}
+		for i := 0; i < n; i++ {
+			diffs = diff(e1[i], e2[i], fmt.Sprintf("%s[%d]", path, i), diffs)
+		}
+		if len(e1) != len(e2) {
+			diffs = append(diffs, strings.TrimPrefix(fmt.Sprintf("%s: 长度 %d, 期望 %d", path, len(e1), len(e2)), ": "))
+		}
+		return diffs
+	}
+
+	r1, ok1 := actual.(*ast.RecordValue)
+	r2, ok2 := expected.(*ast.RecordValue)
+	if ok1 && ok2 && r1.Type == r2.Type {
+		f1, f2 := r1.Values(), r2.Values()
+		for i, field := range r1.Type.Fields {
+			diffs = diff(f1[i], f2[i], path+"."+field, diffs)
+		}
+		return diffs
+	}
+
+	// 字符串列出第一个不同的字符
+	s1, ok1 := actual.(string)
+	s2, ok2 := expected.(string)
+	if ok1 && ok2 {
+		r1, r2 := []rune(s1), []rune(s2)
+		i := 0
+		for i < len(r1) && i < len(r2) && r1[i] == r2[i] {
+			i += 1
+		}
+		return append(diffs, strings.TrimPrefix(fmt.Sprintf("%s: 第 %d 个字符: 实际 %s, 期望 %s", path, i+1, runeAt(r1, i), runeAt(r2, i)), ": "))
+	}
+
+	if path == "" {
+		return diffs
+	}
+	return append(diffs, fmt.Sprintf("%s: 实际 %s, 期望 %s", path, reprType(actual), reprType(expected)))
+}
+
+// 第 i 个字符, 超出字符串长度时为 (结尾)
+func runeAt(r []rune, i int) string {
+	if i >= len(r) {
+		return "(结尾)"
+	}
+	return ast.Repr(string(r[i]))
+}
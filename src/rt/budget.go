@@ -0,0 +1,89 @@
+package rt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrBudgetExceeded 超过执行预算 (最大步数或者最长运行时间)
+var ErrBudgetExceeded = errors.New("超过执行预算")
+
+// 执行预算, 一次 Execute 内所有子解释器, 任务与生成器共用
+// 每条语句, 每次循环与每次调用算一步, 同时检查是否超时或者被取消
+type budget struct {
+	options *Options
+	steps   atomic.Int64
+	memory  atomic.Int64 // 已经分配的内存 (字节)
+
+	ctx    context.Context // 超时, 超过步数或者 Options.Context 取消时结束
+	cancel context.CancelFunc
+
+	mu    sync.Mutex
+	cause error // 超过步数时的错误
+}
+
+// 预算用尽时的 panic, 不能被脚本的 try 捕获, 由 Execute 转换成 error
+type budgetStop struct{}
+
+func newBudget(options *Options) *budget {
+	b := &budget{options: options}
+	parent := options.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	if options.Timeout > 0 {
+		b.ctx, b.cancel = context.WithTimeout(parent, options.Timeout)
+	} else {
+		b.ctx, b.cancel = context.WithCancel(parent)
+	}
+	return b
+}
+
+// 走一步, 预算用尽时停止执行
+func (b *budget) step() {
+	if max := b.options.MaxSteps; max > 0 && b.steps.Add(1) > max {
+		b.stop(fmt.Errorf("%w: 超过最大步数 %d", ErrBudgetExceeded, max))
+	}
+	select {
+	case <-b.ctx.Done():
+		panic(budgetStop{})
+	default:
+	}
+}
+
+// 停止执行, 同时唤醒阻塞在通道上的任务
+func (b *budget) stop(cause error) {
+	b.mu.Lock()
+	if b.cause == nil {
+		b.cause = cause
+	}
+	b.mu.Unlock()
+	b.cancel()
+	panic(budgetStop{})
+}
+
+// 停止执行的原因: ErrBudgetExceeded 或者 Options.Context 的错误 (例如 context.Canceled)
+func (b *budget) err() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.cause != nil {
+		return b.cause
+	}
+	if b.options.Context != nil && b.options.Context.Err() != nil {
+		return b.options.Context.Err()
+	}
+	if b.ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("%w: 超过最长运行时间 %s", ErrBudgetExceeded, b.options.Timeout)
+	}
+	return b.ctx.Err()
+}
+
+// 阻塞的操作因为预算用尽而结束时停止执行
+func stopIf(err error) {
+	if err != nil {
+		panic(budgetStop{})
+	}
+}
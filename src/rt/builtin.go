@@ -0,0 +1,136 @@
+package rt
+
+import (
+	"context"
+	"my-lang/ast"
+	"unicode/utf8"
+)
+
+// Builtins 内置方法表 (作为全局对象表的上一层)
+func Builtins() *ast.ObjectList {
+	objs := ast.NewObjectList(nil)
+	objs.Add(&ast.Builtin{Name: "len", Fn: builtinLen, Result: ast.INT})
+	objs.Add(&ast.Builtin{Name: "next", Fn: builtinNext, Result: ast.LIST})
+	objs.Add(&ast.Builtin{Name: "chan", Fn: builtinChan, Result: ast.CHANNEL})
+	objs.Add(&ast.Builtin{Name: "send", Fn: builtinSend})
+	objs.Add(&ast.Builtin{Name: "recv", Fn: builtinRecv})
+	objs.Add(&ast.Builtin{Name: "close", Fn: builtinClose})
+	objs.Add(&ast.Builtin{Name: "wait", Fn: builtinWait})
+	objs.Add(&ast.Builtin{Name: "assert", Fn: builtinAssert})
+	objs.Add(&ast.Builtin{Name: "assert_eq", Fn: builtinAssertEq})
+	objs.Add(&ast.Builtin{Name: "assert_throws", Fn: builtinAssertThrows})
+	return objs
+}
+
+// 内置方法的参数数量必须一致
+func checkArgs(name string, args []interface{}, n int) {
+	if len(args) != n {
+		panic(ast.NewError(ast.TypeError, "%s 需要 %d 个参数, 实际提供 %d 个", name, n, len(args)))
+	}
+}
+
+// len(x) 列表长度或者字符串长度
+func builtinLen(ctx context.Context, args []interface{}) interface{} {
+	checkArgs("len", args, 1)
+	switch val := args[0].(type) {
+	case *ast.ListValue:
+		return int64(val.Len())
+	case string:
+		return int64(utf8.RuneCountInString(val))
+	}
+	panic(ast.NewError(ast.TypeError, "len 不支持 %s 类型", ast.TypeString(ast.GetType(args[0]))))
+}
+
+// next(g) 生成器的下一个值: [value, done]
+// 结束时 done 为 true, value 为生成器 return 的值
+func builtinNext(ctx context.Context, args []interface{}) interface{} {
+	checkArgs("next", args, 1)
+	gen, ok := args[0].(*ast.GeneratorValue)
+	if !ok {
+		panic(ast.NewError(ast.TypeError, "next 不支持 %s 类型", ast.TypeString(ast.GetType(args[0]))))
+	}
+	value, done := gen.Resume()
+	return ast.NewListValue([]interface{}{value, done})
+}
+
+// chan([type], [size]) 创建通道, 可以限定元素类型, size 为缓冲区大小
+// chan(), chan(10), chan('int'), chan('int', 10)
+func builtinChan(ctx context.Context, args []interface{}) interface{} {
+	elem, size := "", int64(0)
+	if len(args) > 0 {
+		if name, ok := args[0].(string); ok {
+			elem, args = name, args[1:]
+		}
+	}
+	if len(args) > 1 {
+		panic(ast.NewError(ast.TypeError, "chan 最多需要 2 个参数, 实际提供 %d 个", len(args)+1))
+	}
+	if len(args) == 1 {
+		n, ok := args[0].(int64)
+		if !ok || n < 0 {
+			panic(ast.NewError(ast.TypeError, "通道的缓冲区大小必须是非负的 int"))
+		}
+		size = n
+	}
+	return ast.NewChannelValue(elem, ast.Int64ToInt(size))
+}
+
+// 通道操作的对象必须是通道
+func channelArg(name string, val interface{}) *ast.ChannelValue {
+	ch, ok := val.(*ast.ChannelValue)
+	if !ok {
+		panic(ast.NewError(ast.TypeError, "%s 不支持 %s 类型", name, ast.TypeString(ast.GetType(val))))
+	}
+	return ch
+}
+
+// send(c, x) 向通道发送值
+func builtinSend(ctx context.Context, args []interface{}) interface{} {
+	checkArgs("send", args, 2)
+	stopIf(channelArg("send", args[0]).Send(ctx, args[1]))
+	return nil
+}
+
+// recv(c) 从通道接收值, 通道已经关闭且没有数据时抛出 ChannelError (for x in c 遍历到关闭为止)
+func builtinRecv(ctx context.Context, args []interface{}) interface{} {
+	checkArgs("recv", args, 1)
+	val, ok, err := channelArg("recv", args[0]).Recv(ctx)
+	stopIf(err)
+	if !ok {
+		panic(ast.NewError(ast.ChannelError, "从已关闭的通道接收数据"))
+	}
+	return val
+}
+
+// close(c) 关闭通道
+func builtinClose(ctx context.Context, args []interface{}) interface{} {
+	checkArgs("close", args, 1)
+	channelArg("close", args[0]).Close()
+	return nil
+}
+
+// wait(t) 等待任务结束并返回结果, wait([t1, t2]) 等待所有任务并返回结果列表
+func builtinWait(ctx context.Context, args []interface{}) interface{} {
+	checkArgs("wait", args, 1)
+	switch val := args[0].(type) {
+	case *ast.TaskValue:
+		result, _ := val.Wait(ctx)
+		// 预算用尽时任务没有结果
+		stopIf(ctx.Err())
+		return result
+	case *ast.ListValue:
+		elements := val.Values()
+		results := make([]interface{}, len(elements))
+		for i, element := range elements {
+			task, ok := element.(*ast.TaskValue)
+			if !ok {
+				panic(ast.NewError(ast.TypeError, "wait 不支持 %s 类型", ast.TypeString(ast.GetType(element))))
+			}
+			result, _ := task.Wait(ctx)
+			stopIf(ctx.Err())
+			results[i] = result
+		}
+		return ast.NewListValue(results)
+	}
+	panic(ast.NewError(ast.TypeError, "wait 不支持 %s 类型", ast.TypeString(ast.GetType(args[0]))))
+}
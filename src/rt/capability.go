@@ -0,0 +1,89 @@
+package rt
+
+import (
+	"fmt"
+	"my-lang/ast"
+	"sort"
+	"strings"
+)
+
+// Capability 脚本可以使用的能力, 使用前检查
+// 目前 import 需要 fs:read, 从 MYLANG_PATH 查找模块需要 env;
+// fs:write, exec 与 clock 预留给以后的标准库, 现在的内置方法都不需要任何能力
+type Capability string
+
+const (
+	CapFsRead  Capability = "fs:read"  // 读文件 (包括 import)
+	CapFsWrite Capability = "fs:write" // 写文件
+	CapExec    Capability = "exec"     // 启动进程
+	CapEnv     Capability = "env"      // 读取环境变量 (包括 MYLANG_PATH)
+	CapClock   Capability = "clock"    // 读取当前时间
+)
+
+// 所有的能力
+var capabilities = []Capability{CapFsRead, CapFsWrite, CapExec, CapEnv, CapClock}
+
+// Capabilities 能力集合, 为 nil 时没有任何能力
+type Capabilities map[Capability]bool
+
+func NewCapabilities(caps ...Capability) Capabilities {
+	set := make(Capabilities)
+	for _, c := range caps {
+		set[c] = true
+	}
+	return set
+}
+
+// AllCapabilities 所有的能力 (默认配置)
+func AllCapabilities() Capabilities {
+	return NewCapabilities(capabilities...)
+}
+
+// PureCapabilities 纯计算: 不能访问文件, 进程, 环境变量与时间
+// 同样的输入总是得到同样的结果, 适合规则求值
+func PureCapabilities() Capabilities {
+	return NewCapabilities()
+}
+
+// ParseCapabilities 解析能力列表: "fs:read,env", 或者预设的 "all", "pure"
+func ParseCapabilities(s string) (Capabilities, error) {
+	switch s {
+	case "all":
+		return AllCapabilities(), nil
+	case "pure", "":
+		return PureCapabilities(), nil
+	}
+
+	set := NewCapabilities()
+	for _, name := range strings.Split(s, ",") {
+		c := Capability(strings.TrimSpace(name))
+		if !AllCapabilities().Has(c) {
+			return nil, fmt.Errorf("未知的能力 %s", c)
+		}
+		set[c] = true
+	}
+	return set, nil
+}
+
+func (c Capabilities) Has(cap Capability) bool {
+	return c[cap]
+}
+
+// String 按名字排序: fs:read,env
+func (c Capabilities) String() string {
+	names := make([]string, 0)
+	for cap := range c {
+		if c[cap] {
+			names = append(names, string(cap))
+		}
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+// 检查是否有使用 what 所需的能力, 没有时抛出 PermissionError
+func (e *Exec) require(cap Capability, what string) {
+	if !e.options.Capabilities.Has(cap) {
+		panic(ast.NewError(ast.PermissionError, "%s 需要 %s 权限", what, cap))
+	}
+}
@@ -0,0 +1,114 @@
+package rt
+
+import (
+	"my-lang/token"
+	"sort"
+	"sync"
+)
+
+// 分支编号: if 的两个分支, for 的循环体
+const (
+	BranchThen = 0
+	BranchElse = 1
+	BranchBody = 0
+)
+
+// Coverage 记录执行过的语句, 分支与方法, 用于统计代码覆盖率
+// 只记录执行次数, 源文件中有哪些语句由 cover 包根据完整的语法树得到
+type Coverage struct {
+	mu       sync.Mutex
+	stmts    map[token.Pos]int64 // 语句的位置 -> 执行次数
+	branches map[branch]int64    // 分支 -> 执行次数
+	calls    map[token.Pos]int64 // 方法定义的位置 -> 调用次数
+}
+
+// if 或者 for 语句的一个分支
+type branch struct {
+	pos   token.Pos
+	index int
+}
+
+// NewCoverage 新建 Coverage
+func NewCoverage() *Coverage {
+	return &Coverage{
+		stmts:    make(map[token.Pos]int64),
+		branches: make(map[branch]int64),
+		calls:    make(map[token.Pos]int64),
+	}
+}
+
+// Stmt 语句的执行次数
+func (c *Coverage) Stmt(pos token.Pos) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stmts[pos]
+}
+
+// Branch if 或者 for 语句的分支的执行次数
+func (c *Coverage) Branch(pos token.Pos, index int) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.branches[branch{pos, index}]
+}
+
+// Calls 方法的调用次数, pos 为方法定义的位置
+func (c *Coverage) Calls(pos token.Pos) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls[pos]
+}
+
+// Files 执行过的源文件 (包括导入的模块), 按名字排序
+func (c *Coverage) Files() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	seen := make(map[string]bool)
+	for pos := range c.stmts {
+		seen[pos.File] = true
+	}
+	files := make([]string, 0, len(seen))
+	for file := range seen {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	return files
+}
+
+func (c *Coverage) stmt(pos token.Pos) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stmts[pos] += 1
+}
+
+func (c *Coverage) branch(pos token.Pos, index int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.branches[branch{pos, index}] += 1
+}
+
+func (c *Coverage) call(pos token.Pos) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls[pos] += 1
+}
+
+// 记录语句执行
+func (e *Exec) cover(pos token.Pos) {
+	if c := e.options.Coverage; c != nil {
+		c.stmt(pos)
+	}
+}
+
+// 记录 if 或者 for 的分支执行
+func (e *Exec) coverBranch(pos token.Pos, index int) {
+	if c := e.options.Coverage; c != nil {
+		c.branch(pos, index)
+	}
+}
+
+// 记录方法调用
+func (e *Exec) coverCall(frame *Frame) {
+	if c := e.options.Coverage; c != nil {
+		c.call(frame.Fn.Pos())
+	}
+}
@@ -0,0 +1,96 @@
+package rt
+
+import (
+	"my-lang/ast"
+	"my-lang/token"
+)
+
+// Hook 每条语句执行前调用, 调试器在这里暂停执行
+// 同一个解释器的多个任务可能同时调用
+type Hook func(e *Exec, stmt ast.Stmt)
+
+// Frame 当前执行的栈帧 (不在方法内时为最外层)
+func (e *Exec) Frame() *Frame {
+	return e.current()
+}
+
+// Root 调用栈的最外层, 同一个任务内的解释器相同
+func (e *Exec) Root() *Frame {
+	return e.root
+}
+
+// Frames 当前调用栈 (由外到内, 包括最外层)
+func (e *Exec) Frames() []*Frame {
+	frames := []*Frame{e.root}
+	for _, frame := range e.frames.Elements() {
+		frames = append(frames, frame.(*Frame))
+	}
+	return frames
+}
+
+// Depth 调用深度 (不含最外层)
+func (e *Exec) Depth() int {
+	return e.frames.Len()
+}
+
+// Stack 当前调用栈 (由外到内)
+func (e *Exec) Stack() []ast.TraceFrame {
+	return e.trace(token.Pos{})
+}
+
+// Scope 当前作用域
+func (e *Exec) Scope() *ast.ObjectList {
+	return e.Parser.Objects
+}
+
+// Locals 当前方法内可见的变量 (由内到外, 被遮蔽的变量不列出)
+// 沿着对象表往上找到方法的作用域为止, 不在方法内时一直找到全局对象表
+func (e *Exec) Locals() []*ast.Variable {
+	frame := e.current()
+	seen := make(map[string]bool)
+	locals := make([]*ast.Variable, 0)
+	for objs := e.Parser.Objects; objs != nil; objs = objs.Previous() {
+		vars := objs.Variables()
+		for i := len(vars) - 1; i >= 0; i-- {
+			if !seen[vars[i].Name] {
+				seen[vars[i].Name] = true
+				locals = append(locals, vars[i])
+			}
+		}
+		if objs == frame.Objs {
+			break
+		}
+	}
+	return locals
+}
+
+// Eval 在当前作用域里求表达式的值, 求值时不调用 Hook
+func (e *Exec) Eval(src string) (value interface{}, err error) {
+	return e.EvalIn(e.Parser.Objects, src)
+}
+
+// EvalIn 在 objs 作用域里求表达式的值 (例如外层栈帧的 Frame.Objs)
+func (e *Exec) EvalIn(objs *ast.ObjectList, src string) (value interface{}, err error) {
+	scriptErr := e.protect(func() {
+		toks, err := scanTokens("<eval>", []byte(src))
+		if err != nil {
+			panic(err)
+		}
+		parser := ast.NewParser(toks, objs)
+		stmt, ok := parser.ParseStmt().(*ast.ExprStmt)
+		for ok && !parser.IsEnd() {
+			ok = parser.ParseStmt() == nil
+		}
+		if !ok {
+			panic(ast.NewError(ast.SyntaxError, "不是表达式: %s", src))
+		}
+
+		exec := e.fork(parser)
+		exec.noHook = true
+		value = exec.expr(stmt.Expr)
+	})
+	if scriptErr != nil {
+		return nil, scriptErr
+	}
+	return value, nil
+}
@@ -0,0 +1,225 @@
+package rt
+
+import (
+	"my-lang/ast"
+	"my-lang/cst"
+	"my-lang/token"
+	"os"
+)
+
+// ExecuteFile 扫描, 解析并运行源文件
+func ExecuteFile(path string, options Options) (interface{}, error) {
+	e, err := loadFile(path, options)
+	if err != nil {
+		return nil, err
+	}
+
+	// 运行
+	return e.Execute()
+}
+
+// 顶层定义的测试块与定义时的对象表
+type testBlock struct {
+	stmt *ast.TestStmt
+	objs *ast.ObjectList
+}
+
+// ExecuteTest 运行源文件的顶层, 然后运行名为 name 的测试块 (test "name" { ... })
+// 没有这个测试块时调用没有参数的方法 name (测试方法)
+// 每次都使用新的全局对象表与模块缓存, 测试之间互不影响
+func ExecuteTest(path string, name string, options Options) error {
+	e, err := loadFile(path, options)
+	if err != nil {
+		return err
+	}
+
+	e.tests = make(map[string]*testBlock)
+	_, err = e.execute(func() interface{} {
+		e.Run()
+		if block, ok := e.tests[name]; ok {
+			// 与方法体一样在新的作用域里执行, 可以 return 提前结束
+			exec := e.fork(ast.NewParser(block.stmt.Body, e.scope(block.objs)))
+			exec.returnable = true
+			return exec.Run()
+		}
+
+		fn, ok := e.Parser.Objects.FindObject(name).(*ast.Function)
+		if !ok || fn.Owner != nil {
+			panic(ast.NewError(ast.NameError, "找不到测试 %s", name))
+		}
+		// 调用栈的最外层显示为测试方法定义的位置
+		e.root.Pos = fn.Pos()
+		return e.callFn(fn, nil)
+	})
+	return err
+}
+
+// 扫描源文件, 创建解释器
+func loadFile(path string, options Options) (*Exec, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// 扫描所有的 tokens
+	toks, scanErr := scanTokens(path, src)
+	if scanErr != nil {
+		return nil, scanErr
+	}
+
+	// 全局对象表 (上一层是内置方法表)
+	globalObjs := ast.NewObjectList(Builtins())
+
+	// 新建解析器
+	p := ast.NewParser(toks, globalObjs)
+
+	// 新建解释器
+	e := NewExecWithOptions(p, options)
+	e.SetFile(path)
+	return e, nil
+}
+
+// 扫描所有的 tokens (由具体语法树得到), 有词法错误时返回第一个错误
+func scanTokens(file string, src []byte) ([]token.Token, *ast.Error) {
+	tree, errs := cst.Parse(file, src)
+	if len(errs) > 0 {
+		err := ast.NewError(ast.SyntaxError, "%s", errs[0].Msg)
+		err.Pos = errs[0].Pos
+		return nil, err
+	}
+	return tree.Tokens(), nil
+}
+
+// Execute 运行，未被捕获的脚本错误作为 error 返回
+// 超过执行预算时返回 ErrBudgetExceeded, Options.Context 取消时返回它的错误 (例如 context.Canceled)
+// 解释器自身的 panic 作为 InternalError 返回, 不会让宿主进程退出
+func (e *Exec) Execute() (value interface{}, err error) {
+	return e.execute(e.Run)
+}
+
+// 解释器自身的 panic (例如 Go 的运行时错误) 转换为脚本错误
+func internalError(r interface{}) *ast.Error {
+	if err, ok := r.(*ast.Error); ok {
+		return err
+	}
+	return ast.NewError(ast.InternalError, "%v", r)
+}
+
+// 在执行预算内运行 fn, 错误的处理与 Execute 相同
+func (e *Exec) execute(fn func() interface{}) (value interface{}, err error) {
+	if err := e.options.Validate(); err != nil {
+		return nil, err
+	}
+
+	// 每次运行重新计算预算, 结束时取消还在运行的任务
+	e.budget = newBudget(e.options)
+	defer e.budget.cancel()
+	defer e.profileExit()
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(budgetStop); ok {
+				value, err = nil, e.budget.err()
+				return
+			}
+			value, err = nil, internalError(r)
+		}
+	}()
+
+	if scriptErr := e.protect(func() {
+		value = fn()
+	}); scriptErr != nil {
+		return nil, scriptErr
+	}
+	return value, nil
+}
+
+// 执行 fn 并捕获脚本错误，出错时将解释器状态恢复到执行前
+// 其它的 panic (例如预算用尽) 恢复状态后继续往外抛
+func (e *Exec) protect(fn func()) (err *ast.Error) {
+	depth := e.frames.Len()
+
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		scriptErr, ok := r.(*ast.Error)
+
+		// 出错时调用栈还未出栈, 此时的调用栈就是抛出错误的位置
+		if ok && scriptErr.Trace == nil {
+			scriptErr.Trace = e.trace(scriptErr.Pos)
+			if !scriptErr.Pos.IsValid() {
+				scriptErr.Pos = scriptErr.Trace[len(scriptErr.Trace)-1].Pos
+			}
+		}
+
+		for e.frames.Len() > depth {
+			e.releaseFrame(e.frames.Pop().(*Frame))
+		}
+
+		if !ok {
+			panic(r)
+		}
+		err = scriptErr
+	}()
+
+	fn()
+	return
+}
+
+// 当前调用栈快照 (由外到内), pos 为出错的位置 (语法错误比当前语句更精确)
+func (e *Exec) trace(pos token.Pos) []ast.TraceFrame {
+	trace := []ast.TraceFrame{e.root.trace()}
+	for _, frame := range e.frames.Elements() {
+		trace = append(trace, frame.(*Frame).trace())
+	}
+	if pos.IsValid() {
+		trace[len(trace)-1].Pos = pos
+	}
+	return trace
+}
+
+// 在新的作用域里执行语法块, 结束时退还作用域的内存
+func (e *Exec) runBlock(toks []token.Token, objs *ast.ObjectList) interface{} {
+	defer e.release(objs)
+	return e.fork(ast.NewParser(toks, objs)).Run()
+}
+
+// 执行 try 语句
+func (e *Exec) try(stmt *ast.TryStmt) (value interface{}) {
+
+	// try { ... }
+	err := e.protect(func() {
+		value = e.runBlock(stmt.Body, e.scope(e.Parser.Objects))
+	})
+
+	// catch e { ... }
+	if err != nil && stmt.CatchBody != nil {
+		objs := e.scope(e.Parser.Objects)
+		if stmt.CatchName != "" {
+			e.define(objs, &ast.Variable{
+				Name:  stmt.CatchName,
+				Value: err,
+			})
+		}
+		err = e.protect(func() {
+			value = e.runBlock(stmt.CatchBody, objs)
+		})
+	}
+
+	// finally { ... }
+	// finally 总是会执行, 如果在 finally 内 return, 则覆盖之前的结果与错误
+	if stmt.FinallyBody != nil {
+		finallyValue := e.runBlock(stmt.FinallyBody, e.scope(e.Parser.Objects))
+		if finallyValue != nil {
+			return finallyValue
+		}
+	}
+
+	// 没有被 catch 处理的错误继续往外抛
+	if err != nil {
+		panic(err)
+	}
+
+	return
+}
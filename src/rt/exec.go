@@ -1,23 +1,68 @@
 package rt
 
 import (
-	"fmt"
 	"math"
 	"my-lang/ast"
-	"reflect"
+	"my-lang/data"
+	"os"
 	"strconv"
+	"strings"
 )
 
-// ReturnLevel 可返回层数
-var returnLevel = 0
-
 type Exec struct {
 	Parser *ast.Parser
+	File   string // 当前执行的源文件
+
+	root       *Frame // 调用栈的起点 (主程序为 <main>, 任务为 <task>)
+	returnable bool   // 是否在方法体或者语句块内, 此时才可以 return
+	tail       bool   // 是否是方法体 (或其中的 if/for) 的语法块, 此时 return f() 是尾调用
+	noHook     bool   // 不调用 Options.Hook (调试器求值时)
+
+	tests map[string]*testBlock // 文件顶层定义的测试块 (只有运行测试时的主解释器记录, 为 nil 时跳过)
+
+	// 以下状态属于一个解释器, 不同的解释器之间互不影响, 可以在多个 goroutine 里同时运行
+	options *Options      // 解释器配置 (所有子解释器共用)
+	budget  *budget       // 执行预算 (所有子解释器与任务共用)
+	out     *output       // print 的输出 (所有子解释器与任务共用)
+	modules *ModuleLoader // 模块缓存 (所有子解释器共用)
+	frames  *data.Stack   // 调用栈 (所有子解释器共用, 每个任务与生成器各有一个)
 }
 
 func NewExec(parser *ast.Parser) *Exec {
+	return NewExecWithOptions(parser, DefaultOptions())
+}
+
+func NewExecWithOptions(parser *ast.Parser, options Options) *Exec {
+	if options.Stdout == nil {
+		options.Stdout = os.Stdout
+	}
+	return &Exec{
+		Parser: parser,
+		root:   &Frame{name: "<main>", Objs: parser.Objects},
+
+		options: &options,
+		budget:  newBudget(&Options{}), // Execute 时才开始计算预算
+		out:     &output{w: options.Stdout},
+		modules: NewModuleLoader(),
+		frames:  data.NewStack(),
+	}
+}
+
+// 在新的语法分析器上创建子解释器
+func (e *Exec) fork(parser *ast.Parser) *Exec {
 	return &Exec{
 		Parser: parser,
+		File:   e.File,
+
+		root:       e.root,
+		returnable: e.returnable,
+		noHook:     e.noHook,
+
+		options: e.options,
+		budget:  e.budget,
+		out:     e.out,
+		modules: e.modules,
+		frames:  e.frames,
 	}
 }
 
@@ -29,6 +74,14 @@ func (e *Exec) Run() interface{} {
 
 		stmt := e.Parser.ParseStmt()
 		if stmt != nil {
+			e.budget.step()
+			frame := e.current()
+			frame.Pos, frame.count = stmt.Pos(), frame.count+1
+			e.profile()
+			e.cover(stmt.Pos())
+			if e.options.Hook != nil && !e.noHook {
+				e.options.Hook(e, stmt)
+			}
 			value := e.stmt(stmt)
 			if value != nil {
 				return value
@@ -50,41 +103,105 @@ func (e *Exec) stmt(stmt ast.Stmt) interface{} {
 		objs := e.Parser.Objects.FindObject(stmt.Name)
 
 		if objs == nil {
-			e.Parser.Objects.Add(&ast.Variable{
+			e.define(e.Parser.Objects, &ast.Variable{
 				Name:  stmt.Name,
 				Value: e.expr(stmt.Value),
 			})
+		} else if v, ok := objs.(*ast.Variable); ok {
+			v.Store(e.expr(stmt.Value))
 		} else {
-			objs.(*ast.Variable).Value = e.expr(stmt.Value)
+			panic(ast.NewError(ast.TypeError, "%s 不是变量, 不能赋值", stmt.Name))
 		}
+	case *ast.FieldAssignStmt:
+		// 成员赋值语句
+		stmt := stmt.(*ast.FieldAssignStmt)
+		record := e.record(e.expr(stmt.X), stmt.Name)
+		record.Set(stmt.Name, e.expr(stmt.Value))
+	case *ast.IndexAssignStmt:
+		// 下标赋值语句
+		stmt := stmt.(*ast.IndexAssignStmt)
+		list := e.list(e.expr(stmt.X))
+		list.SetIndex(e.index(list, e.expr(stmt.Index)), e.expr(stmt.Value))
 	case *ast.PrintStmt:
 		// 打印语句
 		stmt := stmt.(*ast.PrintStmt)
-		fmt.Println(e.expr(stmt.Expr))
+		e.out.println(e.expr(stmt.Expr))
 	case *ast.ReturnStmt:
-		if returnLevel == 0 {
-			panic("错误: return 语句在不合法的位置")
+		if !e.returnable {
+			panic(ast.NewError(ast.SyntaxError, "return 语句在不合法的位置"))
 		}
 
 		stmt := stmt.(*ast.ReturnStmt)
+		if e.tail {
+			// return f(...) 尾调用, 交给外层的 invoke 复用栈帧
+			if call := e.tailCall(stmt.Expr); call != nil {
+				return call
+			}
+		}
 		return e.expr(stmt.Expr)
+	case *ast.ImportStmt:
+		// 导入语句
+		if e.returnable {
+			panic(ast.NewError(ast.ImportError, "import 语句只能在文件顶层使用"))
+		}
+
+		stmt := stmt.(*ast.ImportStmt)
+		e.require(CapFsRead, "import")
+		e.importModule(stmt)
+	case *ast.TestStmt:
+		// 测试块, 运行测试时才执行
+		if e.returnable {
+			panic(ast.NewError(ast.SyntaxError, "test 块只能在文件顶层定义"))
+		}
+
+		stmt := stmt.(*ast.TestStmt)
+		if e.tests != nil {
+			e.tests[stmt.Name] = &testBlock{stmt: stmt, objs: e.Parser.Objects}
+		}
+	case *ast.TryStmt:
+		// 异常处理语句
+		stmt := stmt.(*ast.TryStmt)
+		value := e.try(stmt)
+		if value != nil {
+			return value
+		}
+	case *ast.ThrowStmt:
+		// 抛出异常
+		stmt := stmt.(*ast.ThrowStmt)
+		panic(ast.ThrowValue(e.expr(stmt.Expr)))
+	case *ast.YieldStmt:
+		// 生成器产出值
+		stmt := stmt.(*ast.YieldStmt)
+		e.yield(e.expr(stmt.Expr))
+	case *ast.SelectStmt:
+		// 等待通道操作
+		stmt := stmt.(*ast.SelectStmt)
+		value := e.selectCase(stmt)
+		if value != nil {
+			return value
+		}
 	case *ast.IfStmt:
 		stmt := stmt.(*ast.IfStmt)
 		cond := e.expr(stmt.Cond)
-		if reflect.TypeOf(cond).String() != "bool" {
-			panic("错误: if 条件必须是 bool 类型")
+		if _, ok := cond.(bool); !ok {
+			panic(ast.NewError(ast.TypeError, "if 条件必须是 bool 类型"))
 		}
 
 		var parser *ast.Parser = nil
 		var value interface{} = nil
+		objs := e.scope(e.Parser.Objects)
+		defer e.release(objs)
 		if cond == true {
-			parser = ast.NewParser(stmt.TrueBody, ast.NewObjectList(e.Parser.Objects))
+			e.coverBranch(stmt.Pos(), BranchThen)
+			parser = ast.NewParser(stmt.TrueBody, objs)
 		} else {
-			parser = ast.NewParser(stmt.FalseBody, ast.NewObjectList(e.Parser.Objects))
+			e.coverBranch(stmt.Pos(), BranchElse)
+			parser = ast.NewParser(stmt.FalseBody, objs)
 		}
 
 		// 执行对应分支的语法块
-		exec := NewExec(parser)
+		exec := e.fork(parser)
+		exec.tail = e.tail
 		value = exec.Run()
 
 		// 如果在if内return，则提前结束外层的作用域
@@ -93,12 +210,20 @@ func (e *Exec) stmt(stmt ast.Stmt) interface{} {
 		}
 	case *ast.ForStmt:
 		stmt := stmt.(*ast.ForStmt)
+		if stmt.Iter != nil {
+			return e.forIn(stmt)
+		}
+
 		cond := e.expr(stmt.Cond)
 
-		objs := ast.NewObjectList(e.Parser.Objects)
+		objs := e.scope(e.Parser.Objects)
+		defer e.release(objs)
 		for cond == true {
+			e.budget.step()
+			e.coverBranch(stmt.Pos(), BranchBody)
 			parser := ast.NewParser(stmt.Body, objs)
-			exec := NewExec(parser)
+			exec := e.fork(parser)
+			exec.tail = e.tail
 			value := exec.Run()
 
 			// 如果在for内return，则提前结束外层的作用域
@@ -121,11 +246,17 @@ func (e *Exec) expr(expr ast.Expr) interface{} {
 		lval, rval := e.expr(expr.Left), e.expr(expr.Right)
 		ltype, rtype := ast.ProcessType(ast.GetType(lval), ast.GetType(rval))
 
+		// 整数隐式转换成浮点数
+		if ast.SameType(ltype, rtype, ast.FLOAT) {
+			lval, rval = ast.ToFloat(lval), ast.ToFloat(rval)
+		}
+
 		switch expr.Op {
 		// 加法表达式
 		case ast.ADD:
 			// 字符串相加: 'abc' + 'def' = 'abcdef'
 			if ast.SameType(ltype, rtype, ast.STRING) {
+				e.alloc(int64(len(lval.(string)) + len(rval.(string))))
 				return lval.(string) + rval.(string)
 			}
 
@@ -139,7 +270,7 @@ func (e *Exec) expr(expr ast.Expr) interface{} {
 				return lval.(float64) + rval.(float64)
 			}
 
-			panic(fmt.Sprintf("错误: 不合法的运算 %s + %s", ast.TypeString(ltype), ast.TypeString(rtype)))
+			panic(ast.NewError(ast.TypeError, "不合法的运算 %s + %s", ast.TypeString(ltype), ast.TypeString(rtype)))
 		case ast.SUB:
 			// 整数相减: 1 - 2 = -1
 			if ast.SameType(ltype, rtype, ast.INT) {
@@ -151,18 +282,23 @@ func (e *Exec) expr(expr ast.Expr) interface{} {
 				return lval.(float64) - rval.(float64)
 			}
 
-			panic(fmt.Sprintf("错误: 不合法的运算 %s - %s", ast.TypeString(ltype), ast.TypeString(rtype)))
+			panic(ast.NewError(ast.TypeError, "不合法的运算 %s - %s", ast.TypeString(ltype), ast.TypeString(rtype)))
 		case ast.MUL:
 
 			// 字符串乘整数: 'str' * 3 = 'strstrstr'
 			if ltype == ast.INT && rtype == ast.STRING {
 				lval, rval = ast.SortByType(lval, rval)
 
-				result := ""
-				for i := 0; i < ast.Int64ToInt(lval.(int64)); i++ {
-					result += rval.(string)
+				n, str := lval.(int64), rval.(string)
+				if n <= 0 || str == "" {
+					return ""
 				}
-				return result
+				// 先检查配额再分配, 避免 'x' * 1000000000 耗尽内存
+				if n > math.MaxInt64/int64(len(str)) {
+					panic(ast.NewError(ast.MemoryError, "字符串过长"))
+				}
+				e.alloc(n * int64(len(str)))
+				return strings.Repeat(str, ast.Int64ToInt(n))
 			}
 
 			// 整数相乘: 1 * 2 = 2
@@ -175,8 +311,10 @@ func (e *Exec) expr(expr ast.Expr) interface{} {
 				return lval.(float64) * rval.(float64)
 			}
 
-			panic(fmt.Sprintf("错误: 不合法的运算 %s * %s", ast.TypeString(ltype), ast.TypeString(rtype)))
+			panic(ast.NewError(ast.TypeError, "不合法的运算 %s * %s", ast.TypeString(ltype), ast.TypeString(rtype)))
 		case ast.DIV:
+			e.checkZero(rval)
+
 			// 整数相除: 1 / 2 = 0.5
 			if ast.SameType(ltype, rtype, ast.INT) {
 				return float64(lval.(int64)) / float64(rval.(int64))
@@ -187,8 +325,10 @@ func (e *Exec) expr(expr ast.Expr) interface{} {
 				return lval.(float64) / rval.(float64)
 			}
 
-			panic(fmt.Sprintf("错误: 不合法的运算 %s / %s", ast.TypeString(ltype), ast.TypeString(rtype)))
+			panic(ast.NewError(ast.TypeError, "不合法的运算 %s / %s", ast.TypeString(ltype), ast.TypeString(rtype)))
 		case ast.MOD:
+			e.checkZero(rval)
+
 			// 整数相除: 3 % 2 = 1
 			if ast.SameType(ltype, rtype, ast.INT) {
 				return lval.(int64) % rval.(int64)
@@ -199,7 +339,7 @@ func (e *Exec) expr(expr ast.Expr) interface{} {
 				return math.Mod(lval.(float64), rval.(float64))
 			}
 
-			panic(fmt.Sprintf("错误: 不合法的运算 %s % %s", ast.TypeString(ltype), ast.TypeString(rtype)))
+			panic(ast.NewError(ast.TypeError, "不合法的运算 %s %% %s", ast.TypeString(ltype), ast.TypeString(rtype)))
 		case ast.EQ:
 			// 1 == 2
 			if ast.SameType(ltype, rtype, ast.INT) ||
@@ -209,7 +349,12 @@ func (e *Exec) expr(expr ast.Expr) interface{} {
 				return lval == rval
 			}
 
-			panic(fmt.Sprintf("错误: 不合法的运算 %s == %s", ast.TypeString(ltype), ast.TypeString(rtype)))
+			// 结构体按字段比较, 列表按元素比较
+			if ast.SameType(ltype, rtype, ast.RECORD) || ast.SameType(ltype, rtype, ast.LIST) {
+				return ast.Equal(lval, rval)
+			}
+
+			panic(ast.NewError(ast.TypeError, "不合法的运算 %s == %s", ast.TypeString(ltype), ast.TypeString(rtype)))
 		case ast.NQ:
 			// 1 != 2
 			if ast.SameType(ltype, rtype, ast.INT) ||
@@ -219,7 +364,12 @@ func (e *Exec) expr(expr ast.Expr) interface{} {
 				return lval != rval
 			}
 
-			panic(fmt.Sprintf("错误: 不合法的运算 %s != %s", ast.TypeString(ltype), ast.TypeString(rtype)))
+			// 结构体按字段比较, 列表按元素比较
+			if ast.SameType(ltype, rtype, ast.RECORD) || ast.SameType(ltype, rtype, ast.LIST) {
+				return !ast.Equal(lval, rval)
+			}
+
+			panic(ast.NewError(ast.TypeError, "不合法的运算 %s != %s", ast.TypeString(ltype), ast.TypeString(rtype)))
 
 		case ast.GT:
 			// 1 > 2
@@ -237,7 +387,7 @@ func (e *Exec) expr(expr ast.Expr) interface{} {
 				return lval.(string) > rval.(string)
 			}
 
-			panic(fmt.Sprintf("错误: 不合法的运算 %s > %s", ast.TypeString(ltype), ast.TypeString(rtype)))
+			panic(ast.NewError(ast.TypeError, "不合法的运算 %s > %s", ast.TypeString(ltype), ast.TypeString(rtype)))
 		case ast.GE:
 			// 1 >= 2
 			if ast.SameType(ltype, rtype, ast.INT) {
@@ -254,7 +404,7 @@ func (e *Exec) expr(expr ast.Expr) interface{} {
 				return lval.(string) >= rval.(string)
 			}
 
-			panic(fmt.Sprintf("错误: 不合法的运算 %s >= %s", ast.TypeString(ltype), ast.TypeString(rtype)))
+			panic(ast.NewError(ast.TypeError, "不合法的运算 %s >= %s", ast.TypeString(ltype), ast.TypeString(rtype)))
 		case ast.LT:
 			// 1 < 2
 			if ast.SameType(ltype, rtype, ast.INT) {
@@ -271,7 +421,7 @@ func (e *Exec) expr(expr ast.Expr) interface{} {
 				return lval.(string) < rval.(string)
 			}
 
-			panic(fmt.Sprintf("错误: 不合法的运算 %s < %s", ast.TypeString(ltype), ast.TypeString(rtype)))
+			panic(ast.NewError(ast.TypeError, "不合法的运算 %s < %s", ast.TypeString(ltype), ast.TypeString(rtype)))
 		case ast.LE:
 			// 1 <= 2
 			if ast.SameType(ltype, rtype, ast.INT) {
@@ -288,7 +438,7 @@ func (e *Exec) expr(expr ast.Expr) interface{} {
 				return lval.(string) <= rval.(string)
 			}
 
-			panic(fmt.Sprintf("错误: 不合法的运算 %s <= %s", ast.TypeString(ltype), ast.TypeString(rtype)))
+			panic(ast.NewError(ast.TypeError, "不合法的运算 %s <= %s", ast.TypeString(ltype), ast.TypeString(rtype)))
 		}
 	case *ast.LitExpr:
 		expr := expr.(*ast.LitExpr)
@@ -306,62 +456,203 @@ func (e *Exec) expr(expr ast.Expr) interface{} {
 			return expr.Lit
 		case ast.BOOL:
 			// 布尔值字面量
-			return expr.Lit
+			return expr.Lit == "true"
 
 		}
 	case *ast.IdentityExpr:
 		// 变量
 		expr := expr.(*ast.IdentityExpr)
-		return expr.Object.(*ast.Variable).Value
+		switch obj := expr.Object.(type) {
+		case *ast.Variable:
+			return obj.Load()
+		case *ast.Function:
+			panic(ast.NewError(ast.TypeError, "方法 %s 不能作为值使用", obj.Name))
+		case *ast.Builtin:
+			panic(ast.NewError(ast.TypeError, "内置方法 %s 不能作为值使用", obj.Name))
+		}
+		panic(ast.NewError(ast.TypeError, "对象不能作为值使用"))
 	case *ast.BlockExpr:
 		// 语句块
 		expr := expr.(*ast.BlockExpr)
 
-		returnLevel += 1
-
-		// 语句块内对象表
-		blockObj := ast.NewObjectList(e.Parser.Objects)
-		parser := ast.NewParser(expr.Toks, blockObj)
-		exec := NewExec(parser)
-		val := exec.Run()
-
-		returnLevel -= 1
-		return val
+		return e.blockExpr(expr)
 	case *ast.CallFnExpr:
 		// 方法调用
 		expr := expr.(*ast.CallFnExpr)
 		return e.callFn(expr.Fn, expr.Params)
+	case *ast.CallBuiltinExpr:
+		// 内置方法调用
+		expr := expr.(*ast.CallBuiltinExpr)
+		if expr.Builtin.Name == "assert_throws" {
+			return e.assertThrows(expr)
+		}
+		args := make([]interface{}, len(expr.Params))
+		for i, param := range expr.Params {
+			args[i] = e.expr(param)
+		}
+		return expr.Builtin.Fn(e.budget.ctx, args)
+	case *ast.ListExpr:
+		// 列表字面量
+		expr := expr.(*ast.ListExpr)
+		e.alloc(listMemory(len(expr.Elements)))
+		elements := make([]interface{}, len(expr.Elements))
+		for i, element := range expr.Elements {
+			elements[i] = e.expr(element)
+		}
+		return ast.NewListValue(elements)
+	case *ast.IndexExpr:
+		// 下标访问
+		expr := expr.(*ast.IndexExpr)
+		list := e.list(e.expr(expr.X))
+		return list.Index(e.index(list, e.expr(expr.Index)))
+	case *ast.NewRecordExpr:
+		// 构造结构体
+		expr := expr.(*ast.NewRecordExpr)
+		e.alloc(listMemory(len(expr.Record.Fields)))
+		record := ast.NewRecordValue(expr.Record)
+		for i, field := range expr.Fields {
+			record.Fields[i] = e.expr(field)
+		}
+		return record
+	case *ast.FieldExpr:
+		// 成员访问
+		expr := expr.(*ast.FieldExpr)
+		val := e.expr(expr.X)
+		if err, ok := val.(*ast.Error); ok {
+			return err.Field(expr.Name)
+		}
+		return e.record(val, expr.Name).Get(expr.Name)
+	case *ast.MethodCallExpr:
+		// 类型方法调用
+		expr := expr.(*ast.MethodCallExpr)
+		return e.callMethod(e.expr(expr.X), expr.Name, expr.Params)
+	case *ast.SpawnExpr:
+		// 启动并发任务
+		expr := expr.(*ast.SpawnExpr)
+		return e.spawn(expr)
 
 	}
 	return nil
 }
 
-// 调用方法
-func (e *Exec) callFn(fn *ast.Function, params []ast.Expr) (value interface{}) {
+// 语句块表达式在自己的作用域里执行, 结束时退还作用域的内存
+func (e *Exec) blockExpr(expr *ast.BlockExpr) interface{} {
+	objs := e.scope(e.Parser.Objects)
+	defer e.release(objs)
+	exec := e.fork(ast.NewParser(expr.Toks, objs))
+	exec.returnable = true
+	return exec.Run()
+}
+
+// 下标访问的对象必须是列表
+func (e *Exec) list(val interface{}) *ast.ListValue {
+	list, ok := val.(*ast.ListValue)
+	if !ok {
+		panic(ast.NewError(ast.TypeError, "%s 类型不支持下标访问", ast.TypeString(ast.GetType(val))))
+	}
+	return list
+}
+
+// 下标必须是整数且不能越界
+func (e *Exec) index(list *ast.ListValue, val interface{}) int {
+	i, ok := val.(int64)
+	if !ok {
+		panic(ast.NewError(ast.TypeError, "下标必须是 int 类型, 实际是 %s", ast.TypeString(ast.GetType(val))))
+	}
+	// 列表不会变短, 检查后下标一直有效
+	if n := list.Len(); i < 0 || i >= int64(n) {
+		panic(ast.NewError(ast.IndexError, "下标 %d 越界 (长度 %d)", i, n))
+	}
+	return ast.Int64ToInt(i)
+}
+
+// 成员访问的对象必须是结构体
+func (e *Exec) record(val interface{}, name string) *ast.RecordValue {
+	if super, ok := val.(*ast.Super); ok {
+		return super.Self
+	}
 
-	// 函数局部变量表
-	fnObjs := ast.NewObjectList(fn.ParentObjs)
+	record, ok := val.(*ast.RecordValue)
+	if !ok {
+		panic(ast.NewError(ast.AttributeError, "%s 类型没有字段 %s", ast.TypeString(ast.GetType(val)), name))
+	}
+	return record
+}
 
-	// 将具体的表达式传入具体的参数上
-	for i := 0; i < len(params); i++ {
-		fnObjs.Add(&ast.Variable{
-			Name:  fn.Args[i],
-			Value: e.expr(params[i]),
-		})
+// 除数不能为 0
+func (e *Exec) checkZero(val interface{}) {
+	if val == int64(0) || val == float64(0) {
+		panic(ast.NewError(ast.ZeroDivisionError, "除数不能为 0"))
 	}
+}
 
-	// 可返回层数+1
-	returnLevel += 1
+// 将调用参数与方法参数一一对应
+// 1. 位置参数按顺序绑定, 多出的位置参数收集到 ...rest 里
+// 2. 命名参数按名字绑定
+// 3. 没有绑定的参数使用默认值
+func (e *Exec) bindArgs(fn *ast.Function, params []ast.Param) []interface{} {
+
+	values := make([]interface{}, len(fn.Args))
+	bound := make([]bool, len(fn.Args))
+
+	// 位置参数的个数 (不包括 ...rest)
+	fixed := len(fn.Args)
+	var rest *ast.ListValue
+	if fixed > 0 && fn.Args[fixed-1].Rest {
+		fixed -= 1
+		e.alloc(listMemory(0))
+		rest = ast.NewListValue(make([]interface{}, 0))
+		values[fixed], bound[fixed] = rest, true
+	}
 
-	// 开始语法分析
-	parser := ast.NewParser(fn.Body, fnObjs)
-	exec := NewExec(parser)
+	position := 0
+	for _, param := range params {
+		val := e.expr(param.Value)
 
-	// 解析方法体
-	value = exec.Run()
+		// f(x: 1)
+		if param.Name != "" {
+			i := argIndex(fn, param.Name)
+			if i < 0 || fn.Args[i].Rest {
+				panic(ast.NewError(ast.TypeError, "%s 没有参数 %s", fn.Signature(), param.Name))
+			}
+			if bound[i] {
+				panic(ast.NewError(ast.TypeError, "%s 的参数 %s 重复赋值", fn.Signature(), param.Name))
+			}
+			values[i], bound[i] = val, true
+			continue
+		}
 
-	// 可返回层数-1
-	returnLevel -= 1
+		// f(1)
+		if position < fixed {
+			values[position], bound[position] = val, true
+		} else if rest != nil {
+			e.alloc(valueSize)
+			rest.Elements = append(rest.Elements, val)
+		} else {
+			panic(ast.NewError(ast.TypeError, "%s 最多需要 %d 个参数, 实际提供 %d 个", fn.Signature(), fixed, len(params)))
+		}
+		position += 1
+	}
+
+	for i, arg := range fn.Args {
+		if bound[i] {
+			continue
+		}
+		if arg.Default == nil {
+			panic(ast.NewError(ast.TypeError, "%s 缺少参数 %s", fn.Signature(), arg.Name))
+		}
+		values[i] = e.expr(arg.Default)
+	}
 
-	return
+	return values
+}
+
+// 参数下标，不存在则返回 -1
+func argIndex(fn *ast.Function, name string) int {
+	for i, arg := range fn.Args {
+		if arg.Name == name {
+			return i
+		}
+	}
+	return -1
 }
@@ -0,0 +1,96 @@
+package rt
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+// 独立的解释器可以同时运行, 用 go test -race 检查
+func TestExecuteFileParallel(t *testing.T) {
+	src := `type Point { x, y }
+
+gen(n) = {
+    i = 0
+    for i < n {
+        yield Point{x: i, y: i * 2}
+        i = i + 1
+    }
+}
+
+sum(c, n) = {
+    s = 0
+    for p in gen(n) {
+        s = s + p.x + p.y
+    }
+    send(c, s)
+    return s
+}
+
+c = chan(2)
+tasks = [spawn sum(c, 2000), spawn sum(c, 1000)]
+results = wait(tasks)
+total = recv(c) + recv(c)
+
+try {
+    throw 'boom'
+} catch e {
+    print e
+}
+try {
+    xs = [1, 2]
+    print xs[5]
+} catch e {
+    print 'caught'
+}
+print total
+print results
+`
+	program := filepath.Join(t.TempDir(), "main.m")
+	if err := os.WriteFile(program, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	want := "Error: boom\ncaught\n7495500\n[5997000, 1498500]\n"
+
+	// 生成器在遍历中途不能被回收
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				runtime.GC()
+			}
+		}
+	}()
+
+	const n = 8
+	var wg sync.WaitGroup
+	outputs := make([]bytes.Buffer, n)
+	errs := make([]error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			options := DefaultOptions()
+			options.Stdout = &outputs[i]
+			_, errs[i] = ExecuteFile(program, options)
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < n; i++ {
+		if errs[i] != nil {
+			t.Errorf("解释器 %d 出错: %s", i, errs[i])
+			continue
+		}
+		if got := outputs[i].String(); got != want {
+			t.Errorf("解释器 %d 的输出应该是 %q, 实际是 %q", i, want, got)
+		}
+	}
+}
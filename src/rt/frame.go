@@ -0,0 +1,225 @@
+package rt
+
+import (
+	"my-lang/ast"
+	"my-lang/token"
+)
+
+// Frame 调用栈帧
+type Frame struct {
+	Fn   *ast.Function   // 调用的方法 (最外层与模块顶层为 nil)
+	Objs *ast.ObjectList // 局部变量表 (最外层与模块顶层为全局对象表, 任务的最外层为 nil)
+	Args []interface{}   // 调用参数 (与 Fn.Args 一一对应)
+	Pos  token.Pos       // 正在执行的语句的位置
+
+	name    string     // 最外层与模块顶层的名字
+	gen     *generator // 生成器的栈帧 (普通方法为 nil)
+	count   int        // 已经执行的语句数
+	charged bool       // 计入了内存分配, 执行结束时退还 (方法调用的栈帧)
+}
+
+// Name 调用栈中显示的方法名
+func (f *Frame) Name() string {
+	switch {
+	case f.Fn == nil:
+		return f.name
+	case f.Fn.Owner != nil:
+		return f.Fn.Owner.Name + "." + f.Fn.Name
+	}
+	return f.Fn.Name
+}
+
+// Entered 是否刚进入方法 (正在执行第一条语句)
+func (f *Frame) Entered() bool {
+	return f.count == 1
+}
+
+// 调用栈中的一层 (错误信息使用)
+func (f *Frame) trace() ast.TraceFrame {
+	frame := ast.TraceFrame{
+		Name: f.Name(),
+		Pos:  f.Pos,
+	}
+	if f.Fn != nil {
+		frame.Args = make([]ast.TraceArg, len(f.Args))
+		for i, arg := range f.Args {
+			frame.Args[i] = ast.TraceArg{Name: f.Fn.Args[i].Name, Value: arg}
+		}
+	}
+	return frame
+}
+
+// 当前执行的栈帧 (不在方法内时为最外层)
+func (e *Exec) current() *Frame {
+	if frame, ok := e.frames.Top().(*Frame); ok {
+		return frame
+	}
+	return e.root
+}
+
+// 尾调用: 参数已经绑定完毕, 等待外层的 invoke 在当前栈帧上执行
+type tailCall struct {
+	frame *Frame
+}
+
+// 压入新的栈帧, 超过最大调用深度则抛出错误
+func (e *Exec) pushFrame(frame *Frame) {
+	if depth := e.maxDepth(); e.frames.Len() >= depth {
+		e.releaseFrame(frame)
+		panic(ast.NewError(ast.StackOverflowError, "超过最大调用深度 %d", depth))
+	}
+	e.frames.Push(frame)
+}
+
+// 最大调用深度, 为 0 时为 MaxDepthLimit (Execute 开始前已经检查过不超过上限)
+func (e *Exec) maxDepth() int {
+	if e.options.MaxDepth == 0 {
+		return MaxDepthLimit
+	}
+	return e.options.MaxDepth
+}
+
+// 调用类型方法, 接收者绑定到 self
+func (e *Exec) callMethod(recv interface{}, name string, params []ast.Param) interface{} {
+	return e.invoke(e.methodFrame(recv, name, params))
+}
+
+// 调用方法
+func (e *Exec) callFn(fn *ast.Function, params []ast.Param) interface{} {
+	return e.invoke(e.fnFrame(fn, params))
+}
+
+// 如果是 return f(...) 形式, 则绑定参数并返回尾调用
+func (e *Exec) tailCall(expr ast.Expr) *tailCall {
+	switch expr := expr.(type) {
+	case *ast.CallFnExpr:
+		return &tailCall{e.fnFrame(expr.Fn, expr.Params)}
+	case *ast.MethodCallExpr:
+		return &tailCall{e.methodFrame(e.expr(expr.X), expr.Name, expr.Params)}
+	}
+	return nil
+}
+
+// 创建方法调用的栈帧
+func (e *Exec) fnFrame(fn *ast.Function, params []ast.Param) *Frame {
+	// 函数局部变量表
+	fnObjs := ast.NewObjectList(fn.ParentObjs)
+	args := e.bindParams(fn, fnObjs, params)
+
+	return e.newFrame(fn, fnObjs, args)
+}
+
+// 创建类型方法调用的栈帧
+func (e *Exec) methodFrame(recv interface{}, name string, params []ast.Param) *Frame {
+
+	var self *ast.RecordValue
+	var record *ast.Record
+	switch recv := recv.(type) {
+	case *ast.RecordValue:
+		// p.norm()
+		self, record = recv, recv.Type
+	case *ast.Super:
+		// super.norm()
+		self, record = recv.Self, recv.Record
+	default:
+		panic(ast.NewError(ast.AttributeError, "%s 类型没有方法 %s", ast.TypeString(ast.GetType(recv)), name))
+	}
+
+	fn := record.FindMethod(name)
+	if fn == nil {
+		panic(ast.NewError(ast.AttributeError, "类型 %s 没有方法 %s", record.Name, name))
+	}
+
+	// 方法局部变量表
+	fnObjs := ast.NewObjectList(fn.ParentObjs)
+	fnObjs.Add(&ast.Variable{
+		Name:  "self",
+		Value: self,
+	})
+	if fn.Owner.Parent != nil {
+		fnObjs.Add(&ast.Variable{
+			Name: "super",
+			Value: &ast.Super{
+				Self:   self,
+				Record: fn.Owner.Parent,
+			},
+		})
+	}
+	args := e.bindParams(fn, fnObjs, params)
+
+	return e.newFrame(fn, fnObjs, args)
+}
+
+// 创建栈帧, 计入内存分配 (此时对象表里只有参数)
+func (e *Exec) newFrame(fn *ast.Function, objs *ast.ObjectList, args []interface{}) *Frame {
+	e.alloc(scopeMemory(objs))
+	return &Frame{
+		Fn:      fn,
+		Objs:    objs,
+		Args:    args,
+		charged: true,
+	}
+}
+
+// 将具体的表达式传入具体的参数上, 返回参数值
+func (e *Exec) bindParams(fn *ast.Function, fnObjs *ast.ObjectList, params []ast.Param) []interface{} {
+	args := e.bindArgs(fn, params)
+	for i, val := range args {
+		fnObjs.Add(&ast.Variable{
+			Name:  fn.Args[i].Name,
+			Value: val,
+		})
+	}
+	return args
+}
+
+// 在栈帧上执行方法体
+func (e *Exec) invoke(frame *Frame) (value interface{}) {
+	e.profileCall(frame)
+	e.coverCall(frame)
+
+	// 含有 yield 的方法返回生成器, 方法体在生成器恢复时才执行
+	if frame.Fn.Generator {
+		return e.newGenerator(frame)
+	}
+
+	e.pushFrame(frame)
+
+	for {
+		e.budget.step()
+
+		// 开始语法分析
+		parser := ast.NewParser(frame.Fn.Body, frame.Objs)
+		exec := e.fork(parser)
+		exec.returnable, exec.tail = true, true
+
+		// 解析方法体
+		value = exec.Run()
+
+		// 尾调用: 用新的栈帧替换当前栈帧, 继续循环而不是递归
+		call, ok := value.(*tailCall)
+		if !ok {
+			break
+		}
+
+		// 尾调用的是生成器方法, 直接返回新的生成器
+		if call.frame.Fn.Generator {
+			e.profileCall(call.frame)
+			e.coverCall(call.frame)
+			value = e.newGenerator(call.frame)
+			break
+		}
+		e.frames.Pop()
+		e.releaseFrame(frame)
+		e.profileCall(call.frame)
+		e.coverCall(call.frame)
+		e.frames.Push(call.frame)
+		frame = call.frame
+	}
+
+	e.frames.Pop()
+	e.releaseFrame(frame)
+	e.profile()
+
+	return
+}
@@ -0,0 +1,228 @@
+package rt
+
+import (
+	"my-lang/ast"
+	"my-lang/data"
+	"runtime"
+	"sync"
+)
+
+// 生成器
+// 方法体在单独的 goroutine 里执行, yield 时挂起等待下一次恢复, 栈帧与局部变量因此得以保留
+// 同一时刻只有调用方或者生成器其中一方在执行, 两者通过通道交接
+// 生成器有自己的调用栈, 可以在其它任务里恢复执行
+type generator struct {
+	mu    sync.Mutex // 多个任务同时恢复时逐个执行
+	exec  *Exec
+	frame *Frame
+
+	resume  chan struct{}  // 调用方 -> 生成器: 继续执行
+	yielded chan genResult // 生成器 -> 调用方: 产出的值或者结束
+
+	started bool
+	done    bool
+	stopped bool        // 已经被终结器结束, 不能再恢复
+	result  interface{} // 方法体 return 的值
+}
+
+// 生成器执行一步的结果
+type genResult struct {
+	value interface{}
+	done  bool
+	err   *ast.Error
+	stop  bool        // 预算用尽
+	panic interface{} // 方法体内其它的 panic, 交给调用方重新抛出
+}
+
+// 生成器被丢弃时用于结束其 goroutine
+type genStop struct{}
+
+// 创建生成器, 方法体在第一次恢复时才开始执行
+func (e *Exec) newGenerator(frame *Frame) *ast.GeneratorValue {
+	exec := e.fork(nil)
+	exec.frames = data.NewStack()
+	exec.modules = NewModuleLoader()
+
+	g := &generator{
+		exec:    exec,
+		frame:   frame,
+		resume:  make(chan struct{}),
+		yielded: make(chan genResult),
+	}
+	frame.gen = g
+
+	value := &ast.GeneratorValue{
+		Fn:     frame.Fn,
+		Resume: g.next,
+	}
+
+	// 生成器没有执行完就被丢弃时, 结束挂起的 goroutine
+	// 使用生成器的地方 (例如 for x in) 必须持有 value, 只持有 Resume 时仍然可能被回收
+	runtime.SetFinalizer(value, func(*ast.GeneratorValue) {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		if g.started && !g.done {
+			g.stopped = true
+			close(g.resume)
+		}
+		g.exec.releaseFrame(g.frame)
+	})
+
+	return value
+}
+
+// 恢复执行到下一个 yield, 结束时 done 为 true, 值为方法体 return 的值
+func (g *generator) next() (interface{}, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.done {
+		return g.result, true
+	}
+	if g.stopped {
+		panic(ast.NewError(ast.InternalError, "生成器 %s 已经被回收, 不能继续执行", g.frame.Fn.Name))
+	}
+
+	e := g.exec
+	e.pushFrame(g.frame)
+
+	if !g.started {
+		g.started = true
+		go g.run()
+	} else {
+		g.resume <- struct{}{}
+	}
+	r := <-g.yielded
+
+	e.frames.Pop()
+	if r.done {
+		e.releaseFrame(g.frame)
+	}
+
+	if r.err != nil {
+		g.done = true
+		panic(r.err)
+	}
+	if r.stop {
+		g.done = true
+		panic(budgetStop{})
+	}
+	if r.panic != nil {
+		g.done = true
+		panic(r.panic)
+	}
+	if r.done {
+		g.done, g.result = true, r.value
+	}
+	return r.value, r.done
+}
+
+// 在 goroutine 里执行方法体
+func (g *generator) run() {
+	defer func() {
+		if r := recover(); r != nil {
+			switch r.(type) {
+			case genStop:
+			case budgetStop:
+				g.yielded <- genResult{done: true, stop: true}
+			default:
+				// 不能在生成器的 goroutine 里抛出, 否则会结束整个进程
+				g.yielded <- genResult{done: true, panic: r}
+			}
+		}
+	}()
+
+	exec := g.exec.fork(ast.NewParser(g.frame.Fn.Body, g.frame.Objs))
+	exec.returnable = true
+
+	var value interface{}
+	err := exec.protect(func() {
+		value = exec.Run()
+	})
+	exec.profileExit()
+	g.yielded <- genResult{value: value, done: true, err: err}
+}
+
+// yield x: 把值交给调用方, 等待下一次恢复
+func (e *Exec) yield(value interface{}) {
+	frame, _ := e.frames.Top().(*Frame)
+	if frame == nil || frame.gen == nil {
+		panic(ast.NewError(ast.SyntaxError, "yield 语句在不合法的位置"))
+	}
+
+	g := frame.gen
+	e.profile()
+	g.yielded <- genResult{value: value}
+	if _, ok := <-g.resume; !ok {
+		panic(genStop{})
+	}
+	e.profileResume()
+}
+
+// 遍历列表, 字符串, 生成器或者通道
+func (e *Exec) iterate(val interface{}) func() (interface{}, bool) {
+	switch val := val.(type) {
+	case *ast.ListValue:
+		i := 0
+		return func() (interface{}, bool) {
+			if i >= val.Len() {
+				return nil, true
+			}
+			i += 1
+			return val.Index(i - 1), false
+		}
+	case string:
+		chars := []rune(val)
+		i := 0
+		return func() (interface{}, bool) {
+			if i >= len(chars) {
+				return nil, true
+			}
+			i += 1
+			return string(chars[i-1]), false
+		}
+	case *ast.GeneratorValue:
+		// 遍历期间持有生成器, 避免被终结器提前结束
+		return func() (interface{}, bool) {
+			return val.Resume()
+		}
+	case *ast.ChannelValue:
+		// 遍历到通道关闭为止
+		return func() (interface{}, bool) {
+			val, ok, err := val.Recv(e.budget.ctx)
+			stopIf(err)
+			return val, !ok
+		}
+	}
+	panic(ast.NewError(ast.TypeError, "%s 类型不能遍历", ast.TypeString(ast.GetType(val))))
+}
+
+// for x in xs { ... }
+func (e *Exec) forIn(stmt *ast.ForStmt) interface{} {
+	next := e.iterate(e.expr(stmt.Iter))
+
+	objs := e.scope(e.Parser.Objects)
+	defer e.release(objs)
+	variable := &ast.Variable{Name: stmt.Name}
+	e.define(objs, variable)
+
+	for {
+		e.budget.step()
+		val, done := next()
+		if done {
+			break
+		}
+		variable.Store(val)
+		e.coverBranch(stmt.Pos(), BranchBody)
+
+		exec := e.fork(ast.NewParser(stmt.Body, objs))
+		exec.tail = e.tail
+		value := exec.Run()
+
+		// 如果在for内return，则提前结束外层的作用域
+		if value != nil {
+			return value
+		}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package rt
+
+import "my-lang/ast"
+
+// 内存分配预算中估算的大小 (字节), 字符串按长度计算
+const (
+	valueSize = 16 // 列表元素, 结构体字段 (interface{})
+	listSize  = 32 // 列表本身
+	scopeSize = 64 // 作用域 (对象表)
+	varSize   = 64 // 作用域里的变量
+)
+
+// 分配内存, 超过 Options.MaxAlloc 时抛出 MemoryError (可以被 try 捕获)
+// 值何时不再被引用无法知道, 所以按累计分配计算; 栈帧与作用域在执行结束时退还
+func (b *budget) alloc(size int64) {
+	max := b.options.MaxAlloc
+	if max <= 0 {
+		return
+	}
+	// 单次分配就超过预算时不计入, 避免溢出
+	if size > max || b.memory.Add(size) > max {
+		if size <= max {
+			b.memory.Add(-size)
+		}
+		panic(ast.NewError(ast.MemoryError, "超过内存分配预算 %d 字节", max))
+	}
+}
+
+// 退还执行结束的栈帧与作用域的内存
+func (b *budget) free(size int64) {
+	if b.options.MaxAlloc > 0 {
+		b.memory.Add(-size)
+	}
+}
+
+// n 个元素的列表的大小
+func listMemory(n int) int64 {
+	return listSize + int64(n)*valueSize
+}
+
+// 作用域 (栈帧或者语句块) 的大小: 对象表与其中的变量
+func scopeMemory(objs *ast.ObjectList) int64 {
+	size := int64(scopeSize)
+	for i := 0; i < objs.Len(); i++ {
+		if _, ok := objs.Get(i).(*ast.Variable); ok {
+			size += varSize
+		}
+	}
+	return size
+}
+
+// 创建语句块的作用域, 计入内存分配, 执行结束时调用 release 退还
+func (e *Exec) scope(parent *ast.ObjectList) *ast.ObjectList {
+	e.alloc(scopeSize)
+	return ast.NewObjectList(parent)
+}
+
+// 语句块执行结束 (包括出错时), 退还作用域与其中变量的内存
+func (e *Exec) release(objs *ast.ObjectList) {
+	e.budget.free(scopeMemory(objs))
+}
+
+// 栈帧执行结束 (包括出错时), 退还栈帧的内存, 每个栈帧只退还一次
+func (e *Exec) releaseFrame(frame *Frame) {
+	if frame.charged {
+		frame.charged = false
+		e.release(frame.Objs)
+	}
+}
+
+// 在作用域里定义变量, 计入内存分配
+func (e *Exec) define(objs *ast.ObjectList, v *ast.Variable) {
+	e.alloc(varSize)
+	objs.Add(v)
+}
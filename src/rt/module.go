@@ -0,0 +1,176 @@
+package rt
+
+import (
+	"my-lang/ast"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 模块搜索路径的环境变量
+const pathEnv = "MYLANG_PATH"
+
+// 源文件扩展名
+const fileExt = ".m"
+
+// ModuleLoader 模块缓存与循环导入检测, 解释器, 检查器与语法树共用
+// 怎样处理模块的源码 (执行, 检查或者解析) 由调用方决定
+type ModuleLoader struct {
+	cache   map[string]*ast.Module // 已加载的模块 (绝对路径 -> 模块)
+	loading []string               // 正在加载的模块链 (用于检测循环导入)
+}
+
+func NewModuleLoader() *ModuleLoader {
+	return &ModuleLoader{
+		cache: make(map[string]*ast.Module),
+	}
+}
+
+// Enter 记录入口文件, 导入入口文件本身也是循环导入
+func (l *ModuleLoader) Enter(path string) {
+	l.loading = append(l.loading, AbsPath(path))
+}
+
+// Load 加载模块 path (绝对路径, 由 FindModule 得到), 每个模块只加载一次
+// load 处理模块的源码并返回模块顶层的对象表; 循环导入或者无法读取时抛出 ImportError
+func (l *ModuleLoader) Load(path string, load func(path string, src []byte) *ast.ObjectList) *ast.Module {
+	if module, ok := l.cache[path]; ok {
+		return module
+	}
+
+	// 循环导入检测
+	for i, loading := range l.loading {
+		if loading == path {
+			var chain []string
+			for _, file := range append(l.loading[i:], path) {
+				chain = append(chain, filepath.Base(file))
+			}
+			panic(ast.NewError(ast.ImportError, "循环导入: %s", strings.Join(chain, " -> ")))
+		}
+	}
+
+	src, err := os.ReadFile(path)
+	if err != nil {
+		panic(ast.NewError(ast.ImportError, "无法读取模块 %s: %s", path, err))
+	}
+
+	// 出错时也要出栈
+	l.loading = append(l.loading, path)
+	defer func() {
+		l.loading = l.loading[:len(l.loading)-1]
+	}()
+
+	module := &ast.Module{
+		Name:    ModuleName(path),
+		Path:    path,
+		Objects: load(path, src),
+	}
+	l.cache[path] = module
+	return module
+}
+
+// SetFile 设置当前执行的源文件，用于解析相对路径的导入
+func (e *Exec) SetFile(path string) {
+	e.File = path
+	e.modules.Enter(path)
+}
+
+// AbsPath 绝对路径, 无法得到时返回原来的路径
+func AbsPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	return abs
+}
+
+// ModuleName 模块名: path/to/mod.m -> mod
+func ModuleName(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), fileExt)
+}
+
+// 查找模块文件
+func (e *Exec) findModule(path string) string {
+	return FindModule(e.File, path, e.options.Capabilities)
+}
+
+// FindModule 查找 file 导入的模块文件: 先从 file 所在目录找，有 env 能力时再从 MYLANG_PATH 里找
+func FindModule(file string, path string, caps Capabilities) string {
+	if !strings.HasSuffix(path, fileExt) {
+		path += fileExt
+	}
+
+	if filepath.IsAbs(path) {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		panic(ast.NewError(ast.ImportError, "找不到模块 %s", path))
+	}
+
+	dirs := []string{filepath.Dir(file)}
+	if caps.Has(CapEnv) {
+		dirs = append(dirs, filepath.SplitList(os.Getenv(pathEnv))...)
+	}
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		file := filepath.Join(dir, path)
+		if _, err := os.Stat(file); err == nil {
+			return AbsPath(file)
+		}
+	}
+
+	panic(ast.NewError(ast.ImportError, "找不到模块 %s (搜索路径: %s)", path, strings.Join(dirs, string(filepath.ListSeparator))))
+}
+
+// 加载并执行模块，每个模块只会执行一次
+func (e *Exec) loadModule(path string) *ast.Module {
+	return e.modules.Load(path, func(path string, src []byte) *ast.ObjectList {
+		toks, scanErr := scanTokens(path, src)
+		if scanErr != nil {
+			panic(scanErr)
+		}
+
+		// 模块拥有独立的全局对象表
+		objs := ast.NewObjectList(Builtins())
+		exec := e.fork(ast.NewParser(toks, objs))
+		exec.File, exec.returnable = path, false
+
+		// 模块顶层在调用栈中显示为 <模块名>
+		e.frames.Push(&Frame{name: "<" + ModuleName(path) + ">", Objs: objs})
+		exec.Run()
+		e.frames.Pop()
+		e.profile()
+		return objs
+	})
+}
+
+// 执行导入语句
+func (e *Exec) importModule(stmt *ast.ImportStmt) {
+
+	module := e.loadModule(e.findModule(stmt.Path))
+
+	// import mod [as m]
+	if stmt.Names == nil {
+		if stmt.Alias != "" {
+			e.Parser.Objects.Add(&ast.Module{
+				Name:    stmt.Alias,
+				Path:    module.Path,
+				Objects: module.Objects,
+			})
+		} else {
+			e.Parser.Objects.Add(module)
+		}
+		return
+	}
+
+	// from mod import a, b
+	for _, name := range stmt.Names {
+		obj := module.Objects.FindObject(name)
+		if obj == nil {
+			panic(ast.NewError(ast.ImportError, "模块 %s 没有对象: %s", module.Name, name))
+		}
+		e.Parser.Objects.Add(obj)
+	}
+}
@@ -0,0 +1,62 @@
+package rt
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// DefaultMaxDepth 默认的最大调用深度
+const DefaultMaxDepth = 10000
+
+// MaxDepthLimit 调用深度的上限
+// 栈帧保存在堆上, 但是普通调用仍然在 Go 的栈上递归执行 (只有尾调用复用栈帧),
+// 超过上限时 Go 的栈可能溢出 (无法捕获), 所以 MaxDepth 不能超过这个值
+const MaxDepthLimit = 20000
+
+// Options 解释器配置
+type Options struct {
+	MaxDepth int       // 最大调用深度, 超过时抛出 StackOverflowError (尾调用不计入), 为 0 时为 MaxDepthLimit, 不能超过 MaxDepthLimit
+	Stdout   io.Writer // print 的输出, 为 nil 时使用 os.Stdout
+
+	// 每条语句执行前调用, 为 nil 时不调用 (调试器使用)
+	Hook Hook
+
+	// 统计脚本方法与行的调用次数, 执行时间与内存分配, 为 nil 时不统计
+	Profiler *Profiler
+
+	// 记录执行过的语句, 分支与方法, 为 nil 时不记录
+	Coverage *Coverage
+
+	// 脚本可以使用的能力, 没有的能力在使用时抛出 PermissionError, 为 nil 时没有任何能力
+	Capabilities Capabilities
+
+	// 执行预算, 用尽时 Execute 停止执行并返回 ErrBudgetExceeded, 脚本内的 try 无法捕获
+	MaxSteps int64           // 最多执行的步数 (每条语句, 每次循环与每次调用算一步), 为 0 时不限制
+	Timeout  time.Duration   // 最长运行时间, 为 0 时不限制
+	Context  context.Context // 取消时停止执行, Execute 返回 ctx.Err()
+
+	// 内存分配预算 (字节), 超过时抛出 MemoryError, 为 0 时不限制
+	// 字符串, 列表与结构体按累计分配计算; 调用栈帧, 语句块的作用域与其中的变量在执行结束时退还
+	MaxAlloc int64
+}
+
+// Validate 检查配置, Execute 开始前也会检查
+func (o *Options) Validate() error {
+	if o.MaxDepth < 0 || o.MaxDepth > MaxDepthLimit {
+		return fmt.Errorf("最大调用深度 %d 不合法, 必须在 0 到 %d 之间", o.MaxDepth, MaxDepthLimit)
+	}
+	return nil
+}
+
+// DefaultOptions 默认配置
+func DefaultOptions() Options {
+	return Options{
+		MaxDepth: DefaultMaxDepth,
+		Stdout:   os.Stdout,
+
+		Capabilities: AllCapabilities(),
+	}
+}
@@ -0,0 +1,19 @@
+package rt
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// 解释器的输出, 同一个解释器的多个任务同时 print 时逐行写入
+type output struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (o *output) println(val interface{}) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	fmt.Fprintln(o.w, val)
+}
@@ -0,0 +1,233 @@
+package rt
+
+import (
+	"compress/gzip"
+	"io"
+	"sort"
+	"time"
+)
+
+// pprof 格式 (profile.proto) 的字段编号
+const (
+	profileSampleType        = 1
+	profileSample            = 2
+	profileMapping           = 3
+	profileLocation          = 4
+	profileFunction          = 5
+	profileStringTable       = 6
+	profileTimeNanos         = 9
+	profileDurationNanos     = 10
+	profilePeriodType        = 11
+	profilePeriod            = 12
+	profileDefaultSampleType = 14
+
+	valueTypeType = 1
+	valueTypeUnit = 2
+
+	sampleLocation = 1
+	sampleValue    = 2
+
+	mappingID             = 1
+	mappingFilename       = 5
+	mappingHasFunctions   = 7
+	mappingHasFilenames   = 8
+	mappingHasLineNumbers = 9
+
+	locationID      = 1
+	locationMapping = 2
+	locationLine    = 4
+	lineFunction    = 1
+	lineLine        = 2
+
+	functionID        = 1
+	functionName      = 2
+	functionFilename  = 4
+	functionStartLine = 5
+)
+
+// WriteTo 输出 gzip 压缩的 pprof 格式, 可以用 go tool pprof 查看
+// 每个样本有 4 个值: 调用次数 (calls), 时间 (time, 默认), 分配次数 (alloc_objects) 与分配的字节数 (alloc_space)
+// 栈帧的位置是脚本的 文件:行, 方法名是脚本的方法名 (类型方法为 类型.方法)
+func (p *Profiler) WriteTo(w io.Writer) (int64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	b := &protobuf{strings: map[string]int64{"": 0}, table: []string{""}}
+	valueTypes := [][2]string{
+		{"calls", "count"},
+		{"time", "nanoseconds"},
+		{"alloc_objects", "count"},
+		{"alloc_space", "bytes"},
+	}
+	for _, vt := range valueTypes {
+		b.message(profileSampleType, func() {
+			b.int64(valueTypeType, b.string(vt[0]))
+			b.int64(valueTypeUnit, b.string(vt[1]))
+		})
+	}
+
+	// 按调用栈排序, 输出稳定
+	samples := make([]*profSample, 0, len(p.samples))
+	for _, s := range p.samples {
+		samples = append(samples, s)
+	}
+	sort.Slice(samples, func(i, j int) bool {
+		a, b := samples[i].locations, samples[j].locations
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+	for _, s := range samples {
+		b.message(profileSample, func() {
+			b.uint64s(sampleLocation, s.locations)
+			b.int64s(sampleValue, []int64{s.calls, s.time, s.allocs, s.bytes})
+		})
+	}
+
+	// 所有的位置属于同一个映射, 方法名, 文件与行号都已经给出, pprof 不需要再查找符号
+	b.message(profileMapping, func() {
+		b.uint64(mappingID, 1)
+		b.int64(mappingFilename, b.string("my-lang"))
+		b.uint64(mappingHasFunctions, 1)
+		b.uint64(mappingHasFilenames, 1)
+		b.uint64(mappingHasLineNumbers, 1)
+	})
+
+	locations := make([]profLocation, len(p.locations))
+	for loc, id := range p.locations {
+		locations[id-1] = loc
+	}
+	for i, loc := range locations {
+		b.message(profileLocation, func() {
+			b.uint64(locationID, uint64(i+1))
+			b.uint64(locationMapping, 1)
+			b.message(locationLine, func() {
+				b.uint64(lineFunction, p.functions[loc.fn])
+				b.int64(lineLine, int64(loc.line))
+			})
+		})
+	}
+
+	functions := make([]profFunction, len(p.functions))
+	for fn, id := range p.functions {
+		functions[id-1] = fn
+	}
+	for i, fn := range functions {
+		b.message(profileFunction, func() {
+			b.uint64(functionID, uint64(i+1))
+			b.int64(functionName, b.string(fn.name))
+			b.int64(functionFilename, b.string(fn.file))
+			b.int64(functionStartLine, int64(fn.line))
+		})
+	}
+
+	b.int64(profileTimeNanos, p.start.UnixNano())
+	b.int64(profileDurationNanos, time.Since(p.start).Nanoseconds())
+	b.message(profilePeriodType, func() {
+		b.int64(valueTypeType, b.string("time"))
+		b.int64(valueTypeUnit, b.string("nanoseconds"))
+	})
+	b.int64(profilePeriod, 1)
+	b.int64(profileDefaultSampleType, b.string("time"))
+
+	// 字符串表最后输出, 此时所有的字符串都已经编号
+	for _, s := range b.table {
+		b.bytes(profileStringTable, []byte(s))
+	}
+
+	cw := &countWriter{w: w}
+	zw := gzip.NewWriter(cw)
+	if _, err := zw.Write(b.data); err != nil {
+		return cw.n, err
+	}
+	err := zw.Close()
+	return cw.n, err
+}
+
+// 统计写出的字节数
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countWriter) Write(data []byte) (int, error) {
+	n, err := w.w.Write(data)
+	w.n += int64(n)
+	return n, err
+}
+
+// protobuf 编码, 只支持 pprof 用到的类型
+type protobuf struct {
+	data    []byte
+	strings map[string]int64 // 字符串表: 字符串 -> 编号
+	table   []string
+}
+
+// 字符串在字符串表中的编号
+func (b *protobuf) string(s string) int64 {
+	if i, ok := b.strings[s]; ok {
+		return i
+	}
+	i := int64(len(b.table))
+	b.strings[s] = i
+	b.table = append(b.table, s)
+	return i
+}
+
+func (b *protobuf) varint(x uint64) {
+	for x >= 0x80 {
+		b.data = append(b.data, byte(x)|0x80)
+		x >>= 7
+	}
+	b.data = append(b.data, byte(x))
+}
+
+// 字段编号与类型: 0 为 varint, 2 为长度前缀
+func (b *protobuf) key(tag int, wireType int) {
+	b.varint(uint64(tag)<<3 | uint64(wireType))
+}
+
+func (b *protobuf) uint64(tag int, x uint64) {
+	b.key(tag, 0)
+	b.varint(x)
+}
+
+func (b *protobuf) int64(tag int, x int64) {
+	b.uint64(tag, uint64(x))
+}
+
+func (b *protobuf) bytes(tag int, data []byte) {
+	b.key(tag, 2)
+	b.varint(uint64(len(data)))
+	b.data = append(b.data, data...)
+}
+
+// 打包的重复字段
+func (b *protobuf) uint64s(tag int, xs []uint64) {
+	b.message(tag, func() {
+		for _, x := range xs {
+			b.varint(x)
+		}
+	})
+}
+
+func (b *protobuf) int64s(tag int, xs []int64) {
+	b.message(tag, func() {
+		for _, x := range xs {
+			b.varint(uint64(x))
+		}
+	})
+}
+
+// 嵌套的消息: 先编码内容, 再加上长度前缀
+func (b *protobuf) message(tag int, fn func()) {
+	outer := b.data
+	b.data = nil
+	fn()
+	inner := b.data
+	b.data = outer
+	b.bytes(tag, inner)
+}
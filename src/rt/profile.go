@@ -0,0 +1,201 @@
+package rt
+
+import (
+	"my-lang/data"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Profiler 按脚本的方法与行统计调用次数, 执行时间与内存分配, 用 WriteTo 输出 pprof 格式
+// 时间为墙钟时间: 两次事件 (语句开始, 调用与返回) 之间的时间计入前一次事件时的调用栈
+// 每个任务与生成器有自己的调用栈, 分别计时; 等待通道, 任务与生成器的时间计入等待的语句
+// 内存分配与内存分配预算的估算一致, 计入分配时的调用栈
+type Profiler struct {
+	mu    sync.Mutex
+	start time.Time
+
+	threads   map[*data.Stack]*profThread
+	samples   map[string]*profSample  // 调用栈 -> 统计
+	functions map[profFunction]uint64 // 方法 -> id (从 1 开始)
+	locations map[profLocation]uint64 // 方法中的行 -> id (从 1 开始)
+}
+
+// 一个调用栈 (主程序, 任务或者生成器) 的计时状态
+type profThread struct {
+	last   time.Time
+	sample *profSample // 上一次事件时的调用栈
+}
+
+// 方法, 最外层与模块顶层的行号为 0
+type profFunction struct {
+	name string
+	file string
+	line int
+}
+
+// 方法中的一行
+type profLocation struct {
+	fn   profFunction
+	line int
+}
+
+// 一个调用栈的统计
+type profSample struct {
+	locations []uint64 // 由内到外
+	calls     int64
+	time      int64 // 纳秒
+	allocs    int64
+	bytes     int64
+}
+
+// NewProfiler 新建 Profiler, 从现在开始计时
+func NewProfiler() *Profiler {
+	return &Profiler{
+		start:     time.Now(),
+		threads:   make(map[*data.Stack]*profThread),
+		samples:   make(map[string]*profSample),
+		functions: make(map[profFunction]uint64),
+		locations: make(map[profLocation]uint64),
+	}
+}
+
+// 记录一次事件: 上一次事件以来的时间计入上一次的调用栈, 然后记下当前的调用栈
+// call 不为 nil 时是调用 call 的方法 (栈帧还未入栈), 调用次数计入调用后的调用栈
+func (p *Profiler) event(e *Exec, call *Frame) {
+	now := time.Now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	t := p.thread(e)
+	if t.sample != nil {
+		t.sample.time += now.Sub(t.last).Nanoseconds()
+	}
+	t.last = now
+	t.sample = p.sample(e, call)
+	if call != nil {
+		t.sample.calls += 1
+	}
+}
+
+// 挂起 (生成器 yield) 之后恢复执行, 挂起期间不计时
+func (p *Profiler) resume(e *Exec) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.thread(e).last = time.Now()
+}
+
+// 调用栈结束 (任务或者生成器结束, 主程序返回), 计入最后一段时间
+func (p *Profiler) exit(e *Exec) {
+	p.event(e, nil)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.threads, e.frames)
+}
+
+// 分配内存, 计入当前的调用栈
+func (p *Profiler) alloc(e *Exec, size int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	t := p.thread(e)
+	if t.sample == nil {
+		t.last, t.sample = time.Now(), p.sample(e, nil)
+	}
+	t.sample.allocs += 1
+	t.sample.bytes += size
+}
+
+func (p *Profiler) thread(e *Exec) *profThread {
+	t := p.threads[e.frames]
+	if t == nil {
+		t = &profThread{}
+		p.threads[e.frames] = t
+	}
+	return t
+}
+
+// 当前调用栈 (加上 call) 的统计
+func (p *Profiler) sample(e *Exec, call *Frame) *profSample {
+	frames := e.Frames()
+	if call != nil {
+		frames = append(frames, call)
+	}
+
+	locations := make([]uint64, len(frames))
+	var key strings.Builder
+	for i, frame := range frames {
+		id := p.location(frame)
+		locations[len(frames)-1-i] = id
+		key.WriteString(strconv.FormatUint(id, 10))
+		key.WriteString(" ")
+	}
+
+	s := p.samples[key.String()]
+	if s == nil {
+		s = &profSample{locations: locations}
+		p.samples[key.String()] = s
+	}
+	return s
+}
+
+// 栈帧正在执行的行, 刚调用还没有执行语句时为方法定义的行
+func (p *Profiler) location(frame *Frame) uint64 {
+	pos := frame.Pos
+	fn := profFunction{name: frame.Name(), file: pos.File}
+	if frame.Fn != nil {
+		start := frame.Fn.Pos()
+		fn.file, fn.line = start.File, start.Line
+		if !pos.IsValid() {
+			pos = start
+		}
+	}
+	if _, ok := p.functions[fn]; !ok {
+		p.functions[fn] = uint64(len(p.functions) + 1)
+	}
+
+	loc := profLocation{fn: fn, line: pos.Line}
+	id, ok := p.locations[loc]
+	if !ok {
+		id = uint64(len(p.locations) + 1)
+		p.locations[loc] = id
+	}
+	return id
+}
+
+// 记录语句开始, 调用返回等事件
+func (e *Exec) profile() {
+	if p := e.options.Profiler; p != nil {
+		p.event(e, nil)
+	}
+}
+
+// 记录方法调用, 在栈帧入栈之前调用
+func (e *Exec) profileCall(frame *Frame) {
+	if p := e.options.Profiler; p != nil {
+		p.event(e, frame)
+	}
+}
+
+// 生成器 yield 之后恢复执行
+func (e *Exec) profileResume() {
+	if p := e.options.Profiler; p != nil {
+		p.resume(e)
+	}
+}
+
+// 调用栈结束
+func (e *Exec) profileExit() {
+	if p := e.options.Profiler; p != nil {
+		p.exit(e)
+	}
+}
+
+// 分配内存: 计入内存分配预算与 Profiler
+func (e *Exec) alloc(size int64) {
+	e.budget.alloc(size)
+	if p := e.options.Profiler; p != nil {
+		p.alloc(e, size)
+	}
+}
@@ -0,0 +1,132 @@
+package rt
+
+import (
+	"my-lang/ast"
+	"my-lang/data"
+	"my-lang/token"
+	"reflect"
+)
+
+// spawn f(x): 参数在当前任务里绑定, 方法体在新的 goroutine 里执行
+// 每个任务有自己的调用栈, 对象表, 变量, 列表与结构体都带锁, 可以在任务之间共享
+// 单次读写是原子的, 例如 xs[0] = xs[0] + 1 这样的读-改-写仍然需要用通道协调
+func (e *Exec) spawn(expr *ast.SpawnExpr) *ast.TaskValue {
+	var frame *Frame
+	switch call := expr.Call.(type) {
+	case *ast.CallFnExpr:
+		frame = e.fnFrame(call.Fn, call.Params)
+	case *ast.MethodCallExpr:
+		frame = e.methodFrame(e.expr(call.X), call.Name, call.Params)
+	}
+
+	task := ast.NewTaskValue(frame.Fn)
+
+	// 任务有自己的调用栈, 最外层的位置是 spawn 的位置
+	// 任务内不能 import, 所以也不共享模块状态
+	exec := e.fork(nil)
+	exec.root = &Frame{name: "<task>", Pos: expr.Pos()}
+	exec.frames = data.NewStack()
+	exec.modules = NewModuleLoader()
+
+	go func() {
+		// 预算用尽时结束任务, 等待的一方随后也会停止
+		// 其它的 panic 不能在任务的 goroutine 里抛出 (会结束整个进程), 作为任务的错误交给 wait
+		defer func() {
+			if r := recover(); r != nil {
+				if _, ok := r.(budgetStop); ok {
+					task.Finish(nil, nil)
+					return
+				}
+				task.Finish(nil, internalError(r))
+			}
+		}()
+
+		var value interface{}
+		err := exec.protect(func() {
+			value = exec.invoke(frame)
+		})
+		exec.profileExit()
+		task.Finish(value, err)
+	}()
+
+	return task
+}
+
+// 执行 select 语句, 在第一个可以进行的通道操作上执行对应的分支
+func (e *Exec) selectCase(stmt *ast.SelectStmt) interface{} {
+	cases := make([]reflect.SelectCase, len(stmt.Cases))
+	for i, selectCase := range stmt.Cases {
+		params := selectCase.Call.Params
+		switch selectCase.Call.Builtin.Name {
+		case "recv":
+			if len(params) != 1 {
+				panic(ast.NewError(ast.TypeError, "recv 需要 1 个参数, 实际提供 %d 个", len(params)))
+			}
+			cases[i] = reflect.SelectCase{
+				Dir:  reflect.SelectRecv,
+				Chan: reflect.ValueOf(channelArg("recv", e.expr(params[0])).Chan()),
+			}
+		case "send":
+			if len(params) != 2 {
+				panic(ast.NewError(ast.TypeError, "send 需要 2 个参数, 实际提供 %d 个", len(params)))
+			}
+			ch := channelArg("send", e.expr(params[0]))
+			cases[i] = reflect.SelectCase{
+				Dir:  reflect.SelectSend,
+				Chan: reflect.ValueOf(ch.Chan()),
+				Send: reflect.ValueOf(ch.Check(e.expr(params[1]))),
+			}
+		}
+	}
+	if stmt.Default != nil {
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectDefault})
+	}
+
+	// 预算用尽时结束等待
+	cases = append(cases, reflect.SelectCase{
+		Dir:  reflect.SelectRecv,
+		Chan: reflect.ValueOf(e.budget.ctx.Done()),
+	})
+
+	chosen, value, ok := e.doSelect(cases)
+	if chosen == len(cases)-1 {
+		panic(budgetStop{})
+	}
+
+	// else { ... }
+	objs := e.scope(e.Parser.Objects)
+	if chosen == len(stmt.Cases) {
+		return e.runCase(stmt.Default, objs)
+	}
+
+	// v = recv(c) { ... }, 与 recv 一样, 通道已经关闭且没有数据时抛出 ChannelError
+	selectCase := stmt.Cases[chosen]
+	if selectCase.Call.Builtin.Name == "recv" && !ok {
+		panic(ast.NewError(ast.ChannelError, "从已关闭的通道接收数据"))
+	}
+	if selectCase.Name != "" {
+		e.define(objs, &ast.Variable{
+			Name:  selectCase.Name,
+			Value: value.Interface(),
+		})
+	}
+	return e.runCase(selectCase.Body, objs)
+}
+
+// 执行 select, 向已关闭的通道发送数据时抛出错误
+func (e *Exec) doSelect(cases []reflect.SelectCase) (chosen int, value reflect.Value, ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			panic(ast.NewError(ast.ChannelError, "向已关闭的通道发送数据"))
+		}
+	}()
+	return reflect.Select(cases)
+}
+
+// 执行 select 的分支
+func (e *Exec) runCase(body []token.Token, objs *ast.ObjectList) interface{} {
+	defer e.release(objs)
+	exec := e.fork(ast.NewParser(body, objs))
+	exec.tail = e.tail
+	return exec.Run()
+}
@@ -0,0 +1,213 @@
+package syntax
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// 输出格式
+const (
+	Text  = "text"  // 缩进的树, 方便阅读
+	JSON  = "json"  // {"kind": "AssignStmt", "line": 1, "col": 1, "name": "a", ...}
+	SExpr = "sexpr" // (AssignStmt (pos 1 1) (name "a") ...)
+)
+
+// Encode 按格式输出节点 (*Node) 或者节点列表 ([]*Node)
+// 没有的语法块 (例如没有 else 的 if) 输出为 null, 与空的语法块区分
+func Encode(w io.Writer, format string, v interface{}) error {
+	bw := bufio.NewWriter(w)
+	e := &encoder{w: bw}
+	switch format {
+	case Text:
+		e.text(v, 0)
+	case JSON:
+		var buf bytes.Buffer
+		e.w = &buf
+		e.json(v)
+		var out bytes.Buffer
+		if err := json.Indent(&out, buf.Bytes(), "", "  "); err != nil {
+			return err
+		}
+		out.WriteString("\n")
+		bw.Write(out.Bytes())
+	case SExpr:
+		e.sexpr(v, 0)
+		e.printf("\n")
+	default:
+		return fmt.Errorf("未知的输出格式 %s, 可以是 text, json, sexpr", format)
+	}
+	return bw.Flush()
+}
+
+type encoder struct {
+	w io.Writer
+}
+
+func (e *encoder) printf(format string, args ...interface{}) {
+	fmt.Fprintf(e.w, format, args...)
+}
+
+func indent(level int) string {
+	return strings.Repeat("  ", level)
+}
+
+// 标量字段的值
+func scalar(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return strconv.Quote(v), true
+	case int:
+		return strconv.Itoa(v), true
+	case bool:
+		return strconv.FormatBool(v), true
+	case []string:
+		quoted := make([]string, len(v))
+		for i, s := range v {
+			quoted[i] = strconv.Quote(s)
+		}
+		return "[" + strings.Join(quoted, ", ") + "]", true
+	}
+	return "", false
+}
+
+// AssignStmt 1:1 name="a" type=""
+//
+//	value:
+//	  LitExpr 1:5 type="int" lit="1"
+func (e *encoder) text(v interface{}, level int) {
+	switch v := v.(type) {
+	case []*Node:
+		for _, node := range v {
+			e.text(node, level)
+		}
+		return
+	case *Node:
+		if v == nil {
+			e.printf("%snull\n", indent(level))
+			return
+		}
+		e.printf("%s%s %d:%d", indent(level), v.Kind, v.Pos.Line, v.Pos.Col)
+		for _, f := range v.Fields {
+			if s, ok := scalar(f.Value); ok {
+				e.printf(" %s=%s", f.Name, s)
+			}
+		}
+		e.printf("\n")
+		for _, f := range v.Fields {
+			switch value := f.Value.(type) {
+			case *Node:
+				e.printf("%s%s:\n", indent(level+1), f.Name)
+				e.text(value, level+2)
+			case []*Node:
+				switch {
+				case value == nil:
+					e.printf("%s%s: null\n", indent(level+1), f.Name)
+					continue
+				case len(value) == 0:
+					e.printf("%s%s: []\n", indent(level+1), f.Name)
+					continue
+				}
+				e.printf("%s%s:\n", indent(level+1), f.Name)
+				e.text(value, level+2)
+			}
+		}
+	}
+}
+
+func (e *encoder) json(v interface{}) {
+	switch v := v.(type) {
+	case []*Node:
+		if v == nil {
+			e.printf("null")
+			return
+		}
+		e.printf("[")
+		for i, node := range v {
+			if i > 0 {
+				e.printf(",")
+			}
+			e.json(node)
+		}
+		e.printf("]")
+	case *Node:
+		if v == nil {
+			e.printf("null")
+			return
+		}
+		e.printf(`{"kind":%s,"line":%d,"col":%d`, quoteJSON(v.Kind), v.Pos.Line, v.Pos.Col)
+		for _, f := range v.Fields {
+			e.printf(",%s:", quoteJSON(f.Name))
+			switch value := f.Value.(type) {
+			case *Node, []*Node:
+				e.json(value)
+			default:
+				data, _ := json.Marshal(value)
+				e.w.Write(data)
+			}
+		}
+		e.printf("}")
+	}
+}
+
+func quoteJSON(s string) string {
+	data, _ := json.Marshal(s)
+	return string(data)
+}
+
+// (AssignStmt (pos 1 1) (name "a") (type "")
+//
+//	(value
+//	  (LitExpr (pos 1 5) (type "int") (lit "1"))))
+func (e *encoder) sexpr(v interface{}, level int) {
+	switch v := v.(type) {
+	case []*Node:
+		e.printf("(")
+		for i, node := range v {
+			if i > 0 {
+				e.printf("\n%s ", indent(level))
+			}
+			e.sexpr(node, level)
+		}
+		e.printf(")")
+	case *Node:
+		if v == nil {
+			e.printf("nil")
+			return
+		}
+		e.printf("(%s (pos %d %d)", v.Kind, v.Pos.Line, v.Pos.Col)
+		for _, f := range v.Fields {
+			switch value := f.Value.(type) {
+			case *Node:
+				e.printf("\n%s(%s ", indent(level+1), f.Name)
+				e.sexpr(value, level+2)
+				e.printf(")")
+			case []*Node:
+				if value == nil {
+					e.printf(" (%s nil)", f.Name)
+					continue
+				}
+				e.printf("\n%s(%s", indent(level+1), f.Name)
+				for _, node := range value {
+					e.printf("\n%s", indent(level+2))
+					e.sexpr(node, level+2)
+				}
+				e.printf(")")
+			case []string:
+				e.printf(" (%s", f.Name)
+				for _, s := range value {
+					e.printf(" %s", strconv.Quote(s))
+				}
+				e.printf(")")
+			default:
+				s, _ := scalar(value)
+				e.printf(" (%s %s)", f.Name, s)
+			}
+		}
+		e.printf(")")
+	}
+}
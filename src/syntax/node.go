@@ -0,0 +1,76 @@
+package syntax
+
+import "my-lang/token"
+
+// 完整的语法树
+// 解释器与检查器按需解析方法体与语句块, 这里一次递归解析整个文件, 供外部工具使用
+// 每种节点的字段固定且按固定的顺序输出, 没有的值输出为空字符串或者 null
+
+type (
+	// Node 语法树节点
+	Node struct {
+		Kind   string // 节点类型: AssignStmt, BinaryExpr, ... (token 列表中为 token 类型)
+		Pos    token.Pos
+		Fields []Field
+	}
+
+	// Field 节点的字段
+	// Value 为 string, int, bool, *Node (可以为 nil), []*Node 或者 []string
+	Field struct {
+		Name  string
+		Value interface{}
+	}
+)
+
+func newNode(kind string, pos token.Pos, fields ...Field) *Node {
+	return &Node{Kind: kind, Pos: pos, Fields: fields}
+}
+
+func field(name string, value interface{}) Field {
+	return Field{Name: name, Value: value}
+}
+
+// Get 字段的值, 没有则为 nil
+func (n *Node) Get(name string) interface{} {
+	for _, f := range n.Fields {
+		if f.Name == name {
+			return f.Value
+		}
+	}
+	return nil
+}
+
+// 字段的各种类型的值
+func (n *Node) str(name string) string {
+	s, _ := n.Get(name).(string)
+	return s
+}
+
+func (n *Node) node(name string) *Node {
+	node, _ := n.Get(name).(*Node)
+	return node
+}
+
+func (n *Node) nodes(name string) []*Node {
+	nodes, _ := n.Get(name).([]*Node)
+	return nodes
+}
+
+func (n *Node) strs(name string) []string {
+	strs, _ := n.Get(name).([]string)
+	return strs
+}
+
+func (n *Node) flag(name string) bool {
+	b, _ := n.Get(name).(bool)
+	return b
+}
+
+// Tokens token 列表, 每个 token 是一个没有子节点的节点
+func Tokens(toks []token.Token) []*Node {
+	nodes := make([]*Node, 0, len(toks))
+	for _, tok := range toks {
+		nodes = append(nodes, newNode(tok.Type.Name(), tok.Pos, field("lit", tok.Lit)))
+	}
+	return nodes
+}
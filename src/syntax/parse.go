@@ -0,0 +1,423 @@
+package syntax
+
+import (
+	"my-lang/ast"
+	"my-lang/cst"
+	"my-lang/rt"
+	"my-lang/token"
+	"os"
+	"sort"
+)
+
+// ParseFile 解析源文件的完整语法树, 导入的模块只解析出对象, 不放进树里
+// 语句有错误时 (例如找不到名字) 从这条语句到语法块结尾成为一个 BadStmt, 其它语法块继续解析,
+// 这时同时返回语法树与第一个错误 (*ast.Error); 有词法错误时没有语法树
+func ParseFile(path string) (*Node, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseSource(path, src)
+}
+
+// ParseSource 解析内存中的源码, path 用于记录位置与查找模块
+func ParseSource(path string, src []byte) (file *Node, err error) {
+	b := &builder{modules: rt.NewModuleLoader()}
+	b.modules.Enter(path)
+	defer func() {
+		if r := recover(); r != nil {
+			scriptErr, ok := r.(*ast.Error)
+			if !ok {
+				panic(r)
+			}
+			file, err = nil, scriptErr
+		}
+	}()
+
+	body, _ := b.file(path, src)
+	file = newNode("File", token.Pos{File: path}, field("path", path), field("body", body))
+	if len(b.errors) > 0 {
+		return file, b.errors[0]
+	}
+	return file, nil
+}
+
+// 递归解析语句, 对象表的维护与检查器一致
+type builder struct {
+	modules *rt.ModuleLoader // 已解析的模块, 与解释器一样检测循环导入
+	errors  []*ast.Error     // BadStmt 的错误
+}
+
+// 解析源码, 返回顶层的语句与对象表
+func (b *builder) file(path string, src []byte) ([]*Node, *ast.ObjectList) {
+	tree, errs := cst.Parse(path, src)
+	if len(errs) > 0 {
+		err := ast.NewError(ast.SyntaxError, "%s", errs[0].Msg)
+		err.Pos = errs[0].Pos
+		panic(err)
+	}
+	objs := ast.NewObjectList(rt.Builtins())
+	return b.block(tree.Tokens(), objs), objs
+}
+
+// 语法块内的语句, 方法与类型的定义按出现的顺序作为语句
+func (b *builder) block(toks []token.Token, objs *ast.ObjectList) []*Node {
+	nodes := make([]*Node, 0)
+	p := ast.NewParser(toks, objs)
+	for !p.IsEnd() {
+		start := p.Offset
+		stmts, err := b.next(p, objs)
+		if err != nil {
+			nodes = append(nodes, b.badStmt(err, p.Tokens[start:]))
+			break
+		}
+		nodes = append(nodes, stmts...)
+	}
+	return nodes
+}
+
+// BadStmt: 出错的语句直到语法块结尾的源码
+func (b *builder) badStmt(err *ast.Error, toks []token.Token) *Node {
+	for len(toks) > 0 && toks[0].Type == token.LINEBREAK {
+		toks = toks[1:]
+	}
+	for len(toks) > 0 && (toks[len(toks)-1].Type == token.LINEBREAK || toks[len(toks)-1].Type == token.EOF) {
+		toks = toks[:len(toks)-1]
+	}
+	var pos token.Pos
+	if len(toks) > 0 {
+		pos = toks[0].Pos
+	}
+	if !err.Pos.IsValid() {
+		err.Pos = pos
+	}
+	b.errors = append(b.errors, err)
+	return newNode("BadStmt", pos, field("error", err.Error()), field("source", token.Join(toks)))
+}
+
+// 解析下一条语句 (定义可能有多个节点, 空行没有节点)
+func (b *builder) next(p *ast.Parser, objs *ast.ObjectList) (nodes []*Node, err *ast.Error) {
+	defer func() {
+		if r := recover(); r != nil {
+			scriptErr, ok := r.(*ast.Error)
+			if !ok {
+				panic(r)
+			}
+			err = scriptErr
+		}
+	}()
+
+	length := objs.Len()
+
+	// impl [Point] { ... }
+	var record *ast.Record
+	methods := make(map[*ast.Function]bool)
+	if p.Token().Type == token.IMPL && p.Offset+1 < len(p.Tokens) {
+		if record, _ = objs.FindObject(p.Tokens[p.Offset+1].Lit).(*ast.Record); record != nil {
+			for _, fn := range record.Methods {
+				methods[fn] = true
+			}
+		}
+	}
+	pos := p.Token().Pos
+
+	stmt := p.ParseStmt()
+	if stmt != nil {
+		return []*Node{b.stmt(stmt, objs)}, nil
+	}
+
+	for i := length; i < objs.Len(); i++ {
+		switch obj := objs.Get(i).(type) {
+		case *ast.Function:
+			nodes = append(nodes, b.fn(obj))
+		case *ast.Record:
+			nodes = append(nodes, typeDef(obj))
+		}
+	}
+
+	if record != nil {
+		var fns []*ast.Function
+		for _, fn := range record.Methods {
+			if !methods[fn] {
+				fns = append(fns, fn)
+			}
+		}
+		sort.Slice(fns, func(i, j int) bool {
+			pi, pj := fns[i].Pos(), fns[j].Pos()
+			return pi.Line < pj.Line || (pi.Line == pj.Line && pi.Col < pj.Col)
+		})
+		defs := make([]*Node, 0, len(fns))
+		for _, fn := range fns {
+			defs = append(defs, b.fn(fn))
+		}
+		nodes = append(nodes, newNode("ImplDef", pos, field("type", record.Name), field("methods", defs)))
+	}
+	return nodes, nil
+}
+
+// 子语法块, names 是块内预先定义的变量 (例如 for x in 的 x)
+func (b *builder) child(toks []token.Token, objs *ast.ObjectList, names ...string) []*Node {
+	child := ast.NewObjectList(objs)
+	for _, name := range names {
+		if name != "" {
+			child.Add(&ast.Variable{Name: name})
+		}
+	}
+	return b.block(toks, child)
+}
+
+// 可能没有的语法块 (例如没有 else 的 if), 没有时为 nil
+func (b *builder) optional(toks []token.Token, objs *ast.ObjectList, names ...string) []*Node {
+	if toks == nil {
+		return nil
+	}
+	return b.child(toks, objs, names...)
+}
+
+// FnDef: f(a, b = 1, ...c): int = { ... }
+func (b *builder) fn(fn *ast.Function) *Node {
+	objs := ast.NewObjectList(fn.ParentObjs)
+	if fn.Owner != nil {
+		objs.Add(&ast.Variable{Name: "self"})
+		if fn.Owner.Parent != nil {
+			objs.Add(&ast.Variable{Name: "super"})
+		}
+	}
+
+	args := make([]*Node, 0, len(fn.Args))
+	for _, arg := range fn.Args {
+		objs.Add(&ast.Variable{Name: arg.Name})
+		args = append(args, newNode("Arg", fn.Pos(),
+			field("name", arg.Name),
+			field("type", arg.Type),
+			field("default", b.expr(arg.Default, fn.ParentObjs)),
+			field("rest", arg.Rest),
+		))
+	}
+
+	return newNode("FnDef", fn.Pos(),
+		field("name", fn.Name),
+		field("args", args),
+		field("result", fn.Result),
+		field("generator", fn.Generator),
+		field("body", b.block(fn.Body, objs)),
+	)
+}
+
+// TypeDef: type Point3: Point { z }, 只列出自己定义的字段
+func typeDef(record *ast.Record) *Node {
+	parent, fields := "", record.Fields
+	if record.Parent != nil {
+		parent, fields = record.Parent.Name, fields[len(record.Parent.Fields):]
+	}
+	return newNode("TypeDef", record.Pos(),
+		field("name", record.Name),
+		field("parent", parent),
+		field("fields", append([]string{}, fields...)),
+	)
+}
+
+func (b *builder) stmt(stmt ast.Stmt, objs *ast.ObjectList) *Node {
+	pos := stmt.Pos()
+	switch stmt := stmt.(type) {
+	case *ast.ExprStmt:
+		return newNode("ExprStmt", pos, field("expr", b.expr(stmt.Expr, objs)))
+	case *ast.AssignStmt:
+		node := newNode("AssignStmt", pos,
+			field("name", stmt.Name),
+			field("type", stmt.Type),
+			field("value", b.expr(stmt.Value, objs)),
+		)
+		// 与解释器一样, 找不到的名字定义为新的变量
+		if objs.FindObject(stmt.Name) == nil {
+			objs.Add(&ast.Variable{Name: stmt.Name})
+		}
+		return node
+	case *ast.FieldAssignStmt:
+		return newNode("FieldAssignStmt", pos,
+			field("x", b.expr(stmt.X, objs)),
+			field("name", stmt.Name),
+			field("value", b.expr(stmt.Value, objs)),
+		)
+	case *ast.IndexAssignStmt:
+		return newNode("IndexAssignStmt", pos,
+			field("x", b.expr(stmt.X, objs)),
+			field("index", b.expr(stmt.Index, objs)),
+			field("value", b.expr(stmt.Value, objs)),
+		)
+	case *ast.PrintStmt:
+		return newNode("PrintStmt", pos, field("expr", b.expr(stmt.Expr, objs)))
+	case *ast.ReturnStmt:
+		return newNode("ReturnStmt", pos, field("expr", b.expr(stmt.Expr, objs)))
+	case *ast.ThrowStmt:
+		return newNode("ThrowStmt", pos, field("expr", b.expr(stmt.Expr, objs)))
+	case *ast.YieldStmt:
+		return newNode("YieldStmt", pos, field("expr", b.expr(stmt.Expr, objs)))
+	case *ast.IfStmt:
+		return newNode("IfStmt", pos,
+			field("cond", b.expr(stmt.Cond, objs)),
+			field("then", b.child(stmt.TrueBody, objs)),
+			field("else", b.optional(stmt.FalseBody, objs)),
+		)
+	case *ast.ForStmt:
+		return newNode("ForStmt", pos,
+			field("cond", b.expr(stmt.Cond, objs)),
+			field("name", stmt.Name),
+			field("iter", b.expr(stmt.Iter, objs)),
+			field("body", b.child(stmt.Body, objs, stmt.Name)),
+		)
+	case *ast.TryStmt:
+		return newNode("TryStmt", pos,
+			field("body", b.child(stmt.Body, objs)),
+			field("catchName", stmt.CatchName),
+			field("catch", b.optional(stmt.CatchBody, objs, stmt.CatchName)),
+			field("finally", b.optional(stmt.FinallyBody, objs)),
+		)
+	case *ast.SelectStmt:
+		cases := make([]*Node, 0, len(stmt.Cases))
+		for _, c := range stmt.Cases {
+			cases = append(cases, newNode("SelectCase", c.Pos(),
+				field("name", c.Name),
+				field("call", b.expr(c.Call, objs)),
+				field("body", b.child(c.Body, objs, c.Name)),
+			))
+		}
+		return newNode("SelectStmt", pos,
+			field("cases", cases),
+			field("default", b.optional(stmt.Default, objs)),
+		)
+	case *ast.TestStmt:
+		return newNode("TestStmt", pos,
+			field("name", stmt.Name),
+			field("body", b.child(stmt.Body, objs)),
+		)
+	case *ast.ImportStmt:
+		b.importModule(stmt, objs)
+		return newNode("ImportStmt", pos,
+			field("path", stmt.Path),
+			field("alias", stmt.Alias),
+			field("names", append([]string{}, stmt.Names...)),
+		)
+	}
+	panic(ast.NewError(ast.SyntaxError, "未知的语句 %T", stmt))
+}
+
+// 导入模块的对象, 后面的语句才能引用它们
+func (b *builder) importModule(stmt *ast.ImportStmt, objs *ast.ObjectList) {
+	// 语法树只用于工具, 按宿主的配置查找模块
+	path := rt.FindModule(stmt.Pos().File, stmt.Path, rt.AllCapabilities())
+
+	module := b.modules.Load(path, func(path string, src []byte) *ast.ObjectList {
+		_, objs := b.file(path, src)
+		return objs
+	})
+
+	if stmt.Names == nil {
+		name := module.Name
+		if stmt.Alias != "" {
+			name = stmt.Alias
+		}
+		objs.Add(&ast.Module{Name: name, Path: module.Path, Objects: module.Objects})
+		return
+	}
+	for _, name := range stmt.Names {
+		obj := module.Objects.FindObject(name)
+		if obj == nil {
+			err := ast.NewError(ast.ImportError, "模块 %s 没有对象: %s", module.Name, name)
+			err.Pos = stmt.Pos()
+			panic(err)
+		}
+		objs.Add(obj)
+	}
+}
+
+func (b *builder) expr(expr ast.Expr, objs *ast.ObjectList) *Node {
+	if expr == nil {
+		return nil
+	}
+	pos := expr.Pos()
+	switch expr := expr.(type) {
+	case *ast.LitExpr:
+		return newNode("LitExpr", pos, field("type", ast.TypeString(expr.Type)), field("lit", expr.Lit))
+	case *ast.IdentityExpr:
+		return newNode("IdentityExpr", pos, field("module", expr.Module), field("name", objectName(expr.Object)))
+	case *ast.BinaryExpr:
+		return newNode("BinaryExpr", pos,
+			field("op", ast.OperatorString(expr.Op)),
+			field("left", b.expr(expr.Left, objs)),
+			field("right", b.expr(expr.Right, objs)),
+		)
+	case *ast.BlockExpr:
+		return newNode("BlockExpr", pos, field("body", b.child(expr.Toks, objs)))
+	case *ast.CallFnExpr:
+		return newNode("CallFnExpr", pos,
+			field("module", expr.Module),
+			field("name", expr.Fn.Name),
+			field("params", b.params(expr.Params, objs)),
+		)
+	case *ast.CallBuiltinExpr:
+		return newNode("CallBuiltinExpr", pos,
+			field("module", expr.Module),
+			field("name", expr.Builtin.Name),
+			field("params", b.exprs(expr.Params, objs)),
+		)
+	case *ast.NewRecordExpr:
+		return newNode("NewRecordExpr", pos,
+			field("module", expr.Module),
+			field("type", expr.Record.Name),
+			field("fields", b.exprs(expr.Fields, objs)),
+		)
+	case *ast.FieldExpr:
+		return newNode("FieldExpr", pos, field("x", b.expr(expr.X, objs)), field("name", expr.Name))
+	case *ast.MethodCallExpr:
+		return newNode("MethodCallExpr", pos,
+			field("x", b.expr(expr.X, objs)),
+			field("name", expr.Name),
+			field("params", b.params(expr.Params, objs)),
+		)
+	case *ast.ListExpr:
+		return newNode("ListExpr", pos, field("elements", b.exprs(expr.Elements, objs)))
+	case *ast.IndexExpr:
+		return newNode("IndexExpr", pos, field("x", b.expr(expr.X, objs)), field("index", b.expr(expr.Index, objs)))
+	case *ast.SpawnExpr:
+		return newNode("SpawnExpr", pos, field("call", b.expr(expr.Call, objs)))
+	}
+	panic(ast.NewError(ast.SyntaxError, "未知的表达式 %T", expr))
+}
+
+func (b *builder) exprs(exprs []ast.Expr, objs *ast.ObjectList) []*Node {
+	nodes := make([]*Node, 0, len(exprs))
+	for _, expr := range exprs {
+		nodes = append(nodes, b.expr(expr, objs))
+	}
+	return nodes
+}
+
+// 调用参数, 命名参数的 name 不为空
+func (b *builder) params(params []ast.Param, objs *ast.ObjectList) []*Node {
+	nodes := make([]*Node, 0, len(params))
+	for _, param := range params {
+		pos := param.Pos()
+		if !pos.IsValid() && param.Value != nil {
+			pos = param.Value.Pos()
+		}
+		nodes = append(nodes, newNode("Param", pos, field("name", param.Name), field("value", b.expr(param.Value, objs))))
+	}
+	return nodes
+}
+
+func objectName(obj ast.Object) string {
+	switch obj := obj.(type) {
+	case *ast.Variable:
+		return obj.Name
+	case *ast.Function:
+		return obj.Name
+	case *ast.Builtin:
+		return obj.Name
+	case *ast.Record:
+		return obj.Name
+	case *ast.Module:
+		return obj.Name
+	}
+	return ""
+}
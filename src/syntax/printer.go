@@ -0,0 +1,387 @@
+package syntax
+
+import (
+	"io"
+	"strings"
+	"unicode"
+)
+
+// Source 把语法树输出成源码
+// 语法树不保留注释与原有的换行, 输出按统一的格式: 每层缩进 4 个空格, 定义前后空一行
+// 输出的源码重新解析得到同样的语法树 (位置除外)
+func Source(node *Node) string {
+	p := &printer{}
+	p.node(node)
+	return p.sb.String()
+}
+
+// Print 把语法树输出成源码
+func Print(w io.Writer, node *Node) error {
+	_, err := io.WriteString(w, Source(node))
+	return err
+}
+
+type printer struct {
+	sb    strings.Builder
+	level int
+}
+
+func (p *printer) write(s ...string) {
+	for _, str := range s {
+		p.sb.WriteString(str)
+	}
+}
+
+func (p *printer) newline() {
+	p.write("\n", strings.Repeat("    ", p.level))
+}
+
+func (p *printer) node(node *Node) {
+	switch node.Kind {
+	case "File":
+		p.stmts(node.nodes("body"))
+		if len(node.nodes("body")) > 0 {
+			p.write("\n")
+		}
+	case "FnDef", "TypeDef", "ImplDef":
+		p.def(node)
+	default:
+		p.stmt(node)
+	}
+}
+
+// 语句序列, 定义前后空一行
+func (p *printer) stmts(stmts []*Node) {
+	for i, stmt := range stmts {
+		if i > 0 {
+			if isDef(stmt) || isDef(stmts[i-1]) {
+				p.write("\n")
+			}
+			p.newline()
+		}
+		p.node(stmt)
+	}
+}
+
+func isDef(node *Node) bool {
+	switch node.Kind {
+	case "FnDef", "TypeDef", "ImplDef":
+		return true
+	}
+	return false
+}
+
+// { ... }, 空的语法块为 {}
+func (p *printer) block(stmts []*Node) {
+	if len(stmts) == 0 {
+		p.write("{}")
+		return
+	}
+	p.write("{")
+	p.level += 1
+	p.newline()
+	p.stmts(stmts)
+	p.level -= 1
+	p.newline()
+	p.write("}")
+}
+
+func (p *printer) def(node *Node) {
+	switch node.Kind {
+	case "FnDef":
+		p.write(node.str("name"), "(")
+		for i, arg := range node.nodes("args") {
+			if i > 0 {
+				p.write(", ")
+			}
+			if arg.flag("rest") {
+				p.write("...")
+			}
+			p.write(arg.str("name"))
+			if typ := arg.str("type"); typ != "" {
+				p.write(": ", typ)
+			}
+			if def := arg.node("default"); def != nil {
+				p.write(" = ")
+				p.expr(def)
+			}
+		}
+		p.write(")")
+		if result := node.str("result"); result != "" {
+			p.write(": ", result)
+		}
+		p.write(" = ")
+
+		// 只有一条 return 语句的方法写成一行: f(x) = x * 2
+		body := node.nodes("body")
+		if len(body) == 1 && body[0].Kind == "ReturnStmt" {
+			if expr := body[0].node("expr"); expr != nil && expr.Kind != "BlockExpr" {
+				p.expr(expr)
+				return
+			}
+		}
+		p.block(body)
+	case "TypeDef":
+		p.write("type ", node.str("name"))
+		if parent := node.str("parent"); parent != "" {
+			p.write(" : ", parent)
+		}
+		if fields := node.strs("fields"); len(fields) > 0 {
+			p.write(" { ", strings.Join(fields, ", "), " }")
+		} else {
+			p.write(" {}")
+		}
+	case "ImplDef":
+		p.write("impl ", node.str("type"), " ")
+		p.block(node.nodes("methods"))
+	}
+}
+
+func (p *printer) stmt(node *Node) {
+	switch node.Kind {
+	case "ExprStmt":
+		p.expr(node.node("expr"))
+	case "AssignStmt":
+		p.write(node.str("name"))
+		if typ := node.str("type"); typ != "" {
+			p.write(": ", typ)
+		}
+		p.write(" = ")
+		p.expr(node.node("value"))
+	case "FieldAssignStmt":
+		p.operand(node.node("x"))
+		p.write(".", node.str("name"), " = ")
+		p.expr(node.node("value"))
+	case "IndexAssignStmt":
+		p.operand(node.node("x"))
+		p.write("[")
+		p.expr(node.node("index"))
+		p.write("] = ")
+		p.expr(node.node("value"))
+	case "PrintStmt", "ReturnStmt", "ThrowStmt", "YieldStmt":
+		p.write(strings.ToLower(strings.TrimSuffix(node.Kind, "Stmt")))
+		if expr := node.node("expr"); expr != nil {
+			p.write(" ")
+			p.expr(expr)
+		}
+	case "IfStmt":
+		p.write("if ")
+		p.expr(node.node("cond"))
+		p.write(" ")
+		p.block(node.nodes("then"))
+		if body := node.nodes("else"); body != nil {
+			p.write(" else ")
+			p.block(body)
+		}
+	case "ForStmt":
+		p.write("for ")
+		if name := node.str("name"); name != "" {
+			p.write(name, " in ")
+			p.expr(node.node("iter"))
+		} else {
+			p.expr(node.node("cond"))
+		}
+		p.write(" ")
+		p.block(node.nodes("body"))
+	case "TryStmt":
+		p.write("try ")
+		p.block(node.nodes("body"))
+		if body := node.nodes("catch"); body != nil {
+			p.write(" catch ")
+			if name := node.str("catchName"); name != "" {
+				p.write(name, " ")
+			}
+			p.block(body)
+		}
+		if body := node.nodes("finally"); body != nil {
+			p.write(" finally ")
+			p.block(body)
+		}
+	case "SelectStmt":
+		p.write("select {")
+		p.level += 1
+		for _, c := range node.nodes("cases") {
+			p.newline()
+			if name := c.str("name"); name != "" {
+				p.write(name, " = ")
+			}
+			p.expr(c.node("call"))
+			p.write(" ")
+			p.block(c.nodes("body"))
+		}
+		if body := node.nodes("default"); body != nil {
+			p.newline()
+			p.write("else ")
+			p.block(body)
+		}
+		p.level -= 1
+		p.newline()
+		p.write("}")
+	case "TestStmt":
+		p.write("test ", quote(node.str("name")), " ")
+		p.block(node.nodes("body"))
+	case "BadStmt":
+		// 无法解析的源码原样输出
+		for i, line := range strings.Split(node.str("source"), "\n") {
+			if i > 0 {
+				p.newline()
+			}
+			p.write(strings.TrimSpace(line))
+		}
+	case "ImportStmt":
+		path := modulePath(node.str("path"))
+		if names := node.strs("names"); len(names) > 0 {
+			p.write("from ", path, " import ", strings.Join(names, ", "))
+			return
+		}
+		p.write("import ", path)
+		if alias := node.str("alias"); alias != "" {
+			p.write(" as ", alias)
+		}
+	}
+}
+
+// 模块名直接输出, 路径加上引号
+func modulePath(path string) string {
+	for i, r := range path {
+		if !(unicode.IsLetter(r) || r == '_' || (i > 0 && unicode.IsDigit(r))) {
+			return quote(path)
+		}
+	}
+	if path == "" {
+		return quote(path)
+	}
+	return path
+}
+
+// 字符串字面量不支持转义, 含有单引号时使用双引号
+func quote(s string) string {
+	if strings.Contains(s, "'") && !strings.Contains(s, `"`) {
+		return `"` + s + `"`
+	}
+	return "'" + s + "'"
+}
+
+// 运算符优先级, 与解析器一致
+func priority(op string) int {
+	switch op {
+	case "==", "!=", ">", ">=", "<", "<=":
+		return 2
+	case "+", "-":
+		return 3
+	case "*", "/", "%":
+		return 4
+	}
+	return 5
+}
+
+func exprPriority(node *Node) int {
+	if node != nil && node.Kind == "BinaryExpr" {
+		return priority(node.str("op"))
+	}
+	return 5
+}
+
+// 后缀 (.name, [i]) 前面的表达式, 二元表达式需要括号
+func (p *printer) operand(node *Node) {
+	if exprPriority(node) < 5 {
+		p.write("(")
+		p.expr(node)
+		p.write(")")
+		return
+	}
+	p.expr(node)
+}
+
+func (p *printer) expr(node *Node) {
+	if node == nil {
+		return
+	}
+	switch node.Kind {
+	case "LitExpr":
+		if node.str("type") == "string" {
+			p.write(quote(node.str("lit")))
+		} else {
+			p.write(node.str("lit"))
+		}
+	case "IdentityExpr":
+		p.qualified(node, "name")
+	case "BinaryExpr":
+		// 运算从左到右结合: 左边优先级更低时需要括号, 右边优先级相同时也需要
+		prio := priority(node.str("op"))
+		left, right := node.node("left"), node.node("right")
+		if exprPriority(left) < prio {
+			p.write("(")
+			p.expr(left)
+			p.write(")")
+		} else {
+			p.expr(left)
+		}
+		p.write(" ", node.str("op"), " ")
+		if exprPriority(right) <= prio {
+			p.write("(")
+			p.expr(right)
+			p.write(")")
+		} else {
+			p.expr(right)
+		}
+	case "BlockExpr":
+		p.block(node.nodes("body"))
+	case "CallFnExpr", "MethodCallExpr":
+		if node.Kind == "MethodCallExpr" {
+			p.operand(node.node("x"))
+			p.write(".", node.str("name"))
+		} else {
+			p.qualified(node, "name")
+		}
+		p.write("(")
+		for i, param := range node.nodes("params") {
+			if i > 0 {
+				p.write(", ")
+			}
+			if name := param.str("name"); name != "" {
+				p.write(name, ": ")
+			}
+			p.expr(param.node("value"))
+		}
+		p.write(")")
+	case "CallBuiltinExpr":
+		p.qualified(node, "name")
+		p.list("(", node.nodes("params"), ")")
+	case "NewRecordExpr":
+		p.qualified(node, "type")
+		p.list("(", node.nodes("fields"), ")")
+	case "FieldExpr":
+		p.operand(node.node("x"))
+		p.write(".", node.str("name"))
+	case "ListExpr":
+		p.list("[", node.nodes("elements"), "]")
+	case "IndexExpr":
+		p.operand(node.node("x"))
+		p.write("[")
+		p.expr(node.node("index"))
+		p.write("]")
+	case "SpawnExpr":
+		p.write("spawn ")
+		p.expr(node.node("call"))
+	}
+}
+
+// 模块成员带上限定名: m.f
+func (p *printer) qualified(node *Node, name string) {
+	if module := node.str("module"); module != "" {
+		p.write(module, ".")
+	}
+	p.write(node.str(name))
+}
+
+func (p *printer) list(open string, nodes []*Node, close string) {
+	p.write(open)
+	for i, node := range nodes {
+		if i > 0 {
+			p.write(", ")
+		}
+		p.expr(node)
+	}
+	p.write(close)
+}
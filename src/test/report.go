@@ -0,0 +1,173 @@
+package test
+
+import (
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"my-lang/ast"
+	"strings"
+	"time"
+)
+
+// 报告格式
+const (
+	TAP   = "tap"   // Test Anything Protocol (版本 13)
+	JUnit = "junit" // JUnit XML, CI 系统可以读取
+)
+
+// Write 按格式输出测试报告, verbose 时 TAP 报告也列出通过的测试的输出
+func Write(w io.Writer, format string, results []*Result, verbose bool) error {
+	switch format {
+	case TAP:
+		return WriteTAP(w, results, verbose)
+	case JUnit:
+		return WriteJUnit(w, results)
+	}
+	return fmt.Errorf("未知的报告格式 %s, 可以是 tap, junit", format)
+}
+
+// WriteTAP 输出 TAP 报告, 失败的信息与输出作为 # 开头的注释
+//
+//	TAP version 13
+//	1..2
+//	ok 1 - math_test.m test_add
+//	not ok 2 - math_test.m test_sub
+//	# AssertionError: assert_eq 失败
+//	# pass 1
+//	# fail 1
+func WriteTAP(w io.Writer, results []*Result, verbose bool) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "TAP version 13\n1..%d\n", len(results))
+	passed := 0
+	for i, r := range results {
+		status := "ok"
+		if !r.Passed() {
+			status = "not ok"
+		} else {
+			passed += 1
+		}
+		fmt.Fprintf(bw, "%s %d - %s\n", status, i+1, r.Title())
+		if !r.Passed() {
+			comment(bw, r.Message())
+		}
+		if r.Output != "" && (verbose || !r.Passed()) {
+			comment(bw, "输出:")
+			comment(bw, r.Output)
+		}
+	}
+	fmt.Fprintf(bw, "# pass %d\n# fail %d\n", passed, len(results)-passed)
+	return bw.Flush()
+}
+
+// 多行文本作为 TAP 注释
+func comment(w io.Writer, text string) {
+	for _, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		fmt.Fprintf(w, "# %s\n", line)
+	}
+}
+
+// JUnit XML, 每个源文件是一个 testsuite
+type (
+	junitSuites struct {
+		XMLName  xml.Name     `xml:"testsuites"`
+		Tests    int          `xml:"tests,attr"`
+		Failures int          `xml:"failures,attr"`
+		Errors   int          `xml:"errors,attr"`
+		Time     string       `xml:"time,attr"`
+		Suites   []junitSuite `xml:"testsuite"`
+	}
+
+	junitSuite struct {
+		Name     string      `xml:"name,attr"`
+		Tests    int         `xml:"tests,attr"`
+		Failures int         `xml:"failures,attr"`
+		Errors   int         `xml:"errors,attr"`
+		Time     string      `xml:"time,attr"`
+		Cases    []junitCase `xml:"testcase"`
+	}
+
+	junitCase struct {
+		Name      string        `xml:"name,attr"`
+		Classname string        `xml:"classname,attr"`
+		Time      string        `xml:"time,attr"`
+		Failure   *junitFailure `xml:"failure,omitempty"`
+		Error     *junitFailure `xml:"error,omitempty"`
+		SystemOut string        `xml:"system-out,omitempty"`
+	}
+
+	junitFailure struct {
+		Message string `xml:"message,attr"`
+		Type    string `xml:"type,attr"`
+		Text    string `xml:",chardata"`
+	}
+)
+
+// WriteJUnit 输出 JUnit XML 报告
+// 断言失败 (AssertionError) 为 failure, 其它错误为 error
+func WriteJUnit(w io.Writer, results []*Result) error {
+	report := junitSuites{}
+	index := make(map[string]int) // 源文件 -> testsuite 的下标
+	durations := make(map[string]time.Duration)
+	var total time.Duration
+	for _, r := range results {
+		i, ok := index[r.File]
+		if !ok {
+			i = len(report.Suites)
+			index[r.File] = i
+			report.Suites = append(report.Suites, junitSuite{Name: r.File})
+		}
+		suite := &report.Suites[i]
+
+		name := r.Name
+		if name == "" {
+			name = r.File
+		}
+		c := junitCase{
+			Name:      name,
+			Classname: r.File,
+			Time:      seconds(r.Duration),
+			SystemOut: r.Output,
+		}
+		if !r.Passed() {
+			failure := &junitFailure{Message: r.Err.Error(), Type: ast.ErrorKind, Text: r.Message()}
+			var scriptErr *ast.Error
+			if errors.As(r.Err, &scriptErr) {
+				failure.Type = scriptErr.Kind
+			}
+			if failure.Type == ast.AssertionError {
+				c.Failure = failure
+				suite.Failures += 1
+				report.Failures += 1
+			} else {
+				c.Error = failure
+				suite.Errors += 1
+				report.Errors += 1
+			}
+		}
+		suite.Cases = append(suite.Cases, c)
+		suite.Tests += 1
+		report.Tests += 1
+		durations[r.File] += r.Duration
+		total += r.Duration
+	}
+	for i := range report.Suites {
+		report.Suites[i].Time = seconds(durations[report.Suites[i].Name])
+	}
+	report.Time = seconds(total)
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString(xml.Header)
+	enc := xml.NewEncoder(bw)
+	enc.Indent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	bw.WriteString("\n")
+	return bw.Flush()
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
@@ -0,0 +1,194 @@
+package test
+
+import (
+	"bytes"
+	"errors"
+	"my-lang/ast"
+	"my-lang/check"
+	"my-lang/rt"
+	"my-lang/syntax"
+	"my-lang/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 单元测试
+// 测试文件以 _test.m 结尾, 其中顶层的 test "name" { ... } 块, 以及以 test_ 开头且没有参数的方法是测试
+// 每个测试单独运行: 重新执行一遍文件的顶层 (新的全局对象表与模块缓存), 然后执行测试块或者调用测试方法
+// 测试正常结束为通过, 抛出错误 (例如 assert 失败时的 AssertionError) 为失败
+
+// Suffix 测试文件的后缀
+const Suffix = "_test.m"
+
+// Prefix 测试方法的前缀
+const Prefix = "test_"
+
+type (
+	// Test 一个测试块或者测试方法
+	Test struct {
+		File string
+		Name string
+		Pos  token.Pos
+	}
+
+	// Result 测试结果
+	Result struct {
+		Test
+		Err      error  // 为 nil 时通过; 源文件本身的错误 (例如检查错误) 时 Name 为空
+		Output   string // print 的输出
+		Duration time.Duration
+	}
+)
+
+// Find 查找测试文件
+// 目录只查找其中的测试文件, dir/... 递归查找子目录, 文件直接作为测试文件
+func Find(patterns []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var files []string
+	add := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			files = append(files, path)
+		}
+	}
+
+	for _, pattern := range patterns {
+		dir, recursive := pattern, false
+		if pattern == "..." || strings.HasSuffix(pattern, "/...") {
+			dir, recursive = strings.TrimSuffix(strings.TrimSuffix(pattern, "..."), "/"), true
+			if dir == "" {
+				dir = "."
+			}
+		}
+
+		info, err := os.Stat(dir)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			add(dir)
+			continue
+		}
+		err = filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() && path != dir && !recursive {
+				return filepath.SkipDir
+			}
+			if !d.IsDir() && strings.HasSuffix(path, Suffix) {
+				add(path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// Tests 源文件中的测试块与测试方法, 按定义的顺序
+// 从完整的语法树得到, 不执行源文件; 有词法或者语法错误时返回错误
+func Tests(path string) ([]Test, error) {
+	tree, err := syntax.ParseFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var tests []Test
+	for _, node := range tree.Get("body").([]*syntax.Node) {
+		name, _ := node.Get("name").(string)
+		switch node.Kind {
+		case "TestStmt":
+			tests = append(tests, Test{File: path, Name: name, Pos: node.Pos})
+		case "FnDef":
+			args, _ := node.Get("args").([]*syntax.Node)
+			if strings.HasPrefix(name, Prefix) && len(args) == 0 {
+				tests = append(tests, Test{File: path, Name: name, Pos: node.Pos})
+			}
+		}
+	}
+	sort.SliceStable(tests, func(i, j int) bool {
+		return tests[i].Pos.Line < tests[j].Pos.Line
+	})
+	return tests, nil
+}
+
+// RunFile 运行源文件中名字与 filter 匹配 (为 nil 时全部) 的测试
+// 源文件无法解析或者有检查错误时不运行测试, 结果只有一项源文件本身的错误 (即使没有匹配的测试)
+func RunFile(path string, filter *regexp.Regexp, options rt.Options) []*Result {
+	tests, err := Tests(path)
+	if err != nil {
+		return []*Result{{Test: Test{File: path}, Err: err}}
+	}
+
+	c := check.NewChecker()
+	c.Capabilities = options.Capabilities
+	c.CheckFile(path)
+	if c.HasErrors() {
+		var msgs []string
+		for _, d := range c.Sorted() {
+			if !d.Warning {
+				msgs = append(msgs, d.String())
+			}
+		}
+		return []*Result{{Test: Test{File: path}, Err: errors.New(strings.Join(msgs, "\n"))}}
+	}
+
+	var matched []Test
+	for _, t := range tests {
+		if filter == nil || filter.MatchString(t.Name) {
+			matched = append(matched, t)
+		}
+	}
+	results := make([]*Result, 0, len(matched))
+	for _, t := range matched {
+		results = append(results, Run(t, options))
+	}
+	return results
+}
+
+// Run 运行一个测试, print 的输出记录在结果中
+func Run(t Test, options rt.Options) *Result {
+	var out bytes.Buffer
+	options.Stdout = &out
+
+	start := time.Now()
+	err := rt.ExecuteTest(t.File, t.Name, options)
+	return &Result{
+		Test:     t,
+		Err:      err,
+		Output:   out.String(),
+		Duration: time.Since(start),
+	}
+}
+
+// Passed 是否通过
+func (r *Result) Passed() bool {
+	return r.Err == nil
+}
+
+// Title 结果的名字: file_test.m test_add, 源文件本身的错误只有文件名
+func (r *Result) Title() string {
+	if r.Name == "" {
+		return r.File
+	}
+	return r.File + " " + r.Name
+}
+
+// Message 失败的信息, 脚本错误带调用栈
+func (r *Result) Message() string {
+	var scriptErr *ast.Error
+	if errors.As(r.Err, &scriptErr) {
+		return scriptErr.Traceback()
+	}
+	if r.Err != nil {
+		return r.Err.Error()
+	}
+	return ""
+}
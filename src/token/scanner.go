@@ -2,36 +2,70 @@ package token
 
 import (
 	"os"
+	"strconv"
 	"unicode"
 	"unicode/utf8"
 )
 
 type Scanner struct {
-	// todo 文件名
-	src []byte // 源码
+	file string // 文件名
+	src  []byte // 源码
 
 	offset   int  // 当前偏移位置
+	chOffset int  // 当前字符的偏移位置
 	ch       rune // 当前读取的字符 (utf-8)
 	nearlyCh byte // 下一个紧挨着的字符 (必定是 ascii)
+
+	line int // 当前字符所在行 (从 1 开始)
+	col  int // 当前字符所在列 (从 1 开始)
+
+	errors   []Error // 扫描中遇到的错误
+	comments bool    // 是否保留注释 token
+	lossless bool    // 是否记录 Trivia
+}
+
+// Error 词法错误, 例如字符串没有结束
+type Error struct {
+	Pos Pos
+	Msg string
 }
 
 // end of file
 const eof = -1
 
 func NewScanner(path string) (s *Scanner) {
-	var scanner Scanner
-
 	// 读取文件内容并存进 src
 	bytes, err := os.ReadFile(path)
 	if err != nil {
 		panic(err)
 	}
-	scanner.src = bytes
+	return NewSourceScanner(path, bytes)
+}
+
+// NewSourceScanner 扫描内存中的源码, file 只用于记录位置
+func NewSourceScanner(file string, src []byte) *Scanner {
+	var scanner Scanner
+	scanner.file = file
+	scanner.src = src
+	scanner.line = 1
 
 	scanner.next()
 	return &scanner
 }
 
+// KeepComments 保留注释 token (默认跳过, 解析器不会看到注释)
+func (s *Scanner) KeepComments() *Scanner {
+	s.comments = true
+	return s
+}
+
+// Lossless 无损模式: 每个 token 带有 Trivia, 注释与不合法的字符放进 Trivia 而不是作为 token
+// ScanTokens 的结果以 EOF 结尾, 文件末尾的空白在 EOF 的 Leading 里
+func (s *Scanner) Lossless() *Scanner {
+	s.lossless = true
+	return s
+}
+
 // 是否 offset 到达 EOF
 func (s *Scanner) isEOF() bool {
 	return s.offset >= len(s.src)
@@ -39,6 +73,14 @@ func (s *Scanner) isEOF() bool {
 
 // 读取下一个字符并偏移 offset
 func (s *Scanner) next() {
+	// 记录位置
+	if s.ch == '\n' {
+		s.line += 1
+		s.col = 0
+	}
+	s.col += 1
+	s.chOffset = s.offset
+
 	if !s.isEOF() {
 		// 切片转码成 utf8
 		r, w := utf8.DecodeRune(s.src[s.offset:])
@@ -87,8 +129,8 @@ func (s *Scanner) nextNearlyChar(ch byte) bool {
 
 // 扫描变量或者关键词字面量
 func (s *Scanner) scanIdentity() (tok Token) {
-	for unicode.IsLetter(s.ch) || s.ch == '_' {
-		// 目前仅十进制
+	// 首字符必须是字母或者下划线，之后可以包含数字
+	for unicode.IsLetter(s.ch) || s.ch == '_' || unicode.IsDigit(s.ch) {
 		tok.Lit += string(s.ch)
 		s.next()
 	}
@@ -125,14 +167,29 @@ func (s *Scanner) scanNumber() (tok Token) {
 	return
 }
 
+// 扫描注释: // 到行尾 (不含换行符)
+func (s *Scanner) scanComment() (tok Token) {
+	tok.Type = COMMENT
+	for s.ch != '\n' && s.ch != eof {
+		tok.Lit += string(s.ch)
+		s.next()
+	}
+	return
+}
+
 // 扫描字符串字面量
 func (s *Scanner) scanString(end rune) (tok Token) {
 	tok.Type = STRINGLIT
 	// [']xxx'
+	start := s.pos()
 	s.next()
 
 	// '[xxx]'
 	for s.ch != end {
+		if s.ch == eof {
+			s.error(start, "字符串没有结束")
+			return
+		}
 		tok.Lit += string(s.ch)
 		s.next()
 	}
@@ -143,10 +200,68 @@ func (s *Scanner) scanString(end rune) (tok Token) {
 	return
 }
 
+// 记录错误, 扫描继续进行
+func (s *Scanner) error(pos Pos, msg string) {
+	s.errors = append(s.errors, Error{Pos: pos, Msg: msg})
+}
+
+// Errors 扫描中遇到的错误
+func (s *Scanner) Errors() []Error {
+	return s.errors
+}
+
+// 当前字符的位置
+func (s *Scanner) pos() Pos {
+	return Pos{
+		File: s.file,
+		Line: s.line,
+		Col:  s.col,
+	}
+}
+
 // ScanNext 扫描当前字符返回对应的 Token, 并且偏移 offset 至下一个字符
 func (s *Scanner) scanNext() (tok Token) {
+	start := s.chOffset
+	for {
+		s.skipSpace()
+
+		pos := s.pos()
+		textStart := s.chOffset
+		tok = s.scan()
+		tok.Pos = pos
+
+		switch {
+		case s.lossless && (tok.Type == COMMENT || tok.Type == ILLEGAL):
+			// 留在下一个 token 的 Leading 里
+			continue
+		case tok.Type == COMMENT && !s.comments:
+			continue
+		}
+		if s.lossless {
+			tok.Trivia = &Trivia{
+				Leading: string(s.src[start:textStart]),
+				Text:    string(s.src[textStart:s.chOffset]),
+			}
+			if tok.Type != LINEBREAK && tok.Type != EOF {
+				tok.Trivia.Trailing = s.scanTrailing()
+			}
+		}
+		return
+	}
+}
 
+// 无损模式下 token 之后到行尾的空白与注释
+func (s *Scanner) scanTrailing() string {
+	start := s.chOffset
 	s.skipSpace()
+	if s.ch == '/' && s.nearlyCh == '/' {
+		s.scanComment()
+	}
+	return string(s.src[start:s.chOffset])
+}
+
+// 扫描一个 Token
+func (s *Scanner) scan() (tok Token) {
 
 	switch s.ch {
 	case eof:
@@ -161,6 +276,10 @@ func (s *Scanner) scanNext() (tok Token) {
 	case '*':
 		tok.Type = STAR
 	case '/':
+		if s.nearlyCh == '/' {
+			tok = s.scanComment()
+			return
+		}
 		tok.Type = SLASH
 	case '%':
 		tok.Type = PERCENT
@@ -174,10 +293,21 @@ func (s *Scanner) scanNext() (tok Token) {
 		tok.Type = LBRACE
 	case '}':
 		tok.Type = RBRACE
+	case '[':
+		tok.Type = LBRACK
+	case ']':
+		tok.Type = RBRACK
 	case '.':
 		tok.Type = DOT
+		if s.nearlyCh == '.' && s.offset+1 < len(s.src) && s.src[s.offset+1] == '.' {
+			tok.Type = ELLIPSIS
+			s.next()
+			s.next()
+		}
 	case ',':
 		tok.Type = COMMA
+	case ':':
+		tok.Type = COLON
 	case '\'':
 		tok = s.scanString('\'')
 		return
@@ -214,13 +344,22 @@ func (s *Scanner) scanNext() (tok Token) {
 			tok = s.scanIdentity()
 			return
 		}
+		tok.Type = ILLEGAL
+		s.error(s.pos(), "不合法的字符 "+strconv.QuoteRune(s.ch))
 	}
 	s.next()
 	return
 }
 
 func (s *Scanner) ScanTokens() (toks []Token) {
-	for tok := s.scanNext(); tok.Type != EOF; tok = s.scanNext() {
+	tok := s.scanNext()
+	for ; tok.Type != EOF; tok = s.scanNext() {
+		if tok.Type == ILLEGAL {
+			continue
+		}
+		toks = append(toks, tok)
+	}
+	if s.lossless {
 		toks = append(toks, tok)
 	}
 	return
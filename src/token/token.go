@@ -3,16 +3,47 @@ package token
 import (
 	"fmt"
 	"strconv"
+	"strings"
 )
 
 type (
 	Type  int
 	Token struct {
-		Type        // 类型
-		Lit  string // 字面量
+		Type           // 类型
+		Lit    string  // 字面量
+		Pos            // 位置
+		Trivia *Trivia // 周围的空白与注释 (只在无损模式下有)
+	}
+
+	// Trivia 无损模式下 token 周围的源码, 所有 token 的 Leading + Text + Trailing 依次拼接就是完整的源码
+	// 注释与空白属于前一个 token 的 Trailing (直到行尾), 行首的缩进与独占一行的注释属于后一个 token 的 Leading
+	Trivia struct {
+		Leading  string
+		Text     string // token 本身的源码 (字符串带引号)
+		Trailing string
+	}
+
+	// Pos 源码位置
+	Pos struct {
+		File string
+		Line int // 从 1 开始, 0 表示没有位置信息
+		Col  int // 从 1 开始
 	}
 )
 
+// IsValid 是否有位置信息
+func (pos Pos) IsValid() bool {
+	return pos.Line > 0
+}
+
+// String 格式: file:line:col
+func (pos Pos) String() string {
+	if !pos.IsValid() {
+		return pos.File
+	}
+	return fmt.Sprintf("%s:%d:%d", pos.File, pos.Line, pos.Col)
+}
+
 func EmptyToken(p Type) Token {
 	return Token{
 		Type: p,
@@ -21,7 +52,8 @@ func EmptyToken(p Type) Token {
 }
 
 const (
-	EOF Type = iota
+	EOF     Type = iota
+	ILLEGAL      // 不合法的字符 (扫描时跳过并记录错误)
 
 	PLUS      // +
 	MINUS     // -
@@ -34,8 +66,12 @@ const (
 	RPAREN    // )
 	LBRACE    // {
 	RBRACE    // }
+	LBRACK    // [
+	RBRACK    // ]
 	DOT       // .
+	ELLIPSIS  // ...
 	COMMA     // ,
+	COLON     // :
 	ASSIGN    // =
 	EQ        // ==
 	NOT       // !
@@ -49,6 +85,7 @@ const (
 	INTLIT    // 123
 	FLOATLIT  // 123.456
 	STRINGLIT // "xx", 'xx'
+	COMMENT   // // xx
 
 	TRUE
 	FALSE
@@ -57,10 +94,24 @@ const (
 	IF
 	ELSE
 	FOR
+	TYPE
+	IMPL
+	IMPORT
+	FROM
+	AS
+	TRY
+	CATCH
+	FINALLY
+	THROW
+	YIELD
+	IN
+	SPAWN
+	SELECT
 )
 
 var tokens = map[Type]string{
-	EOF: "EOF",
+	EOF:     "EOF",
+	ILLEGAL: "ILLEGAL",
 
 	PLUS:      "+",
 	MINUS:     "-",
@@ -73,8 +124,12 @@ var tokens = map[Type]string{
 	RPAREN:    ")",
 	LBRACE:    "{",
 	RBRACE:    "}",
+	LBRACK:    "[",
+	RBRACK:    "]",
 	DOT:       ".",
+	ELLIPSIS:  "...",
 	COMMA:     ",",
+	COLON:     ":",
 	ASSIGN:    "=",
 	EQ:        "==",
 	NOT:       "!",
@@ -88,6 +143,7 @@ var tokens = map[Type]string{
 	INTLIT:    "INTLIT",
 	FLOATLIT:  "FLOATLIT",
 	STRINGLIT: "STRINGLIT",
+	COMMENT:   "COMMENT",
 
 	TRUE:   "true",
 	FALSE:  "false",
@@ -96,6 +152,94 @@ var tokens = map[Type]string{
 	IF:     "if",
 	ELSE:   "else",
 	FOR:    "for",
+	TYPE:   "type",
+	IMPL:   "impl",
+	IMPORT: "import",
+	FROM:   "from",
+	AS:     "as",
+
+	TRY:     "try",
+	CATCH:   "catch",
+	FINALLY: "finally",
+	THROW:   "throw",
+
+	YIELD: "yield",
+	IN:    "in",
+
+	SPAWN:  "spawn",
+	SELECT: "select",
+}
+
+// 类型的常量名, 用于输出 token 列表等需要稳定名字的地方
+var names = [...]string{
+	EOF:       "EOF",
+	ILLEGAL:   "ILLEGAL",
+	PLUS:      "PLUS",
+	MINUS:     "MINUS",
+	STAR:      "STAR",
+	SLASH:     "SLASH",
+	PERCENT:   "PERCENT",
+	LINEBREAK: "LINEBREAK",
+	SEMICOLON: "SEMICOLON",
+	LPAREN:    "LPAREN",
+	RPAREN:    "RPAREN",
+	LBRACE:    "LBRACE",
+	RBRACE:    "RBRACE",
+	LBRACK:    "LBRACK",
+	RBRACK:    "RBRACK",
+	DOT:       "DOT",
+	ELLIPSIS:  "ELLIPSIS",
+	COMMA:     "COMMA",
+	COLON:     "COLON",
+	ASSIGN:    "ASSIGN",
+	EQ:        "EQ",
+	NOT:       "NOT",
+	NQ:        "NQ",
+	GT:        "GT",
+	GE:        "GE",
+	LT:        "LT",
+	LE:        "LE",
+	IDENTITY:  "IDENTITY",
+	INTLIT:    "INTLIT",
+	FLOATLIT:  "FLOATLIT",
+	STRINGLIT: "STRINGLIT",
+	COMMENT:   "COMMENT",
+	TRUE:      "TRUE",
+	FALSE:     "FALSE",
+	RETURN:    "RETURN",
+	PRINT:     "PRINT",
+	IF:        "IF",
+	ELSE:      "ELSE",
+	FOR:       "FOR",
+	TYPE:      "TYPE",
+	IMPL:      "IMPL",
+	IMPORT:    "IMPORT",
+	FROM:      "FROM",
+	AS:        "AS",
+	TRY:       "TRY",
+	CATCH:     "CATCH",
+	FINALLY:   "FINALLY",
+	THROW:     "THROW",
+	YIELD:     "YIELD",
+	IN:        "IN",
+	SPAWN:     "SPAWN",
+	SELECT:    "SELECT",
+}
+
+// Name 类型的常量名: IDENTITY, LPAREN, RETURN
+func (t Type) Name() string {
+	if int(t) < len(names) {
+		return names[t]
+	}
+	return "UNKNOWN"
+}
+
+// Text token 在源码中的文本 (字符串字面量不含引号)
+func (tok Token) Text() string {
+	if tok.Lit != "" {
+		return tok.Lit
+	}
+	return tokens[tok.Type]
 }
 
 func TypeString(tokType Type) string {
@@ -116,6 +260,19 @@ var Keywords = []KeywordPair{
 	{"if", IF},
 	{"else", ELSE},
 	{"for", FOR},
+	{"type", TYPE},
+	{"impl", IMPL},
+	{"import", IMPORT},
+	{"from", FROM},
+	{"as", AS},
+	{"try", TRY},
+	{"catch", CATCH},
+	{"finally", FINALLY},
+	{"throw", THROW},
+	{"yield", YIELD},
+	{"in", IN},
+	{"spawn", SPAWN},
+	{"select", SELECT},
 }
 
 func Debug(toks []Token) {
@@ -127,3 +284,44 @@ func Debug(toks []Token) {
 		fmt.Println()
 	}
 }
+
+// String 源码形式
+func (tok Token) String() string {
+	switch tok.Type {
+	case STRINGLIT:
+		return "'" + tok.Lit + "'"
+	case IDENTITY, INTLIT, FLOATLIT:
+		return tok.Lit
+	case LINEBREAK:
+		return "\n"
+	}
+	return tokens[tok.Type]
+}
+
+// Join 将 tokens 拼接成源码
+func Join(toks []Token) string {
+	var sb strings.Builder
+	for i, tok := range toks {
+		if i > 0 && needSpace(toks[i-1].Type, tok.Type) {
+			sb.WriteString(" ")
+		}
+		sb.WriteString(tok.String())
+	}
+	return sb.String()
+}
+
+// 两个相邻的 token 之间是否需要空格
+func needSpace(prev Type, next Type) bool {
+	switch prev {
+	case LPAREN, LBRACK, DOT, ELLIPSIS, LINEBREAK:
+		return false
+	}
+	switch next {
+	case RPAREN, RBRACK, DOT, COMMA, COLON, LINEBREAK:
+		return false
+	case LPAREN, LBRACK:
+		// 调用与下标: f(x), a[0]
+		return prev != IDENTITY && prev != RPAREN && prev != RBRACK
+	}
+	return true
+}